@@ -6,10 +6,15 @@ import (
 
 	"github.com/xeodocs/xeodocs-backend/internal/build"
 	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
 )
 
 func main() {
 	cfg := config.Load()
+	db.Init(cfg)
+	defer db.Close()
+	storage.Init(cfg)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/internal/build", build.BuildHandler(cfg))
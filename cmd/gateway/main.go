@@ -18,7 +18,8 @@ func main() {
 	mux.HandleFunc("/v1/roles", gateway.AuthProxyHandler(cfg))
 	mux.HandleFunc("/v1/roles/", gateway.AuthProxyHandler(cfg))
 	mux.HandleFunc("/v1/projects", gateway.ProjectProxyHandler(cfg))
-	mux.HandleFunc("/v1/projects/", gateway.ProjectProxyHandler(cfg))
+	mux.HandleFunc("/v1/projects/", gateway.ProjectRoutesHandler(cfg))
+	mux.HandleFunc("/v1/webhooks/", gateway.ProjectProxyHandler(cfg))
 	mux.HandleFunc("/v1/logs", gateway.LoggingProxyHandler(cfg))
 	mux.HandleFunc("/v1/logs/", gateway.LoggingProxyHandler(cfg))
 	mux.HandleFunc("/v1/build/", gateway.BuildProxyHandler(cfg))
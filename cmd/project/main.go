@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/xeodocs/xeodocs-backend/internal/auth"
 	"github.com/xeodocs/xeodocs-backend/internal/project"
@@ -36,6 +37,50 @@ func main() {
 				http.NotFound(w, r)
 				return
 			}
+			// Glossary sub-resource: /projects/{id}/glossary[/{termId}]
+			if strings.Contains(id, "/glossary") {
+				switch r.Method {
+				case http.MethodGet:
+					auth.JWTMiddleware(cfg, "")(project.ListGlossaryHandler(cfg))(w, r)
+				case http.MethodPost:
+					auth.JWTMiddleware(cfg, "")(project.CreateGlossaryTermHandler(cfg))(w, r)
+				case http.MethodPut:
+					auth.JWTMiddleware(cfg, "")(project.UpdateGlossaryTermHandler(cfg))(w, r)
+				case http.MethodDelete:
+					auth.JWTMiddleware(cfg, "")(project.DeleteGlossaryTermHandler(cfg))(w, r)
+				default:
+					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				}
+				return
+			}
+			// Credentials sub-resource: /projects/{id}/credentials
+			if strings.HasSuffix(strings.TrimSuffix(id, "/"), "/credentials") {
+				switch r.Method {
+				case http.MethodGet:
+					auth.JWTMiddleware(cfg, "")(project.GetCredentialsHandler(cfg))(w, r)
+				case http.MethodPut:
+					auth.JWTMiddleware(cfg, "editor")(project.SetCredentialsHandler(cfg))(w, r)
+				case http.MethodDelete:
+					auth.JWTMiddleware(cfg, "editor")(project.DeleteCredentialsHandler(cfg))(w, r)
+				default:
+					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				}
+				return
+			}
+			// Webhook sub-resource: /projects/{id}/webhook
+			if strings.HasSuffix(strings.TrimSuffix(id, "/"), "/webhook") {
+				switch r.Method {
+				case http.MethodGet:
+					auth.JWTMiddleware(cfg, "")(project.GetWebhookHandler(cfg))(w, r)
+				case http.MethodPost:
+					auth.JWTMiddleware(cfg, "editor")(project.CreateWebhookHandler(cfg))(w, r)
+				case http.MethodDelete:
+					auth.JWTMiddleware(cfg, "editor")(project.DeleteWebhookHandler(cfg))(w, r)
+				default:
+					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				}
+				return
+			}
 			switch r.Method {
 			case http.MethodGet:
 				auth.JWTMiddleware(cfg, "")(project.GetProjectHandler(cfg))(w, r)
@@ -51,6 +96,9 @@ func main() {
 		}
 	})
 
+	// Push webhooks - public, authenticated by the per-project webhook secret
+	mux.HandleFunc("/webhooks/", project.ReceiveWebhookHandler(cfg))
+
 	log.Printf("Starting Project Service on port %s", cfg.ProjectPort)
 	log.Fatal(http.ListenAndServe(":"+cfg.ProjectPort, mux))
 }
@@ -3,20 +3,39 @@ package main
 import (
 	"log"
 	"net/http"
+	"strings"
 
+	"github.com/xeodocs/xeodocs-backend/internal/auth"
 	"github.com/xeodocs/xeodocs-backend/internal/repository"
 	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
 )
 
 func main() {
 	cfg := config.Load()
+	db.Init(cfg)
+	defer db.Close()
+	storage.Init(cfg)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/internal/clone-repo", repository.CloneRepoHandler(cfg))
 	mux.HandleFunc("/internal/create-language-copies", repository.CreateLanguageCopiesHandler(cfg))
 	mux.HandleFunc("/internal/sync-repo", repository.SyncRepoHandler(cfg))
+	mux.HandleFunc("/internal/commit-translations", repository.CommitTranslationsHandler(cfg))
 	mux.HandleFunc("/internal/delete-repo", repository.DeleteRepoHandler(cfg))
 
+	// Repository files of a project: /projects/{id}/files/... and /projects/{id}/storage
+	files := auth.JWTMiddleware(cfg, "")(repository.FilesHandler(cfg))
+	usage := auth.JWTMiddleware(cfg, "")(repository.StorageUsageHandler(cfg))
+	mux.HandleFunc("/projects/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/storage") {
+			usage(w, r)
+			return
+		}
+		files(w, r)
+	})
+
 	log.Printf("Starting Repository Service on port %s", cfg.RepositoryPort)
 	log.Fatal(http.ListenAndServe(":"+cfg.RepositoryPort, mux))
 }
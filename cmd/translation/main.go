@@ -1,7 +1,80 @@
 package main
 
-import "fmt"
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/xeodocs/xeodocs-backend/internal/auth"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+	"github.com/xeodocs/xeodocs-backend/internal/translation"
+)
 
 func main() {
-	fmt.Println("Starting Translation Service")
+	cfg := config.Load()
+	db.Init(cfg)
+	defer db.Close()
+
+	translator, err := translation.NewTranslator(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize translator: %v", err)
+	}
+
+	memory := translation.NewPostgresMemory()
+	pipeline := &translation.Pipeline{
+		Translator:     translator,
+		Memory:         memory,
+		Glossary:       translation.NewProjectGlossary(),
+		Prompts:        translation.NewProjectPrompts(),
+		Links:          translation.NewProjectLinks(),
+		Files:          translation.NewProjectFiles(),
+		FuzzyThreshold: cfg.TMFuzzyThreshold,
+		TokenBudget:    cfg.AITokenBudget,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/internal/translate", translation.TranslateHandler(cfg, translator))
+	mux.HandleFunc("/internal/translate-document", translation.TranslateDocumentHandler(cfg, pipeline))
+	mux.HandleFunc("/internal/jobs/", translation.GetJobHandler(cfg))
+	mux.HandleFunc("/internal/commit-failed", translation.CommitFailedHandler(cfg))
+
+	// Project translation resources - protected, routed here by the gateway
+	mux.HandleFunc("/projects/", func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/usage"):
+			auth.JWTMiddleware(cfg, "")(translation.GetUsageHandler(cfg))(w, r)
+		case strings.HasSuffix(path, "/translations"):
+			auth.JWTMiddleware(cfg, "")(translation.ListTranslationsHandler(cfg))(w, r)
+		case strings.HasSuffix(path, "/segments"):
+			switch r.Method {
+			case http.MethodGet:
+				auth.JWTMiddleware(cfg, "")(translation.GetReviewHandler(cfg))(w, r)
+			case http.MethodPut:
+				auth.JWTMiddleware(cfg, "editor")(translation.SubmitCorrectionsHandler(cfg))(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+		case strings.HasSuffix(path, "/prompt"):
+			auth.JWTMiddleware(cfg, "editor")(translation.PreviewPromptHandler(cfg, pipeline))(w, r)
+		case strings.HasSuffix(path, "/stale"):
+			auth.JWTMiddleware(cfg, "")(translation.GetStaleReportHandler(cfg))(w, r)
+		case strings.HasSuffix(path, "/qa"):
+			auth.JWTMiddleware(cfg, "")(translation.GetQAFindingsHandler(cfg))(w, r)
+		case strings.HasSuffix(path, "/approve"):
+			auth.JWTMiddleware(cfg, "editor")(translation.ApproveFileHandler(cfg, memory))(w, r)
+		case strings.HasSuffix(path, "/reject"):
+			auth.JWTMiddleware(cfg, "editor")(translation.RejectFileHandler(cfg, memory))(w, r)
+		case strings.HasSuffix(path, "/export"):
+			auth.JWTMiddleware(cfg, "")(translation.ExportHandler(cfg))(w, r)
+		case strings.HasSuffix(path, "/import"):
+			auth.JWTMiddleware(cfg, "editor")(translation.ImportHandler(cfg))(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	log.Printf("Starting Translation Service on port %s with provider %s", cfg.TranslationPort, translator.Name())
+	log.Fatal(http.ListenAndServe(":"+cfg.TranslationPort, mux))
 }
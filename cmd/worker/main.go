@@ -4,11 +4,13 @@ import (
 	"log"
 
 	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
 	"github.com/xeodocs/xeodocs-backend/internal/worker"
 )
 
 func main() {
 	cfg := config.Load()
+	storage.Init(cfg)
 
 	// Initialize worker
 	worker.Start(cfg)
@@ -43,7 +43,7 @@ func JWTMiddleware(cfg *config.Config, requiredRole string) func(http.HandlerFun
 				return
 			}
 
-			if requiredRole != "" && claims.RoleID != 1 {
+			if !auth.HasRole(claims, requiredRole) {
 				http.Error(w, "Insufficient permissions", http.StatusForbidden)
 				return
 			}
@@ -4,11 +4,10 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"strconv"
 
 	"github.com/xeodocs/xeodocs-backend/internal/project"
 	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
 )
 
 // ExecuteBuild executes the build command for a project
@@ -22,8 +21,14 @@ func ExecuteBuild(projectID int, cfg *config.Config) error {
 		return fmt.Errorf("no build command configured for project")
 	}
 
-	// Assume repos are stored in /repos/{projectID}
-	repoPath := filepath.Join("/repos", strconv.Itoa(projectID))
+	// Builds wait for syncs and other builds of the project to finish
+	unlock, err := storage.LockProject(projectID, cfg.ProjectLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	repoPath := storage.Repos.ProjectPath(projectID)
 
 	// Check if repo directory exists
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
@@ -45,8 +50,14 @@ func ExecuteExport(projectID int, cfg *config.Config) error {
 		return fmt.Errorf("no export command configured for project")
 	}
 
-	// Assume repos are stored in /repos/{projectID}
-	repoPath := filepath.Join("/repos", strconv.Itoa(projectID))
+	// Exports wait for syncs and builds of the project to finish
+	unlock, err := storage.LockProject(projectID, cfg.ProjectLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	repoPath := storage.Repos.ProjectPath(projectID)
 
 	// Check if repo directory exists
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
@@ -68,8 +79,8 @@ func ExecutePreview(projectID int, cfg *config.Config) error {
 		return fmt.Errorf("no preview command configured for project")
 	}
 
-	// Assume repos are stored in /repos/{projectID}
-	repoPath := filepath.Join("/repos", strconv.Itoa(projectID))
+	// Previews may keep running, so they do not hold the project lock
+	repoPath := storage.Repos.ProjectPath(projectID)
 
 	// Check if repo directory exists
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
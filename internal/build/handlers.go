@@ -2,13 +2,25 @@ package build
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
 	"github.com/xeodocs/xeodocs-backend/internal/shared/logging"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
 )
 
+// writeExecutionError reports a failed build, export or preview. Projects locked
+// by another operation get 409 Conflict so that the worker retries later.
+func writeExecutionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrBusy) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
 // BuildHandler handles build requests for projects
 func BuildHandler(cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -35,7 +47,7 @@ func BuildHandler(cfg *config.Config) http.HandlerFunc {
 		if err != nil {
 			message := "Build service failed to build project " + strconv.Itoa(req.ProjectID) + ": " + err.Error()
 			logging.LogActivity(cfg.LoggingServiceURL, "build_error", message, nil, &req.ProjectID, "error")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeExecutionError(w, err)
 			return
 		}
 
@@ -74,7 +86,7 @@ func ExportHandler(cfg *config.Config) http.HandlerFunc {
 		if err != nil {
 			message := "Build service failed to export project " + strconv.Itoa(req.ProjectID) + ": " + err.Error()
 			logging.LogActivity(cfg.LoggingServiceURL, "export_error", message, nil, &req.ProjectID, "error")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeExecutionError(w, err)
 			return
 		}
 
@@ -113,7 +125,7 @@ func PreviewHandler(cfg *config.Config) http.HandlerFunc {
 		if err != nil {
 			message := "Build service failed to preview project " + strconv.Itoa(req.ProjectID) + ": " + err.Error()
 			logging.LogActivity(cfg.LoggingServiceURL, "preview_error", message, nil, &req.ProjectID, "error")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeExecutionError(w, err)
 			return
 		}
 
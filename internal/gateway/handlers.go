@@ -135,6 +135,68 @@ func BuildProxyHandler(cfg *config.Config) http.HandlerFunc {
 	}
 }
 
+func TranslationProxyHandler(cfg *config.Config) http.HandlerFunc {
+	targetURL, _ := url.Parse(cfg.TranslationServiceURL)
+	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+
+	// Modify the request to strip /v1 prefix
+	originalDirector := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		originalDirector(req)
+		req.URL.Path = strings.TrimPrefix(req.URL.Path, "/v1")
+		req.URL.RawPath = strings.TrimPrefix(req.URL.RawPath, "/v1")
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Add CORS headers
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// Log the incoming request
+		message := "Gateway received request: " + r.Method + " " + r.URL.Path
+		logging.LogActivity(cfg.LoggingServiceURL, "gateway_request", message, nil, nil, "info")
+
+		proxy.ServeHTTP(w, r)
+	}
+}
+
+func RepositoryProxyHandler(cfg *config.Config) http.HandlerFunc {
+	targetURL, _ := url.Parse(cfg.RepositoryServiceURL)
+	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+
+	// Modify the request to strip /v1 prefix
+	originalDirector := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		originalDirector(req)
+		req.URL.Path = strings.TrimPrefix(req.URL.Path, "/v1")
+		req.URL.RawPath = strings.TrimPrefix(req.URL.RawPath, "/v1")
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Add CORS headers
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// Log the incoming request
+		message := "Gateway received request: " + r.Method + " " + r.URL.Path
+		logging.LogActivity(cfg.LoggingServiceURL, "gateway_request", message, nil, nil, "info")
+
+		proxy.ServeHTTP(w, r)
+	}
+}
+
 func AnalyticsProxyHandler(cfg *config.Config) http.HandlerFunc {
 	targetURL, _ := url.Parse(cfg.AnalyticsServiceURL)
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
@@ -1 +1,51 @@
 package gateway
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+)
+
+// translationResources are the project sub-resources served by the translation service
+var translationResources = map[string]bool{
+	"translations": true,
+	"usage":        true,
+}
+
+// repositoryResources are the project sub-resources served by the repository service
+var repositoryResources = map[string]bool{
+	"files":   true,
+	"storage": true,
+}
+
+// projectSubresource returns e.g. "translations" for /v1/projects/{id}/translations/...,
+// or an empty string for project paths without a sub-resource
+func projectSubresource(path string) string {
+	parts := strings.SplitN(strings.Trim(strings.TrimPrefix(path, "/v1/projects/"), "/"), "/", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// ProjectRoutesHandler routes /v1/projects/ to the service that owns each sub-resource.
+// Everything else under a project is handled by the project service.
+func ProjectRoutesHandler(cfg *config.Config) http.HandlerFunc {
+	projectProxy := ProjectProxyHandler(cfg)
+	translationProxy := TranslationProxyHandler(cfg)
+	repositoryProxy := RepositoryProxyHandler(cfg)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		resource := projectSubresource(r.URL.Path)
+		if translationResources[resource] {
+			translationProxy(w, r)
+			return
+		}
+		if repositoryResources[resource] {
+			repositoryProxy(w, r)
+			return
+		}
+		projectProxy(w, r)
+	}
+}
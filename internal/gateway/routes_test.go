@@ -0,0 +1,18 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectSubresource(t *testing.T) {
+	require.Equal(t, "", projectSubresource("/v1/projects/"))
+	require.Equal(t, "", projectSubresource("/v1/projects/12"))
+	require.Equal(t, "glossary", projectSubresource("/v1/projects/12/glossary/3"))
+	require.Equal(t, "translations", projectSubresource("/v1/projects/12/translations"))
+	require.Equal(t, "usage", projectSubresource("/v1/projects/12/usage"))
+	require.True(t, translationResources[projectSubresource("/v1/projects/12/usage")])
+	require.True(t, repositoryResources[projectSubresource("/v1/projects/12/files/preview")])
+	require.True(t, repositoryResources[projectSubresource("/v1/projects/12/storage")])
+}
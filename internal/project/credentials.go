@@ -0,0 +1,247 @@
+package project
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+	"golang.org/x/crypto/ssh"
+)
+
+// Credential types
+const (
+	CredentialHTTPS = "https"
+	CredentialSSH   = "ssh"
+)
+
+// ErrInvalidCredentials is returned for incomplete or unparsable credentials
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Credentials authenticate git operations on a project's repositories. The
+// secret fields are encrypted at rest and never returned by the API.
+type Credentials struct {
+	Type string `json:"type"`
+	// Username defaults to x-access-token for HTTPS tokens and git for SSH
+	Username   string `json:"username"`
+	Token      string `json:"token,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+	// KnownHosts is required for SSH, in OpenSSH known_hosts format
+	KnownHosts string `json:"known_hosts,omitempty"`
+}
+
+// credentialSecret is the encrypted part of Credentials
+type credentialSecret struct {
+	Token      string `json:"token,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+// CredentialsInfo describes stored credentials without their secret
+type CredentialsInfo struct {
+	ProjectID  int       `json:"project_id"`
+	Type       string    `json:"type"`
+	Username   string    `json:"username"`
+	KnownHosts string    `json:"known_hosts,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Validate checks that the credentials are complete and can be used for git
+func (c Credentials) Validate() error {
+	switch c.Type {
+	case CredentialHTTPS:
+		if c.Token == "" {
+			return fmt.Errorf("%w: token is required", ErrInvalidCredentials)
+		}
+	case CredentialSSH:
+		if c.PrivateKey == "" || c.KnownHosts == "" {
+			return fmt.Errorf("%w: private_key and known_hosts are required", ErrInvalidCredentials)
+		}
+	default:
+		return fmt.Errorf("%w: type must be %q or %q", ErrInvalidCredentials, CredentialHTTPS, CredentialSSH)
+	}
+	_, err := c.AuthMethod()
+	return err
+}
+
+// AuthMethod returns the go-git transport authentication for the credentials
+func (c Credentials) AuthMethod() (transport.AuthMethod, error) {
+	switch c.Type {
+	case CredentialHTTPS:
+		username := c.Username
+		if username == "" {
+			username = "x-access-token"
+		}
+		return &githttp.BasicAuth{Username: username, Password: c.Token}, nil
+	case CredentialSSH:
+		username := c.Username
+		if username == "" {
+			username = "git"
+		}
+		keys, err := gitssh.NewPublicKeys(username, []byte(c.PrivateKey), c.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+		}
+		callback, err := knownHostsCallback(c.KnownHosts)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+		}
+		keys.HostKeyCallback = callback
+		return keys, nil
+	}
+	return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidCredentials, c.Type)
+}
+
+// knownHostsCallback verifies host keys against knownHosts. The entries are
+// written to a temporary file because the known_hosts parser only reads files.
+func knownHostsCallback(knownHosts string) (ssh.HostKeyCallback, error) {
+	f, err := os.CreateTemp("", "known_hosts")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(knownHosts); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return gitssh.NewKnownHostsCallback(f.Name())
+}
+
+// encryptSecret seals plaintext with AES-256-GCM under a key derived from secretKey.
+// The nonce is stored in front of the ciphertext.
+func encryptSecret(secretKey string, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(secretKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decryptSecret opens a value sealed by encryptSecret
+func decryptSecret(secretKey string, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(secretKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted secret is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(secretKey string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(secretKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SaveCredentials stores the credentials of a project, replacing any existing ones
+func SaveCredentials(projectID int, c Credentials, secretKey string) (*CredentialsInfo, error) {
+	plaintext, err := json.Marshal(credentialSecret{Token: c.Token, PrivateKey: c.PrivateKey, Passphrase: c.Passphrase})
+	if err != nil {
+		return nil, err
+	}
+	secret, err := encryptSecret(secretKey, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt credentials: %w", err)
+	}
+
+	info := &CredentialsInfo{ProjectID: projectID, Type: c.Type, Username: c.Username, KnownHosts: c.KnownHosts}
+	now := time.Now()
+	query := `INSERT INTO project_credentials (project_id, type, username, secret, known_hosts, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (project_id) DO UPDATE SET type = EXCLUDED.type, username = EXCLUDED.username, secret = EXCLUDED.secret,
+			known_hosts = EXCLUDED.known_hosts, updated_at = EXCLUDED.updated_at
+		RETURNING created_at, updated_at`
+	err = db.DB.QueryRow(query, projectID, c.Type, c.Username, secret, c.KnownHosts, now).Scan(&info.CreatedAt, &info.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// GetCredentialsInfo returns the stored credentials of a project without their secret
+func GetCredentialsInfo(projectID int) (*CredentialsInfo, error) {
+	info := &CredentialsInfo{}
+	query := `SELECT project_id, type, username, known_hosts, created_at, updated_at FROM project_credentials WHERE project_id = $1`
+	err := db.DB.QueryRow(query, projectID).Scan(&info.ProjectID, &info.Type, &info.Username, &info.KnownHosts, &info.CreatedAt, &info.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("credentials not found")
+		}
+		return nil, err
+	}
+	return info, nil
+}
+
+// GetCredentials returns the decrypted credentials of a project, or nil if it has none
+func GetCredentials(projectID int, secretKey string) (*Credentials, error) {
+	c := &Credentials{}
+	var sealed []byte
+	query := `SELECT type, username, secret, known_hosts FROM project_credentials WHERE project_id = $1`
+	err := db.DB.QueryRow(query, projectID).Scan(&c.Type, &c.Username, &sealed, &c.KnownHosts)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	plaintext, err := decryptSecret(secretKey, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials: %w", err)
+	}
+	var secret credentialSecret
+	if err := json.Unmarshal(plaintext, &secret); err != nil {
+		return nil, err
+	}
+	c.Token, c.PrivateKey, c.Passphrase = secret.Token, secret.PrivateKey, secret.Passphrase
+	return c, nil
+}
+
+// GetAuthMethod returns the git authentication of a project, or nil for public repositories
+func GetAuthMethod(projectID int, secretKey string) (transport.AuthMethod, error) {
+	c, err := GetCredentials(projectID, secretKey)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	return c.AuthMethod()
+}
+
+// DeleteCredentials removes the credentials of a project
+func DeleteCredentials(projectID int) error {
+	result, err := db.DB.Exec(`DELETE FROM project_credentials WHERE project_id = $1`, projectID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("credentials not found")
+	}
+	return nil
+}
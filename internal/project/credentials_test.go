@@ -0,0 +1,74 @@
+package project
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestEncryptSecret(t *testing.T) {
+	sealed, err := encryptSecret("key", []byte("ghp_secret"))
+	require.NoError(t, err)
+	require.NotContains(t, string(sealed), "ghp_secret")
+
+	plaintext, err := decryptSecret("key", sealed)
+	require.NoError(t, err)
+	require.Equal(t, "ghp_secret", string(plaintext))
+
+	_, err = decryptSecret("other-key", sealed)
+	require.Error(t, err)
+
+	// Each encryption uses a fresh nonce
+	again, err := encryptSecret("key", []byte("ghp_secret"))
+	require.NoError(t, err)
+	require.NotEqual(t, sealed, again)
+}
+
+func TestHTTPSCredentials(t *testing.T) {
+	require.ErrorIs(t, Credentials{Type: CredentialHTTPS}.Validate(), ErrInvalidCredentials)
+	require.ErrorIs(t, Credentials{Type: "ftp", Token: "x"}.Validate(), ErrInvalidCredentials)
+
+	auth, err := Credentials{Type: CredentialHTTPS, Token: "ghp_secret"}.AuthMethod()
+	require.NoError(t, err)
+	require.Equal(t, &githttp.BasicAuth{Username: "x-access-token", Password: "ghp_secret"}, auth)
+}
+
+func TestSSHCredentials(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(private, "")
+	require.NoError(t, err)
+	privateKey := string(pem.EncodeToMemory(block))
+
+	hostPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostKey, err := ssh.NewPublicKey(hostPublic)
+	require.NoError(t, err)
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ssh.NewPublicKey(otherPublic)
+	require.NoError(t, err)
+
+	creds := Credentials{Type: CredentialSSH, PrivateKey: privateKey, KnownHosts: "github.com " + string(ssh.MarshalAuthorizedKey(hostKey))}
+	require.NoError(t, creds.Validate())
+	require.ErrorIs(t, Credentials{Type: CredentialSSH, PrivateKey: privateKey}.Validate(), ErrInvalidCredentials)
+	require.ErrorIs(t, Credentials{Type: CredentialSSH, PrivateKey: "not a key", KnownHosts: creds.KnownHosts}.Validate(), ErrInvalidCredentials)
+
+	auth, err := creds.AuthMethod()
+	require.NoError(t, err)
+	keys := auth.(*gitssh.PublicKeys)
+	require.Equal(t, "git", keys.User)
+
+	// Only the pinned host key is accepted
+	addr := &net.TCPAddr{IP: net.ParseIP("140.82.112.3"), Port: 22}
+	require.NoError(t, keys.HostKeyCallback("github.com:22", addr, hostKey))
+	require.Error(t, keys.HostKeyCallback("github.com:22", addr, otherKey))
+	require.Error(t, keys.HostKeyCallback("gitlab.com:22", addr, hostKey))
+}
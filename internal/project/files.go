@@ -0,0 +1,167 @@
+package project
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ErrInvalidFilePattern is returned for glob patterns that cannot be parsed
+var ErrInvalidFilePattern = errors.New("invalid file pattern")
+
+// Patterns is a list of glob patterns stored as JSONB
+type Patterns []string
+
+// Value implements driver.Valuer for JSONB
+func (p Patterns) Value() (driver.Value, error) {
+	if p == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(p)
+}
+
+// Scan implements sql.Scanner for JSONB
+func (p *Patterns) Scan(value interface{}) error {
+	if value == nil {
+		*p = Patterns{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+// DefaultExcludePatterns skip files that are not documentation, by file type.
+// Patterns under "all" apply to every file.
+var DefaultExcludePatterns = map[string][]string{
+	"all":      {"node_modules/**", "vendor/**", "third_party/**", "**/generated/**", "*.generated.*", ".github/**"},
+	"markdown": {"CHANGELOG*", "CHANGES.md", "HISTORY.md", "LICENSE*", "CODE_OF_CONDUCT.md", "SECURITY.md"},
+	"catalog":  {"package.json", "package-lock.json", "composer.json", "tsconfig*.json"},
+}
+
+// fileTypes maps extensions to the keys of DefaultExcludePatterns
+var fileTypes = map[string]string{
+	".md":       "markdown",
+	".mdx":      "markdown",
+	".markdown": "markdown",
+	".json":     "catalog",
+	".yaml":     "catalog",
+	".yml":      "catalog",
+	".toml":     "catalog",
+}
+
+// FileRules select which files of a project are translated. Paths are relative
+// to the repository root. A pattern without a slash matches the file name in any
+// directory, "**" matches any number of directories and a trailing slash matches
+// everything below a directory.
+type FileRules struct {
+	// Include limits translation to matching files; empty includes every file
+	Include []string
+	// Exclude skips matching files, in addition to DefaultExcludePatterns
+	Exclude []string
+}
+
+// FileRules returns the include and exclude rules of the project
+func (p *Project) FileRules() FileRules {
+	return FileRules{Include: p.IncludePatterns, Exclude: p.ExcludePatterns}
+}
+
+// Validate checks that every pattern can be parsed
+func (r FileRules) Validate() error {
+	for _, pattern := range append(append([]string{}, r.Include...), r.Exclude...) {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("%w: empty pattern", ErrInvalidFilePattern)
+		}
+		for _, segment := range strings.Split(strings.Trim(pattern, "/"), "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("%w: %q", ErrInvalidFilePattern, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// Translatable reports whether file is translated. The project's exclude patterns
+// always apply; the default ones do not apply to files matched by an include pattern.
+func (r FileRules) Translatable(file string) bool {
+	_, ok := r.Match(file)
+	return ok
+}
+
+// Match reports whether file is translated and, if not, the pattern that excluded it.
+// The pattern is empty when no include pattern matched.
+func (r FileRules) Match(file string) (string, bool) {
+	if pattern, ok := matchAny(r.Exclude, file); ok {
+		return pattern, false
+	}
+	if len(r.Include) > 0 {
+		if _, ok := matchAny(r.Include, file); !ok {
+			return "", false
+		}
+	} else {
+		if pattern, ok := matchAny(DefaultExcludePatterns["all"], file); ok {
+			return pattern, false
+		}
+		if fileType, ok := fileTypes[strings.ToLower(path.Ext(file))]; ok {
+			if pattern, ok := matchAny(DefaultExcludePatterns[fileType], file); ok {
+				return pattern, false
+			}
+		}
+	}
+	return "", true
+}
+
+// matchAny returns the first pattern that matches file
+func matchAny(patterns []string, file string) (string, bool) {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, file) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// MatchGlob reports whether a slash-separated file path matches pattern
+func MatchGlob(pattern, file string) bool {
+	pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "/")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse repeated ** and try every number of skipped directories
+			for len(pattern) > 1 && pattern[1] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return len(parts) > 0
+			}
+			for i := 0; i < len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], parts[0]); err != nil || !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
@@ -0,0 +1,57 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for _, c := range []struct {
+		pattern, path string
+		match         bool
+	}{
+		{"CHANGELOG.md", "CHANGELOG.md", true},
+		{"CHANGELOG.md", "packages/core/CHANGELOG.md", true},
+		{"docs/*.md", "docs/intro.md", true},
+		{"docs/*.md", "docs/guides/intro.md", false},
+		{"docs/**/*.md", "docs/intro.md", true},
+		{"docs/**/*.md", "docs/guides/deep/intro.md", true},
+		{"docs/api/**", "docs/api/v1/client.md", true},
+		{"docs/api/", "docs/api/index.md", true},
+		{"/docs/api/**", "docs/apis.md", false},
+		{"**/generated/**", "src/generated/types.md", true},
+		{"*.generated.*", "docs/api.generated.md", true},
+		{"vendor/**", "docs/vendor/notes.md", false},
+		{"[Rr]eadme.md", "pkg/readme.md", true},
+	} {
+		require.Equal(t, c.match, MatchGlob(c.pattern, c.path), "%s %s", c.pattern, c.path)
+	}
+}
+
+func TestFileRules(t *testing.T) {
+	defaults := FileRules{}
+	require.True(t, defaults.Translatable("docs/intro.md"))
+	pattern, ok := defaults.Match("CHANGELOG.md")
+	require.False(t, ok)
+	require.Equal(t, "CHANGELOG*", pattern)
+	require.False(t, defaults.Translatable("node_modules/pkg/README.md"))
+	require.False(t, defaults.Translatable("locales/package.json"))
+	// Markdown defaults do not apply to catalogs
+	require.True(t, defaults.Translatable("locales/LICENSE.json"))
+
+	rules := FileRules{Include: []string{"docs/**", "CHANGELOG.md"}, Exclude: []string{"docs/api/**"}}
+	require.True(t, rules.Translatable("docs/guide.md"))
+	require.True(t, rules.Translatable("CHANGELOG.md"), "explicitly included despite the defaults")
+	require.False(t, rules.Translatable("README.md"))
+	pattern, ok = rules.Match("docs/api/client.md")
+	require.False(t, ok)
+	require.Equal(t, "docs/api/**", pattern)
+	pattern, ok = rules.Match("README.md")
+	require.False(t, ok)
+	require.Empty(t, pattern)
+
+	require.NoError(t, rules.Validate())
+	require.ErrorIs(t, FileRules{Exclude: []string{"docs/[a-"}}.Validate(), ErrInvalidFilePattern)
+	require.ErrorIs(t, FileRules{Include: []string{" "}}.Validate(), ErrInvalidFilePattern)
+}
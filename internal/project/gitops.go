@@ -1 +1,180 @@
 package project
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+)
+
+// pushRemoteName is the remote that translated branches are pushed to
+const pushRemoteName = "xeodocs"
+
+// CommitAuthor identifies the author or committer of a translation commit
+type CommitAuthor struct {
+	Name  string
+	Email string
+}
+
+func (a CommitAuthor) String() string {
+	return fmt.Sprintf("%s <%s>", a.Name, a.Email)
+}
+
+// TranslationCommit describes changes to a language branch
+type TranslationCommit struct {
+	Language     string
+	SourceCommit string
+	// Files are paths relative to the worktree; missing files are recorded as deletions
+	Files     []string
+	Providers []string
+	Reviewers []CommitAuthor
+	// Summary replaces the default subject line, e.g. for source syncs
+	Summary string
+}
+
+// CommitMessage renders a subject line followed by one trailer per fact, so that
+// the history of a language branch can be searched with git log --grep
+func CommitMessage(c TranslationCommit) string {
+	var sb strings.Builder
+	if c.Summary != "" {
+		sb.WriteString(c.Summary)
+	} else if len(c.Files) == 1 {
+		fmt.Fprintf(&sb, "Translate %s to %s", c.Files[0], c.Language)
+	} else {
+		fmt.Fprintf(&sb, "Translate %d files to %s", len(c.Files), c.Language)
+	}
+
+	sb.WriteString("\n\nFiles:\n")
+	for _, file := range c.Files {
+		sb.WriteString("- " + file + "\n")
+	}
+
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "Language: %s\n", c.Language)
+	if c.SourceCommit != "" {
+		fmt.Fprintf(&sb, "Source-Commit: %s\n", c.SourceCommit)
+	}
+	for _, provider := range c.Providers {
+		fmt.Fprintf(&sb, "Translated-By: %s\n", provider)
+	}
+	for _, reviewer := range c.Reviewers {
+		fmt.Fprintf(&sb, "Reviewed-By: %s\n", reviewer)
+	}
+	return sb.String()
+}
+
+// CommitTranslations commits the listed files of the language worktree at path.
+// The reviewer is the author when there is exactly one, committer otherwise.
+// It returns the new commit hash, or an empty string when the files did not change.
+func CommitTranslations(path string, c TranslationCommit, committer CommitAuthor) (string, error) {
+	repo, err := openWorktree(path)
+	if err != nil {
+		return "", err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	files := append([]string{}, c.Files...)
+	sort.Strings(files)
+	c.Files = files
+	for _, file := range files {
+		if !filepath.IsLocal(file) {
+			return "", fmt.Errorf("invalid file path: %s", file)
+		}
+		if _, err := os.Lstat(filepath.Join(path, file)); errors.Is(err, fs.ErrNotExist) {
+			if _, err := worktree.Remove(file); err != nil && !errors.Is(err, object.ErrFileNotFound) {
+				return "", fmt.Errorf("failed to stage deletion of %s: %w", file, err)
+			}
+			continue
+		}
+		if _, err := worktree.Add(file); err != nil {
+			return "", fmt.Errorf("failed to stage %s: %w", file, err)
+		}
+	}
+
+	now := time.Now()
+	author := committer
+	if len(c.Reviewers) == 1 {
+		author = c.Reviewers[0]
+	}
+	hash, err := worktree.Commit(CommitMessage(c), &git.CommitOptions{
+		Author:    &object.Signature{Name: author.Name, Email: author.Email, When: now},
+		Committer: &object.Signature{Name: committer.Name, Email: committer.Email, When: now},
+	})
+	if errors.Is(err, git.ErrEmptyCommit) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to commit: %w", err)
+	}
+	return hash.String(), nil
+}
+
+// PushBranch pushes branch from the worktree at path to remoteURL. The remote
+// is stored as "xeodocs" and updated when the project's push URL changes.
+func PushBranch(path, remoteURL, branch string, auth transport.AuthMethod) error {
+	repo, err := openWorktree(path)
+	if err != nil {
+		return err
+	}
+
+	remote, err := repo.Remote(pushRemoteName)
+	if err != nil && !errors.Is(err, git.ErrRemoteNotFound) {
+		return err
+	}
+	if remote != nil {
+		if urls := remote.Config().URLs; len(urls) != 1 || urls[0] != remoteURL {
+			if err := repo.DeleteRemote(pushRemoteName); err != nil {
+				return err
+			}
+			remote = nil
+		}
+	}
+	if remote == nil {
+		if _, err := repo.CreateRemote(&gitconfig.RemoteConfig{Name: pushRemoteName, URLs: []string{remoteURL}}); err != nil {
+			return fmt.Errorf("failed to configure push remote: %w", err)
+		}
+	}
+
+	ref := "refs/heads/" + branch
+	err = repo.Push(&git.PushOptions{
+		RemoteName: pushRemoteName,
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(ref + ":" + ref)},
+		Auth:       auth,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("failed to push %s: %w", branch, err)
+	}
+	return nil
+}
+
+// openWorktree opens a linked worktree, whose objects and refs live in the source checkout
+func openWorktree(path string) (*git.Repository, error) {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open worktree %s: %w", path, err)
+	}
+	return repo, nil
+}
+
+// GetCommitAuthor returns the name and email of a user for commit attribution
+func GetCommitAuthor(userID int) (*CommitAuthor, error) {
+	author := &CommitAuthor{}
+	query := `SELECT username, email FROM users WHERE id = $1`
+	if err := db.DB.QueryRow(query, userID).Scan(&author.Name, &author.Email); err != nil {
+		return nil, err
+	}
+	return author, nil
+}
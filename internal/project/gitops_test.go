@@ -0,0 +1,95 @@
+package project
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testCommitter = CommitAuthor{Name: "XeoDocs", Email: "bot@xeodocs.com"}
+
+func runGitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// languageWorktree creates a source repository with one commit and a worktree on xeodocs/es
+func languageWorktree(t *testing.T) (source, worktree string) {
+	root := t.TempDir()
+	source = filepath.Join(root, "source")
+	worktree = filepath.Join(root, "worktrees", "es")
+	require.NoError(t, os.MkdirAll(filepath.Join(source, "docs"), 0755))
+	runGitCmd(t, source, "init", "-q")
+	require.NoError(t, os.WriteFile(filepath.Join(source, "docs", "intro.md"), []byte("# Intro\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "docs", "old.md"), []byte("# Old\n"), 0644))
+	runGitCmd(t, source, "add", ".")
+	runGitCmd(t, source, "commit", "-q", "-m", "Initial")
+	runGitCmd(t, source, "worktree", "add", "-q", "-b", "xeodocs/es", worktree)
+	return source, worktree
+}
+
+func TestCommitMessage(t *testing.T) {
+	message := CommitMessage(TranslationCommit{
+		Language:     "es",
+		SourceCommit: "abc123",
+		Files:        []string{"docs/a.md", "docs/b.md"},
+		Providers:    []string{"openai"},
+		Reviewers:    []CommitAuthor{{Name: "ana", Email: "ana@example.com"}},
+	})
+	require.Equal(t, "Translate 2 files to es\n\n"+
+		"Files:\n- docs/a.md\n- docs/b.md\n\n"+
+		"Language: es\nSource-Commit: abc123\nTranslated-By: openai\nReviewed-By: ana <ana@example.com>\n", message)
+}
+
+func TestCommitAndPushTranslations(t *testing.T) {
+	source, worktree := languageWorktree(t)
+	remote := filepath.Join(t.TempDir(), "fork.git")
+	runGitCmd(t, filepath.Dir(remote), "init", "-q", "--bare", remote)
+
+	require.NoError(t, os.WriteFile(filepath.Join(worktree, "docs", "intro.md"), []byte("# Introducción\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(worktree, "docs", "old.md")))
+	sourceCommit := runGitCmd(t, source, "rev-parse", "HEAD")
+
+	hash, err := CommitTranslations(worktree, TranslationCommit{
+		Language:     "es",
+		SourceCommit: sourceCommit,
+		Files:        []string{"docs/old.md", "docs/intro.md"},
+		Providers:    []string{"stub"},
+		Reviewers:    []CommitAuthor{{Name: "ana", Email: "ana@example.com"}},
+	}, testCommitter)
+	require.NoError(t, err)
+	require.NotEmpty(t, hash)
+
+	// The commit is on the language branch only, attributed to the reviewer
+	require.Equal(t, hash, runGitCmd(t, source, "rev-parse", "xeodocs/es"))
+	require.NotEqual(t, hash, runGitCmd(t, source, "rev-parse", "HEAD"))
+	require.Equal(t, "ana <ana@example.com>|XeoDocs <bot@xeodocs.com>", runGitCmd(t, source, "log", "-1", "--format=%an <%ae>|%cn <%ce>", "xeodocs/es"))
+	require.Contains(t, runGitCmd(t, source, "log", "-1", "--format=%B", "xeodocs/es"), "Source-Commit: "+sourceCommit)
+	require.Equal(t, "M\tdocs/intro.md\nD\tdocs/old.md", runGitCmd(t, source, "diff", "--name-status", "HEAD", "xeodocs/es"))
+
+	// Nothing changed, nothing to commit
+	hash, err = CommitTranslations(worktree, TranslationCommit{Language: "es", Files: []string{"docs/intro.md"}}, testCommitter)
+	require.NoError(t, err)
+	require.Empty(t, hash)
+
+	require.NoError(t, PushBranch(worktree, remote, "xeodocs/es", nil))
+	require.Equal(t, runGitCmd(t, source, "rev-parse", "xeodocs/es"), runGitCmd(t, remote, "rev-parse", "xeodocs/es"))
+
+	// A changed push URL replaces the remote
+	other := filepath.Join(t.TempDir(), "other.git")
+	runGitCmd(t, filepath.Dir(other), "init", "-q", "--bare", other)
+	require.NoError(t, PushBranch(worktree, other, "xeodocs/es", nil))
+	require.Equal(t, other, runGitCmd(t, source, "remote", "get-url", "xeodocs"))
+	require.Equal(t, runGitCmd(t, source, "rev-parse", "xeodocs/es"), runGitCmd(t, other, "rev-parse", "xeodocs/es"))
+
+	_, err = CommitTranslations(worktree, TranslationCommit{Language: "es", Files: []string{"../escape.md"}}, testCommitter)
+	require.Error(t, err)
+}
@@ -0,0 +1,201 @@
+package project
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+)
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// GlossaryTerm is a term with a mandated translation for one language, or a
+// do-not-translate term. Do-not-translate terms with an empty language apply to all languages.
+type GlossaryTerm struct {
+	ID             int       `json:"id"`
+	ProjectID      int       `json:"project_id"`
+	Language       string    `json:"language"`
+	Term           string    `json:"term"`
+	Translation    string    `json:"translation"`
+	DoNotTranslate bool      `json:"do_not_translate"`
+	CaseSensitive  bool      `json:"case_sensitive"`
+	Notes          string    `json:"notes"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type CreateGlossaryTermRequest struct {
+	Language       string `json:"language"`
+	Term           string `json:"term"`
+	Translation    string `json:"translation"`
+	DoNotTranslate bool   `json:"do_not_translate"`
+	CaseSensitive  bool   `json:"case_sensitive"`
+	Notes          string `json:"notes"`
+}
+
+type UpdateGlossaryTermRequest struct {
+	Language       *string `json:"language,omitempty"`
+	Term           *string `json:"term,omitempty"`
+	Translation    *string `json:"translation,omitempty"`
+	DoNotTranslate *bool   `json:"do_not_translate,omitempty"`
+	CaseSensitive  *bool   `json:"case_sensitive,omitempty"`
+	Notes          *string `json:"notes,omitempty"`
+}
+
+// Expected returns the text a translation must contain for this term
+func (t GlossaryTerm) Expected() string {
+	if t.DoNotTranslate {
+		return t.Term
+	}
+	return t.Translation
+}
+
+// Validate checks that the term is usable for the given project
+func (t GlossaryTerm) Validate(project *Project) error {
+	if t.Term == "" {
+		return errors.New("term is required")
+	}
+	if !t.DoNotTranslate && t.Translation == "" {
+		return errors.New("translation is required unless do_not_translate is set")
+	}
+	if !t.DoNotTranslate && t.Language == "" {
+		return errors.New("language is required unless do_not_translate is set")
+	}
+	if t.Language != "" && !project.HasLanguage(t.Language) {
+		return errors.New("language is not configured for this project")
+	}
+	return nil
+}
+
+// HasLanguage reports whether the project translates into language
+func (p *Project) HasLanguage(language string) bool {
+	for _, lang := range p.Languages {
+		if lang == language {
+			return true
+		}
+	}
+	return false
+}
+
+func CreateGlossaryTerm(projectID int, req CreateGlossaryTermRequest) (*GlossaryTerm, error) {
+	term := &GlossaryTerm{
+		ProjectID:      projectID,
+		Language:       req.Language,
+		Term:           req.Term,
+		Translation:    req.Translation,
+		DoNotTranslate: req.DoNotTranslate,
+		CaseSensitive:  req.CaseSensitive,
+		Notes:          req.Notes,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	if term.DoNotTranslate {
+		term.Translation = ""
+	}
+
+	query := `INSERT INTO glossary_terms (project_id, language, term, translation, do_not_translate, case_sensitive, notes, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+	err := db.DB.QueryRow(query, term.ProjectID, term.Language, term.Term, term.Translation, term.DoNotTranslate, term.CaseSensitive, term.Notes, term.CreatedAt, term.UpdatedAt).Scan(&term.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.New("glossary term already exists")
+		}
+		return nil, err
+	}
+
+	return term, nil
+}
+
+// GetGlossaryTerms returns the terms of a project. A non-empty language also
+// includes do-not-translate terms that apply to every language.
+func GetGlossaryTerms(projectID int, language string) ([]GlossaryTerm, error) {
+	query := `SELECT id, project_id, language, term, translation, do_not_translate, case_sensitive, notes, created_at, updated_at FROM glossary_terms WHERE project_id = $1 AND ($2 = '' OR language = $2 OR language = '') ORDER BY term, language`
+	rows, err := db.DB.Query(query, projectID, language)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	terms := []GlossaryTerm{}
+	for rows.Next() {
+		var t GlossaryTerm
+		err := rows.Scan(&t.ID, &t.ProjectID, &t.Language, &t.Term, &t.Translation, &t.DoNotTranslate, &t.CaseSensitive, &t.Notes, &t.CreatedAt, &t.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+	return terms, rows.Err()
+}
+
+func GetGlossaryTermByID(projectID, id int) (*GlossaryTerm, error) {
+	t := &GlossaryTerm{}
+	query := `SELECT id, project_id, language, term, translation, do_not_translate, case_sensitive, notes, created_at, updated_at FROM glossary_terms WHERE project_id = $1 AND id = $2`
+	err := db.DB.QueryRow(query, projectID, id).Scan(&t.ID, &t.ProjectID, &t.Language, &t.Term, &t.Translation, &t.DoNotTranslate, &t.CaseSensitive, &t.Notes, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("glossary term not found")
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// ApplyGlossaryTermUpdate returns the term with the provided fields changed
+func ApplyGlossaryTermUpdate(t GlossaryTerm, req UpdateGlossaryTermRequest) GlossaryTerm {
+	if req.Language != nil {
+		t.Language = *req.Language
+	}
+	if req.Term != nil {
+		t.Term = *req.Term
+	}
+	if req.Translation != nil {
+		t.Translation = *req.Translation
+	}
+	if req.DoNotTranslate != nil {
+		t.DoNotTranslate = *req.DoNotTranslate
+	}
+	if req.CaseSensitive != nil {
+		t.CaseSensitive = *req.CaseSensitive
+	}
+	if req.Notes != nil {
+		t.Notes = *req.Notes
+	}
+	if t.DoNotTranslate {
+		t.Translation = ""
+	}
+	return t
+}
+
+func UpdateGlossaryTerm(t *GlossaryTerm) error {
+	t.UpdatedAt = time.Now()
+	query := `UPDATE glossary_terms SET language = $1, term = $2, translation = $3, do_not_translate = $4, case_sensitive = $5, notes = $6, updated_at = $7 WHERE project_id = $8 AND id = $9`
+	_, err := db.DB.Exec(query, t.Language, t.Term, t.Translation, t.DoNotTranslate, t.CaseSensitive, t.Notes, t.UpdatedAt, t.ProjectID, t.ID)
+	if isUniqueViolation(err) {
+		return errors.New("glossary term already exists")
+	}
+	return err
+}
+
+func DeleteGlossaryTerm(projectID, id int) error {
+	query := `DELETE FROM glossary_terms WHERE project_id = $1 AND id = $2`
+	result, err := db.DB.Exec(query, projectID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("glossary term not found")
+	}
+
+	return nil
+}
@@ -3,13 +3,19 @@ package project
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/xeodocs/xeodocs-backend/internal/shared/auth"
 	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
 	"github.com/xeodocs/xeodocs-backend/internal/shared/logging"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/queue"
 )
 
 // getUserIDFromContext extracts user ID from request context
@@ -51,6 +57,22 @@ func CreateProjectHandler(cfg *config.Config) http.HandlerFunc {
 			http.Error(w, "build_command is required", http.StatusBadRequest)
 			return
 		}
+		if err := ValidatePrompts(req.PromptTemplate, req.LanguagePrompts, req.Languages); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := req.LinkRewrite.Validate(req.Languages); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := ValidateSource(req.SourceRef, req.DocsRoot); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := (FileRules{Include: req.IncludePatterns, Exclude: req.ExcludePatterns}).Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		project, err := CreateProject(req)
 		if err != nil {
@@ -157,10 +179,18 @@ func UpdateProjectHandler(cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
+		previous, err := GetProjectByID(id)
+		if err != nil {
+			writeProjectLookupError(w, err)
+			return
+		}
+
 		project, err := UpdateProject(id, req)
 		if err != nil {
 			if err.Error() == "project not found" {
 				http.Error(w, "Project not found", http.StatusNotFound)
+			} else if errors.Is(err, ErrInvalidPromptTemplate) || errors.Is(err, ErrInvalidLinkRewrite) || errors.Is(err, ErrInvalidSource) || errors.Is(err, ErrInvalidFilePattern) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
 			} else {
 				log.Println("Error updating project:", err)
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -173,6 +203,14 @@ func UpdateProjectHandler(cfg *config.Config) http.HandlerFunc {
 		message := "Project updated: " + project.Name
 		logging.LogActivity(cfg.LoggingServiceURL, "project_updated", message, userID, &project.ID, "info")
 
+		// A new source is checked out by the next sync, which re-clones the repository
+		if project.RepoURL != previous.RepoURL || project.SourceRef != previous.SourceRef {
+			id := fmt.Sprintf("sync-%d-%d", project.ID, time.Now().Unix())
+			if err := queue.PublishTask(cfg, "sync_repo", "sync_repo", id, map[string]interface{}{"projectId": project.ID}); err != nil {
+				log.Println("Error enqueueing sync after source change:", err)
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(project)
 	}
@@ -211,3 +249,567 @@ func DeleteProjectHandler(cfg *config.Config) http.HandlerFunc {
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+// parseGlossaryPath extracts the project ID and optional term ID from
+// /projects/{id}/glossary and /projects/{id}/glossary/{termId}
+func parseGlossaryPath(path string) (projectID int, termID int, err error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/projects/"), "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] != "glossary" {
+		return 0, 0, errors.New("invalid glossary path")
+	}
+	if projectID, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, errors.New("invalid project ID")
+	}
+	if len(parts) == 3 {
+		if termID, err = strconv.Atoi(parts[2]); err != nil {
+			return 0, 0, errors.New("invalid glossary term ID")
+		}
+	}
+	return projectID, termID, nil
+}
+
+// writeProjectLookupError reports a failed project lookup
+func writeProjectLookupError(w http.ResponseWriter, err error) {
+	if err.Error() == "project not found" {
+		http.Error(w, "Project not found", http.StatusNotFound)
+	} else {
+		log.Println("Error getting project:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// ListGlossaryHandler handles GET /projects/{id}/glossary, optionally filtered by ?language=
+func ListGlossaryHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, _, err := parseGlossaryPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := GetProjectByID(projectID); err != nil {
+			writeProjectLookupError(w, err)
+			return
+		}
+
+		terms, err := GetGlossaryTerms(projectID, r.URL.Query().Get("language"))
+		if err != nil {
+			log.Println("Error getting glossary terms:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"terms": terms,
+			"total": len(terms),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CreateGlossaryTermHandler handles POST /projects/{id}/glossary
+func CreateGlossaryTermHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, _, err := parseGlossaryPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req CreateGlossaryTermRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		project, err := GetProjectByID(projectID)
+		if err != nil {
+			writeProjectLookupError(w, err)
+			return
+		}
+
+		candidate := GlossaryTerm{
+			Language:       req.Language,
+			Term:           req.Term,
+			Translation:    req.Translation,
+			DoNotTranslate: req.DoNotTranslate,
+		}
+		if err := candidate.Validate(project); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		term, err := CreateGlossaryTerm(projectID, req)
+		if err != nil {
+			if err.Error() == "glossary term already exists" {
+				http.Error(w, "Glossary term already exists", http.StatusConflict)
+			} else {
+				log.Println("Error creating glossary term:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// Log the glossary change
+		userID := getUserIDFromContext(r.Context())
+		message := "Glossary term created: " + term.Term
+		logging.LogActivity(cfg.LoggingServiceURL, "glossary_term_created", message, userID, &projectID, "info")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(term)
+	}
+}
+
+// UpdateGlossaryTermHandler handles PUT /projects/{id}/glossary/{termId}
+func UpdateGlossaryTermHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, termID, err := parseGlossaryPath(r.URL.Path)
+		if err != nil || termID == 0 {
+			http.Error(w, "Invalid glossary term ID", http.StatusBadRequest)
+			return
+		}
+
+		var req UpdateGlossaryTermRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		project, err := GetProjectByID(projectID)
+		if err != nil {
+			writeProjectLookupError(w, err)
+			return
+		}
+
+		existing, err := GetGlossaryTermByID(projectID, termID)
+		if err != nil {
+			if err.Error() == "glossary term not found" {
+				http.Error(w, "Glossary term not found", http.StatusNotFound)
+			} else {
+				log.Println("Error getting glossary term:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		term := ApplyGlossaryTermUpdate(*existing, req)
+		if err := term.Validate(project); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := UpdateGlossaryTerm(&term); err != nil {
+			if err.Error() == "glossary term already exists" {
+				http.Error(w, "Glossary term already exists", http.StatusConflict)
+			} else {
+				log.Println("Error updating glossary term:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// Log the glossary change
+		userID := getUserIDFromContext(r.Context())
+		message := "Glossary term updated: " + term.Term
+		logging.LogActivity(cfg.LoggingServiceURL, "glossary_term_updated", message, userID, &projectID, "info")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(term)
+	}
+}
+
+// DeleteGlossaryTermHandler handles DELETE /projects/{id}/glossary/{termId}
+func DeleteGlossaryTermHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, termID, err := parseGlossaryPath(r.URL.Path)
+		if err != nil || termID == 0 {
+			http.Error(w, "Invalid glossary term ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := DeleteGlossaryTerm(projectID, termID); err != nil {
+			if err.Error() == "glossary term not found" {
+				http.Error(w, "Glossary term not found", http.StatusNotFound)
+			} else {
+				log.Println("Error deleting glossary term:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// Log the glossary change
+		userID := getUserIDFromContext(r.Context())
+		message := "Glossary term deleted with ID: " + strconv.Itoa(termID)
+		logging.LogActivity(cfg.LoggingServiceURL, "glossary_term_deleted", message, userID, &projectID, "info")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parseCredentialsPath extracts the project ID from /projects/{id}/credentials
+func parseCredentialsPath(path string) (int, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/projects/"), "/"), "/")
+	if len(parts) != 2 || parts[1] != "credentials" {
+		return 0, errors.New("invalid credentials path")
+	}
+	projectID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, errors.New("invalid project ID")
+	}
+	return projectID, nil
+}
+
+// GetCredentialsHandler handles GET /projects/{id}/credentials. The secret is never returned.
+func GetCredentialsHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, err := parseCredentialsPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		info, err := GetCredentialsInfo(projectID)
+		if err != nil {
+			if err.Error() == "credentials not found" {
+				http.Error(w, "Credentials not found", http.StatusNotFound)
+			} else {
+				log.Println("Error getting credentials:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	}
+}
+
+// SetCredentialsHandler handles PUT /projects/{id}/credentials
+func SetCredentialsHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, err := parseCredentialsPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req Credentials
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := GetProjectByID(projectID); err != nil {
+			writeProjectLookupError(w, err)
+			return
+		}
+
+		info, err := SaveCredentials(projectID, req, cfg.CredentialsKey)
+		if err != nil {
+			log.Println("Error saving credentials:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Log the credentials change, without the secret
+		userID := getUserIDFromContext(r.Context())
+		message := "Repository credentials set: " + req.Type
+		logging.LogActivity(cfg.LoggingServiceURL, "project_credentials_set", message, userID, &projectID, "info")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	}
+}
+
+// DeleteCredentialsHandler handles DELETE /projects/{id}/credentials
+func DeleteCredentialsHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, err := parseCredentialsPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := DeleteCredentials(projectID); err != nil {
+			if err.Error() == "credentials not found" {
+				http.Error(w, "Credentials not found", http.StatusNotFound)
+			} else {
+				log.Println("Error deleting credentials:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// Log the credentials removal
+		userID := getUserIDFromContext(r.Context())
+		logging.LogActivity(cfg.LoggingServiceURL, "project_credentials_deleted", "Repository credentials deleted", userID, &projectID, "info")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parseWebhookPath extracts the project ID from /projects/{id}/webhook
+func parseWebhookPath(path string) (int, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/projects/"), "/"), "/")
+	if len(parts) != 2 || parts[1] != "webhook" {
+		return 0, errors.New("invalid webhook path")
+	}
+	projectID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, errors.New("invalid project ID")
+	}
+	return projectID, nil
+}
+
+// GetWebhookHandler handles GET /projects/{id}/webhook. The secret is never returned.
+func GetWebhookHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, err := parseWebhookPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		info, err := GetWebhookInfo(projectID)
+		if err != nil {
+			if err.Error() == "webhook not found" {
+				http.Error(w, "Webhook not found", http.StatusNotFound)
+			} else {
+				log.Println("Error getting webhook:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	}
+}
+
+// CreateWebhookHandler handles POST /projects/{id}/webhook. It generates a new
+// secret, replacing the previous one, and returns it once.
+func CreateWebhookHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, err := parseWebhookPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := GetProjectByID(projectID); err != nil {
+			writeProjectLookupError(w, err)
+			return
+		}
+
+		info, err := CreateWebhookSecret(projectID, cfg.CredentialsKey)
+		if err != nil {
+			log.Println("Error creating webhook secret:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Log the secret rotation, without the secret
+		userID := getUserIDFromContext(r.Context())
+		logging.LogActivity(cfg.LoggingServiceURL, "project_webhook_created", "Webhook secret generated", userID, &projectID, "info")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(info)
+	}
+}
+
+// DeleteWebhookHandler handles DELETE /projects/{id}/webhook
+func DeleteWebhookHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, err := parseWebhookPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := DeleteWebhook(projectID); err != nil {
+			if err.Error() == "webhook not found" {
+				http.Error(w, "Webhook not found", http.StatusNotFound)
+			} else {
+				log.Println("Error deleting webhook:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// Log the webhook removal
+		userID := getUserIDFromContext(r.Context())
+		logging.LogActivity(cfg.LoggingServiceURL, "project_webhook_deleted", "Webhook deleted", userID, &projectID, "info")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// maxWebhookBody caps the size of webhook payloads
+const maxWebhookBody = 10 << 20
+
+// WebhookResponse is returned by POST /webhooks/{provider}/{projectId}
+type WebhookResponse struct {
+	Status   string `json:"status"`
+	Projects []int  `json:"projects,omitempty"`
+}
+
+// parseReceiveWebhookPath extracts the provider and the project ID from
+// /webhooks/{provider}/{projectId}
+func parseReceiveWebhookPath(path string) (provider string, projectID int, err error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/webhooks/"), "/"), "/")
+	if len(parts) != 2 {
+		return "", 0, errors.New("invalid webhook path")
+	}
+	projectID, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return "", 0, errors.New("invalid project ID")
+	}
+	return parts[0], projectID, nil
+}
+
+// ReceiveWebhookHandler handles POST /webhooks/{provider}/{projectId} for GitHub,
+// GitLab and Gitea push events. The request must come from the project's
+// repository and be signed with its secret, so an unauthenticated caller only
+// ever costs one project lookup. The project is synced when it follows the
+// pushed ref. Each delivery ID is accepted once.
+func ReceiveWebhookHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		provider, projectID, err := parseReceiveWebhookPath(r.URL.Path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+		if err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		event, err := ParsePushEvent(provider, r.Header, body)
+		if err != nil {
+			if errors.Is(err, ErrUnknownWebhookProvider) {
+				http.NotFound(w, r)
+			} else {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+
+		// Unknown projects are rejected like bad signatures, so project IDs cannot be probed
+		p, err := GetProjectByID(projectID)
+		if err != nil && err.Error() != "project not found" {
+			log.Println("Error getting project:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		verified := false
+		if p != nil && event.MatchesRepository(p.RepoURL) {
+			secret, err := GetWebhookSecret(p.ID, cfg.CredentialsKey)
+			if err != nil {
+				log.Println("Error getting webhook secret:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			verified = secret != "" && VerifyWebhookSignature(provider, r.Header, body, secret)
+		}
+		if !verified {
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		fresh, err := RecordDelivery(provider, event.DeliveryID)
+		if err != nil {
+			log.Println("Error recording webhook delivery:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		resp := WebhookResponse{Status: "ignored"}
+		if !fresh {
+			resp.Status = "duplicate"
+		} else if event.Push && event.MatchesRef(p.SourceRef) {
+			id := fmt.Sprintf("sync-%d-%s", p.ID, event.DeliveryID)
+			if err := queue.PublishTask(cfg, "sync_repo", "sync_repo", id, map[string]interface{}{"projectId": p.ID}); err != nil {
+				log.Println("Error enqueueing sync from webhook:", err)
+				// The provider redelivers on errors; forget the delivery so the
+				// redelivery is queued instead of being answered as a duplicate
+				if err := ForgetDelivery(provider, event.DeliveryID); err != nil {
+					log.Println("Error forgetting webhook delivery:", err)
+				}
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			message := fmt.Sprintf("Sync queued by %s push to %s", provider, event.Ref)
+			logging.LogActivity(cfg.LoggingServiceURL, "project_webhook_received", message, nil, &p.ID, "info")
+			resp.Status = "queued"
+			resp.Projects = []int{p.ID}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if resp.Status == "queued" {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,208 @@
+package project
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Link styles describe how a doc site generator lays out translated pages
+const (
+	LinkStyleDocusaurus = "docusaurus"
+	LinkStyleMkDocs     = "mkdocs"
+	LinkStyleHugo       = "hugo"
+	LinkStyleNone       = "none"
+)
+
+// ErrInvalidLinkRewrite is returned when link rewrite settings are not valid
+var ErrInvalidLinkRewrite = errors.New("invalid link_rewrite")
+
+// LinkRewrite configures how links to pages of the doc site are rewritten in
+// translated copies. Empty fields use the defaults of the style.
+type LinkRewrite struct {
+	// Style is docusaurus (the default), mkdocs, hugo or none
+	Style string `json:"style,omitempty"`
+	// PathPrefix is inserted after the DocURL path, e.g. "/{locale}"
+	PathPrefix string `json:"path_prefix,omitempty"`
+	// Locales maps language codes to the locale used in URLs, e.g. "pt" to "pt-BR"
+	Locales map[string]string `json:"locales,omitempty"`
+	// Exclude lists path prefixes, relative to the DocURL path, that are never rewritten
+	Exclude []string `json:"exclude,omitempty"`
+	// RootRelative controls whether links such as /docs/intro are rewritten in
+	// addition to full URLs on the DocURL host
+	RootRelative *bool `json:"root_relative,omitempty"`
+}
+
+// linkStyle holds the defaults of a link style
+type linkStyle struct {
+	exclude        []string
+	pageExtensions []string
+	rootRelative   bool
+	lowerLocale    bool
+}
+
+// linkStyles are the supported generators. Docusaurus already adds the locale to
+// root-relative links of a localized build, so only full URLs are rewritten.
+// Hugo uses lowercase language keys in URLs.
+var linkStyles = map[string]linkStyle{
+	LinkStyleDocusaurus: {exclude: []string{"/img/", "/assets/"}, pageExtensions: []string{".md", ".mdx", ".html"}},
+	LinkStyleMkDocs:     {exclude: []string{"/assets/", "/images/", "/img/"}, pageExtensions: []string{".md", ".html"}, rootRelative: true},
+	LinkStyleHugo:       {exclude: []string{"/images/", "/css/", "/js/"}, pageExtensions: []string{".html"}, rootRelative: true, lowerLocale: true},
+}
+
+// Value implements driver.Valuer for JSONB
+func (l LinkRewrite) Value() (driver.Value, error) {
+	return json.Marshal(l)
+}
+
+// Scan implements sql.Scanner for JSONB
+func (l *LinkRewrite) Scan(value interface{}) error {
+	if value == nil {
+		*l = LinkRewrite{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, l)
+}
+
+// Validate checks the style, the prefix and that locales are given for project languages
+func (l LinkRewrite) Validate(languages Languages) error {
+	if _, ok := linkStyles[l.Style]; !ok && l.Style != "" && l.Style != LinkStyleNone {
+		return fmt.Errorf("%w: unknown style %q", ErrInvalidLinkRewrite, l.Style)
+	}
+	if l.PathPrefix != "" && (!strings.HasPrefix(l.PathPrefix, "/") || !strings.Contains(l.PathPrefix, "{locale}")) {
+		return fmt.Errorf("%w: path_prefix must start with / and contain {locale}", ErrInvalidLinkRewrite)
+	}
+	for language, locale := range l.Locales {
+		if !(&Project{Languages: languages}).HasLanguage(language) {
+			return fmt.Errorf("%w: locales: %s is not configured for this project", ErrInvalidLinkRewrite, language)
+		}
+		if locale == "" || strings.ContainsAny(locale, "/?#") {
+			return fmt.Errorf("%w: locales: invalid locale %q for %s", ErrInvalidLinkRewrite, locale, language)
+		}
+	}
+	for _, prefix := range l.Exclude {
+		if !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("%w: exclude entries must start with /", ErrInvalidLinkRewrite)
+		}
+	}
+	return nil
+}
+
+// LinkRules are the resolved link rewrite settings of a project for one language
+type LinkRules struct {
+	Host           string
+	BasePath       string
+	Prefix         string
+	Exclude        []string
+	PageExtensions []string
+	RootRelative   bool
+}
+
+// LinkRulesFor resolves the link rewrite settings for a language. It reports false
+// when links are not rewritten: the style is none or DocURL has no host.
+func (p *Project) LinkRulesFor(language string) (LinkRules, bool) {
+	rewrite := p.LinkRewrite
+	if rewrite.Style == LinkStyleNone {
+		return LinkRules{}, false
+	}
+	style, ok := linkStyles[rewrite.Style]
+	if !ok {
+		style = linkStyles[LinkStyleDocusaurus]
+	}
+
+	site, err := url.Parse(p.DocURL)
+	if err != nil || site.Host == "" {
+		return LinkRules{}, false
+	}
+
+	locale := rewrite.Locales[language]
+	if locale == "" {
+		locale = language
+		if style.lowerLocale {
+			locale = strings.ToLower(locale)
+		}
+	}
+	prefix := rewrite.PathPrefix
+	if prefix == "" {
+		prefix = "/{locale}"
+	}
+
+	rules := LinkRules{
+		Host:           strings.ToLower(site.Host),
+		BasePath:       strings.TrimSuffix(site.EscapedPath(), "/"),
+		Prefix:         strings.TrimSuffix(strings.ReplaceAll(prefix, "{locale}", locale), "/"),
+		Exclude:        style.exclude,
+		PageExtensions: style.pageExtensions,
+		RootRelative:   style.rootRelative,
+	}
+	if len(rewrite.Exclude) > 0 {
+		rules.Exclude = rewrite.Exclude
+	}
+	if rewrite.RootRelative != nil {
+		rules.RootRelative = *rewrite.RootRelative
+	}
+	return rules, true
+}
+
+// Rewrite returns the language-prefixed equivalent of a link to a page of the doc
+// site. Links to other sites, assets, excluded paths, already localized paths and
+// document-relative links, which resolve inside the language copy, are returned unchanged.
+func (r LinkRules) Rewrite(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Opaque != "" || u.User != nil {
+		return target
+	}
+
+	var pathStart int
+	switch {
+	case u.Scheme == "http" || u.Scheme == "https":
+		if !strings.EqualFold(u.Host, r.Host) {
+			return target
+		}
+		pathStart = len(u.Scheme) + len("://") + len(u.Host)
+	case u.Scheme == "" && u.Host == "" && strings.HasPrefix(target, "/") && r.RootRelative:
+		pathStart = 0
+	default:
+		return target
+	}
+
+	escaped := u.EscapedPath()
+	if !strings.HasPrefix(target[pathStart:], escaped) {
+		return target
+	}
+	rest, ok := strings.CutPrefix(escaped, r.BasePath)
+	if !ok || rest != "" && !strings.HasPrefix(rest, "/") {
+		return target
+	}
+	if rest == r.Prefix || strings.HasPrefix(rest, r.Prefix+"/") {
+		return target
+	}
+	for _, prefix := range r.Exclude {
+		if strings.HasPrefix(rest+"/", prefix) {
+			return target
+		}
+	}
+	if ext := path.Ext(rest); ext != "" && !strings.HasSuffix(rest, "/") && !r.isPageExtension(ext) {
+		return target
+	}
+
+	insert := pathStart + len(r.BasePath)
+	return target[:insert] + r.Prefix + target[insert:]
+}
+
+func (r LinkRules) isPageExtension(ext string) bool {
+	for _, page := range r.PageExtensions {
+		if strings.EqualFold(ext, page) {
+			return true
+		}
+	}
+	return false
+}
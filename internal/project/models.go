@@ -21,8 +21,22 @@ type Project struct {
 	BuildCommand   string    `json:"build_command"`
 	ExportCommand  string    `json:"export_command"`
 	PreviewCommand string    `json:"preview_command"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	// SourceRef is the branch, tag or full reference to translate; empty uses the default branch
+	SourceRef string `json:"source_ref"`
+	// DocsRoot restricts translation and syncing to a subdirectory such as docs; empty is the whole repository
+	DocsRoot string `json:"docs_root"`
+	// IncludePatterns and ExcludePatterns select the translated files, see FileRules
+	IncludePatterns Patterns `json:"include_patterns"`
+	ExcludePatterns Patterns `json:"exclude_patterns"`
+	// PromptTemplate and LanguagePrompts customize the translation prompt; empty uses DefaultPromptTemplate
+	PromptTemplate  string          `json:"prompt_template"`
+	LanguagePrompts PromptTemplates `json:"language_prompts"`
+	// LinkRewrite controls how links to DocURL pages are localized in translated copies
+	LinkRewrite LinkRewrite `json:"link_rewrite"`
+	// PushRemoteURL receives the language branches after each commit; empty keeps them local
+	PushRemoteURL string    `json:"push_remote_url"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 // Value implements driver.Valuer for JSONB
@@ -44,13 +58,21 @@ func (l *Languages) Scan(value interface{}) error {
 }
 
 type CreateProjectRequest struct {
-	Name           string    `json:"name"`
-	DocURL         string    `json:"doc_url"`
-	RepoURL        string    `json:"repo_url"`
-	Languages      Languages `json:"languages"`
-	BuildCommand   string    `json:"build_command"`
-	ExportCommand  string    `json:"export_command"`
-	PreviewCommand string    `json:"preview_command"`
+	Name            string          `json:"name"`
+	DocURL          string          `json:"doc_url"`
+	RepoURL         string          `json:"repo_url"`
+	Languages       Languages       `json:"languages"`
+	BuildCommand    string          `json:"build_command"`
+	ExportCommand   string          `json:"export_command"`
+	PreviewCommand  string          `json:"preview_command"`
+	SourceRef       string          `json:"source_ref"`
+	DocsRoot        string          `json:"docs_root"`
+	IncludePatterns Patterns        `json:"include_patterns"`
+	ExcludePatterns Patterns        `json:"exclude_patterns"`
+	PromptTemplate  string          `json:"prompt_template"`
+	LanguagePrompts PromptTemplates `json:"language_prompts"`
+	LinkRewrite     LinkRewrite     `json:"link_rewrite"`
+	PushRemoteURL   string          `json:"push_remote_url"`
 }
 
 type UpdateProjectRequest struct {
@@ -61,23 +83,67 @@ type UpdateProjectRequest struct {
 	BuildCommand   *string   `json:"build_command,omitempty"`
 	ExportCommand  *string   `json:"export_command,omitempty"`
 	PreviewCommand *string   `json:"preview_command,omitempty"`
+	SourceRef      *string   `json:"source_ref,omitempty"`
+	DocsRoot       *string   `json:"docs_root,omitempty"`
+	// IncludePatterns and ExcludePatterns replace the project's patterns when present
+	IncludePatterns Patterns `json:"include_patterns,omitempty"`
+	ExcludePatterns Patterns `json:"exclude_patterns,omitempty"`
+	PromptTemplate  *string  `json:"prompt_template,omitempty"`
+	// LanguagePrompts replaces all per-language templates when present
+	LanguagePrompts PromptTemplates `json:"language_prompts,omitempty"`
+	LinkRewrite     *LinkRewrite    `json:"link_rewrite,omitempty"`
+	PushRemoteURL   *string         `json:"push_remote_url,omitempty"`
+}
+
+// projectColumns lists the columns read by scanProject, in order
+const projectColumns = `id, name, doc_url, repo_url, languages, build_command, export_command, preview_command, source_ref, docs_root, include_patterns, exclude_patterns, prompt_template, language_prompts, link_rewrite, push_remote_url, created_at, updated_at`
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProject(row scanner) (*Project, error) {
+	p := &Project{}
+	err := row.Scan(&p.ID, &p.Name, &p.DocURL, &p.RepoURL, &p.Languages, &p.BuildCommand, &p.ExportCommand, &p.PreviewCommand, &p.SourceRef, &p.DocsRoot, &p.IncludePatterns, &p.ExcludePatterns, &p.PromptTemplate, &p.LanguagePrompts, &p.LinkRewrite, &p.PushRemoteURL, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
 func CreateProject(req CreateProjectRequest) (*Project, error) {
 	project := &Project{
-		Name:           req.Name,
-		DocURL:         req.DocURL,
-		RepoURL:        req.RepoURL,
-		Languages:      req.Languages,
-		BuildCommand:   req.BuildCommand,
-		ExportCommand:  req.ExportCommand,
-		PreviewCommand: req.PreviewCommand,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
-	}
-
-	query := `INSERT INTO projects (name, doc_url, repo_url, languages, build_command, export_command, preview_command, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
-	err := db.DB.QueryRow(query, project.Name, project.DocURL, project.RepoURL, project.Languages, project.BuildCommand, project.ExportCommand, project.PreviewCommand, project.CreatedAt, project.UpdatedAt).Scan(&project.ID)
+		Name:            req.Name,
+		DocURL:          req.DocURL,
+		RepoURL:         req.RepoURL,
+		Languages:       req.Languages,
+		BuildCommand:    req.BuildCommand,
+		ExportCommand:   req.ExportCommand,
+		PreviewCommand:  req.PreviewCommand,
+		SourceRef:       req.SourceRef,
+		DocsRoot:        NormalizeDocsRoot(req.DocsRoot),
+		IncludePatterns: req.IncludePatterns,
+		ExcludePatterns: req.ExcludePatterns,
+		PromptTemplate:  req.PromptTemplate,
+		LanguagePrompts: req.LanguagePrompts,
+		LinkRewrite:     req.LinkRewrite,
+		PushRemoteURL:   req.PushRemoteURL,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+	if project.LanguagePrompts == nil {
+		project.LanguagePrompts = PromptTemplates{}
+	}
+	if project.IncludePatterns == nil {
+		project.IncludePatterns = Patterns{}
+	}
+	if project.ExcludePatterns == nil {
+		project.ExcludePatterns = Patterns{}
+	}
+
+	query := `INSERT INTO projects (name, doc_url, repo_url, languages, build_command, export_command, preview_command, source_ref, docs_root, include_patterns, exclude_patterns, prompt_template, language_prompts, link_rewrite, push_remote_url, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) RETURNING id`
+	err := db.DB.QueryRow(query, project.Name, project.DocURL, project.RepoURL, project.Languages, project.BuildCommand, project.ExportCommand, project.PreviewCommand, project.SourceRef, project.DocsRoot, project.IncludePatterns, project.ExcludePatterns, project.PromptTemplate, project.LanguagePrompts, project.LinkRewrite, project.PushRemoteURL, project.CreatedAt, project.UpdatedAt).Scan(&project.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +152,7 @@ func CreateProject(req CreateProjectRequest) (*Project, error) {
 }
 
 func GetProjects() ([]Project, error) {
-	query := `SELECT id, name, doc_url, repo_url, languages, build_command, export_command, preview_command, created_at, updated_at FROM projects ORDER BY created_at DESC`
+	query := `SELECT ` + projectColumns + ` FROM projects ORDER BY created_at DESC`
 	rows, err := db.DB.Query(query)
 	if err != nil {
 		return nil, err
@@ -95,21 +161,18 @@ func GetProjects() ([]Project, error) {
 
 	var projects []Project
 	for rows.Next() {
-		var p Project
-		err := rows.Scan(&p.ID, &p.Name, &p.DocURL, &p.RepoURL, &p.Languages, &p.BuildCommand, &p.ExportCommand, &p.PreviewCommand, &p.CreatedAt, &p.UpdatedAt)
+		p, err := scanProject(rows)
 		if err != nil {
 			return nil, err
 		}
-		projects = append(projects, p)
+		projects = append(projects, *p)
 	}
 	return projects, nil
 }
 
 func GetProjectByID(id int) (*Project, error) {
-	project := &Project{}
-	query := `SELECT id, name, doc_url, repo_url, languages, build_command, export_command, preview_command, created_at, updated_at FROM projects WHERE id = $1`
-	row := db.DB.QueryRow(query, id)
-	err := row.Scan(&project.ID, &project.Name, &project.DocURL, &project.RepoURL, &project.Languages, &project.BuildCommand, &project.ExportCommand, &project.PreviewCommand, &project.CreatedAt, &project.UpdatedAt)
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE id = $1`
+	project, err := scanProject(db.DB.QueryRow(query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, errors.New("project not found")
@@ -148,10 +211,49 @@ func UpdateProject(id int, req UpdateProjectRequest) (*Project, error) {
 	if req.PreviewCommand != nil {
 		project.PreviewCommand = *req.PreviewCommand
 	}
+	if req.SourceRef != nil {
+		project.SourceRef = *req.SourceRef
+	}
+	if req.DocsRoot != nil {
+		if err := ValidateSource("", *req.DocsRoot); err != nil {
+			return nil, err
+		}
+		project.DocsRoot = NormalizeDocsRoot(*req.DocsRoot)
+	}
+	if req.IncludePatterns != nil {
+		project.IncludePatterns = req.IncludePatterns
+	}
+	if req.ExcludePatterns != nil {
+		project.ExcludePatterns = req.ExcludePatterns
+	}
+	if req.PromptTemplate != nil {
+		project.PromptTemplate = *req.PromptTemplate
+	}
+	if req.LanguagePrompts != nil {
+		project.LanguagePrompts = req.LanguagePrompts
+	}
+	if req.LinkRewrite != nil {
+		project.LinkRewrite = *req.LinkRewrite
+	}
+	if req.PushRemoteURL != nil {
+		project.PushRemoteURL = *req.PushRemoteURL
+	}
+	if err := ValidatePrompts(project.PromptTemplate, project.LanguagePrompts, project.Languages); err != nil {
+		return nil, err
+	}
+	if err := project.LinkRewrite.Validate(project.Languages); err != nil {
+		return nil, err
+	}
+	if err := ValidateSource(project.SourceRef, project.DocsRoot); err != nil {
+		return nil, err
+	}
+	if err := project.FileRules().Validate(); err != nil {
+		return nil, err
+	}
 	project.UpdatedAt = time.Now()
 
-	query := `UPDATE projects SET name = $1, doc_url = $2, repo_url = $3, languages = $4, build_command = $5, export_command = $6, preview_command = $7, updated_at = $8 WHERE id = $9`
-	_, err = db.DB.Exec(query, project.Name, project.DocURL, project.RepoURL, project.Languages, project.BuildCommand, project.ExportCommand, project.PreviewCommand, project.UpdatedAt, id)
+	query := `UPDATE projects SET name = $1, doc_url = $2, repo_url = $3, languages = $4, build_command = $5, export_command = $6, preview_command = $7, source_ref = $8, docs_root = $9, include_patterns = $10, exclude_patterns = $11, prompt_template = $12, language_prompts = $13, link_rewrite = $14, push_remote_url = $15, updated_at = $16 WHERE id = $17`
+	_, err = db.DB.Exec(query, project.Name, project.DocURL, project.RepoURL, project.Languages, project.BuildCommand, project.ExportCommand, project.PreviewCommand, project.SourceRef, project.DocsRoot, project.IncludePatterns, project.ExcludePatterns, project.PromptTemplate, project.LanguagePrompts, project.LinkRewrite, project.PushRemoteURL, project.UpdatedAt, id)
 	if err != nil {
 		return nil, err
 	}
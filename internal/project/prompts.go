@@ -0,0 +1,115 @@
+package project
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// ErrInvalidPromptTemplate is returned when a prompt template does not parse or render
+var ErrInvalidPromptTemplate = errors.New("invalid prompt template")
+
+// DefaultPromptTemplate is used by projects and languages without their own template.
+// The reply format instructions are always added after the rendered template.
+const DefaultPromptTemplate = `You are a professional technical documentation translator.
+Translate each string in the JSON array you receive from {{.SourceLanguageName}} to {{.TargetLanguageName}}.
+{{- if .FilePath}}
+The strings come from the file {{.FilePath}}.
+{{- end}}
+{{- if .Context}}
+
+Context of these strings:
+{{.Context}}
+{{- end}}
+{{- .References}}
+{{- .Glossary}}`
+
+// PromptTemplates maps language codes to the prompt template used for them
+type PromptTemplates map[string]string
+
+// Value implements driver.Valuer for JSONB
+func (t PromptTemplates) Value() (driver.Value, error) {
+	if t == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(t)
+}
+
+// Scan implements sql.Scanner for JSONB
+func (t *PromptTemplates) Scan(value interface{}) error {
+	if value == nil {
+		*t = PromptTemplates{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, t)
+}
+
+// PromptVariables are the values available to prompt templates. Glossary and
+// References are ready-made instructions, empty when there is nothing to add.
+type PromptVariables struct {
+	SourceLanguage     string
+	TargetLanguage     string
+	SourceLanguageName string
+	TargetLanguageName string
+	FilePath           string
+	Context            string
+	Glossary           string
+	References         string
+}
+
+// PromptTemplateFor returns the template for a language: the language's own
+// template, else the project's, else DefaultPromptTemplate
+func (p *Project) PromptTemplateFor(language string) string {
+	if tmpl := p.LanguagePrompts[language]; strings.TrimSpace(tmpl) != "" {
+		return tmpl
+	}
+	if strings.TrimSpace(p.PromptTemplate) != "" {
+		return p.PromptTemplate
+	}
+	return DefaultPromptTemplate
+}
+
+// RenderPrompt executes a prompt template. Unknown variables are errors.
+func RenderPrompt(text string, vars PromptVariables) (string, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, vars); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// ValidatePrompts checks that every template renders and that per-language
+// templates target languages of the project
+func ValidatePrompts(defaultTemplate string, languagePrompts PromptTemplates, languages Languages) error {
+	sample := PromptVariables{
+		SourceLanguage:     "en",
+		TargetLanguage:     "es",
+		SourceLanguageName: "English",
+		TargetLanguageName: "Spanish",
+		FilePath:           "docs/intro.md",
+		Context:            "Document title: Introduction",
+	}
+	if _, err := RenderPrompt(defaultTemplate, sample); err != nil {
+		return fmt.Errorf("prompt_template: %w", err)
+	}
+	for language, tmpl := range languagePrompts {
+		if !(&Project{Languages: languages}).HasLanguage(language) {
+			return fmt.Errorf("%w: language_prompts: %s is not configured for this project", ErrInvalidPromptTemplate, language)
+		}
+		if _, err := RenderPrompt(tmpl, sample); err != nil {
+			return fmt.Errorf("language_prompts.%s: %w", language, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// ErrInvalidSource is returned for an unusable source ref or docs root
+var ErrInvalidSource = errors.New("invalid source")
+
+// NormalizeDocsRoot cleans a docs root such as "/docs/" to "docs"; the repository root is ""
+func NormalizeDocsRoot(root string) string {
+	return strings.Trim(path.Clean("/"+strings.TrimSpace(root)), "/")
+}
+
+// ValidateSource checks the source ref and docs root of a project
+func ValidateSource(ref, docsRoot string) error {
+	if ref != "" {
+		name := plumbing.ReferenceName(ref)
+		if !strings.HasPrefix(ref, "refs/") {
+			name = plumbing.NewBranchReferenceName(ref)
+		}
+		if err := name.Validate(); err != nil {
+			return fmt.Errorf("%w: source_ref %q is not a valid branch, tag or reference", ErrInvalidSource, ref)
+		}
+	}
+	for _, segment := range strings.Split(strings.TrimSpace(docsRoot), "/") {
+		if segment == ".." || segment == ".git" {
+			return fmt.Errorf("%w: docs_root %q must be a directory inside the repository", ErrInvalidSource, docsRoot)
+		}
+	}
+	return nil
+}
+
+// InDocsRoot reports whether a repository path is inside the project's docs root
+func (p *Project) InDocsRoot(file string) bool {
+	return p.DocsRoot == "" || strings.HasPrefix(file, p.DocsRoot+"/")
+}
@@ -0,0 +1,35 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSource(t *testing.T) {
+	for _, ref := range []string{"", "main", "release/2.x", "v1.2.0", "refs/tags/v1.2.0"} {
+		require.NoError(t, ValidateSource(ref, ""), ref)
+	}
+	for _, ref := range []string{"bad..ref", "has space", "refs/heads/"} {
+		require.ErrorIs(t, ValidateSource(ref, ""), ErrInvalidSource, ref)
+	}
+
+	for _, root := range []string{"", "docs", "/website/docs/", "./docs"} {
+		require.NoError(t, ValidateSource("", root), root)
+	}
+	for _, root := range []string{"..", "docs/../../etc", ".git", "docs/.git/hooks"} {
+		require.ErrorIs(t, ValidateSource("", root), ErrInvalidSource, root)
+	}
+}
+
+func TestDocsRoot(t *testing.T) {
+	require.Equal(t, "website/docs", NormalizeDocsRoot(" /website/docs/ "))
+	require.Equal(t, "docs", NormalizeDocsRoot("./docs"))
+	require.Equal(t, "", NormalizeDocsRoot("/"))
+
+	p := &Project{DocsRoot: "docs"}
+	require.True(t, p.InDocsRoot("docs/intro.md"))
+	require.False(t, p.InDocsRoot("docs-old/intro.md"))
+	require.False(t, p.InDocsRoot("README.md"))
+	require.True(t, (&Project{}).InDocsRoot("README.md"))
+}
@@ -0,0 +1,261 @@
+package project
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+)
+
+// Webhook providers
+const (
+	WebhookGitHub = "github"
+	WebhookGitLab = "gitlab"
+	WebhookGitea  = "gitea"
+)
+
+// deliveryRetention is how long delivery IDs are kept for replay protection
+const deliveryRetention = 7 * 24 * time.Hour
+
+// ErrUnknownWebhookProvider is returned for providers other than GitHub, GitLab and Gitea
+var ErrUnknownWebhookProvider = errors.New("unknown webhook provider")
+
+// PushEvent is the part of a push webhook needed to find and sync projects
+type PushEvent struct {
+	Provider   string
+	DeliveryID string
+	// Push is false for pings and other events, which are acknowledged and ignored
+	Push          bool
+	Ref           string
+	DefaultBranch string
+	RepoURLs      []string
+}
+
+// WebhookInfo describes the webhook of a project. Secret is only set when it is generated.
+type WebhookInfo struct {
+	ProjectID int       `json:"project_id"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// pushPayload holds the repository fields of GitHub, GitLab and Gitea push payloads
+type pushPayload struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		CloneURL      string `json:"clone_url"`
+		HTMLURL       string `json:"html_url"`
+		SSHURL        string `json:"ssh_url"`
+		GitHTTPURL    string `json:"git_http_url"`
+		GitSSHURL     string `json:"git_ssh_url"`
+		Homepage      string `json:"homepage"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"repository"`
+	// Project is sent by GitLab only
+	Project struct {
+		GitHTTPURL    string `json:"git_http_url"`
+		GitSSHURL     string `json:"git_ssh_url"`
+		WebURL        string `json:"web_url"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"project"`
+}
+
+// ParsePushEvent reads the delivery ID, event type and push payload of a webhook request
+func ParsePushEvent(provider string, header http.Header, body []byte) (*PushEvent, error) {
+	event := &PushEvent{Provider: provider}
+	switch provider {
+	case WebhookGitHub:
+		event.DeliveryID = header.Get("X-GitHub-Delivery")
+		event.Push = header.Get("X-GitHub-Event") == "push"
+	case WebhookGitLab:
+		event.DeliveryID = header.Get("X-Gitlab-Event-UUID")
+		kind := header.Get("X-Gitlab-Event")
+		event.Push = kind == "Push Hook" || kind == "Tag Push Hook"
+	case WebhookGitea:
+		event.DeliveryID = header.Get("X-Gitea-Delivery")
+		event.Push = header.Get("X-Gitea-Event") == "push"
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownWebhookProvider, provider)
+	}
+	if event.DeliveryID == "" {
+		return nil, errors.New("missing delivery ID")
+	}
+
+	var payload pushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	event.Ref = payload.Ref
+	event.DefaultBranch = payload.Repository.DefaultBranch
+	if event.DefaultBranch == "" {
+		event.DefaultBranch = payload.Project.DefaultBranch
+	}
+	for _, u := range []string{
+		payload.Repository.CloneURL, payload.Repository.HTMLURL, payload.Repository.SSHURL,
+		payload.Repository.GitHTTPURL, payload.Repository.GitSSHURL, payload.Repository.Homepage,
+		payload.Project.GitHTTPURL, payload.Project.GitSSHURL, payload.Project.WebURL,
+	} {
+		if u != "" {
+			event.RepoURLs = append(event.RepoURLs, u)
+		}
+	}
+	return event, nil
+}
+
+// VerifyWebhookSignature checks a request against the project's secret: an HMAC-SHA256
+// of the body for GitHub and Gitea, and the shared token that GitLab sends
+func VerifyWebhookSignature(provider string, header http.Header, body []byte, secret string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	switch provider {
+	case WebhookGitHub:
+		return hmac.Equal([]byte(header.Get("X-Hub-Signature-256")), []byte("sha256="+expected))
+	case WebhookGitea:
+		return hmac.Equal([]byte(header.Get("X-Gitea-Signature")), []byte(expected))
+	case WebhookGitLab:
+		token := header.Get("X-Gitlab-Token")
+		return token != "" && hmac.Equal([]byte(token), []byte(secret))
+	}
+	return false
+}
+
+// NormalizeRepoURL reduces HTTPS, SSH and scp-style clone URLs of a repository to
+// the same host/path form, e.g. github.com/example/docs
+func NormalizeRepoURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		// scp-style git@github.com:example/docs.git
+		if at := strings.Index(raw, "@"); at >= 0 {
+			raw = raw[at+1:]
+		}
+		raw = "ssh://" + strings.Replace(raw, ":", "/", 1)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	p := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	return strings.ToLower(u.Hostname()) + "/" + strings.ToLower(p)
+}
+
+// MatchesRepository reports whether the event comes from the project's repository
+func (e *PushEvent) MatchesRepository(repoURL string) bool {
+	want := NormalizeRepoURL(repoURL)
+	for _, u := range e.RepoURLs {
+		if NormalizeRepoURL(u) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesRef reports whether the push updated the branch or tag the project translates
+func (e *PushEvent) MatchesRef(sourceRef string) bool {
+	if sourceRef == "" {
+		return e.DefaultBranch != "" && e.Ref == "refs/heads/"+e.DefaultBranch
+	}
+	return e.Ref == sourceRef || e.Ref == "refs/heads/"+sourceRef || e.Ref == "refs/tags/"+sourceRef
+}
+
+// CreateWebhookSecret generates a new webhook secret for a project, replacing any existing one
+func CreateWebhookSecret(projectID int, secretKey string) (*WebhookInfo, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	info := &WebhookInfo{ProjectID: projectID, Secret: hex.EncodeToString(raw)}
+	sealed, err := encryptSecret(secretKey, []byte(info.Secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt webhook secret: %w", err)
+	}
+
+	query := `INSERT INTO project_webhooks (project_id, secret, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (project_id) DO UPDATE SET secret = EXCLUDED.secret, created_at = EXCLUDED.created_at
+		RETURNING created_at`
+	if err := db.DB.QueryRow(query, projectID, sealed, time.Now()).Scan(&info.CreatedAt); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// GetWebhookInfo returns when the webhook secret of a project was created, without the secret
+func GetWebhookInfo(projectID int) (*WebhookInfo, error) {
+	info := &WebhookInfo{ProjectID: projectID}
+	err := db.DB.QueryRow(`SELECT created_at FROM project_webhooks WHERE project_id = $1`, projectID).Scan(&info.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("webhook not found")
+		}
+		return nil, err
+	}
+	return info, nil
+}
+
+// GetWebhookSecret returns the decrypted webhook secret of a project, or "" if it has none
+func GetWebhookSecret(projectID int, secretKey string) (string, error) {
+	var sealed []byte
+	err := db.DB.QueryRow(`SELECT secret FROM project_webhooks WHERE project_id = $1`, projectID).Scan(&sealed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+	secret, err := decryptSecret(secretKey, sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt webhook secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+// DeleteWebhook removes the webhook secret of a project, so its deliveries are rejected
+func DeleteWebhook(projectID int) error {
+	result, err := db.DB.Exec(`DELETE FROM project_webhooks WHERE project_id = $1`, projectID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("webhook not found")
+	}
+	return nil
+}
+
+// RecordDelivery stores a delivery ID and reports whether it was new. Replayed
+// deliveries return false. IDs older than deliveryRetention are forgotten.
+func RecordDelivery(provider, deliveryID string) (bool, error) {
+	now := time.Now()
+	if _, err := db.DB.Exec(`DELETE FROM webhook_deliveries WHERE received_at < $1`, now.Add(-deliveryRetention)); err != nil {
+		return false, err
+	}
+	result, err := db.DB.Exec(`INSERT INTO webhook_deliveries (provider, delivery_id, received_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, provider, deliveryID, now)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// ForgetDelivery removes a recorded delivery ID, so a redelivery of a push that
+// could not be queued is processed again instead of being answered as a duplicate
+func ForgetDelivery(provider, deliveryID string) error {
+	_, err := db.DB.Exec(`DELETE FROM webhook_deliveries WHERE provider = $1 AND delivery_id = $2`, provider, deliveryID)
+	return err
+}
@@ -0,0 +1,144 @@
+package project
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const githubPush = `{
+	"ref": "refs/heads/main",
+	"repository": {
+		"clone_url": "https://github.com/Example/docs.git",
+		"ssh_url": "git@github.com:Example/docs.git",
+		"html_url": "https://github.com/Example/docs",
+		"default_branch": "main"
+	}
+}`
+
+const gitlabPush = `{
+	"ref": "refs/heads/develop",
+	"project": {
+		"git_http_url": "https://gitlab.com/example/docs.git",
+		"git_ssh_url": "git@gitlab.com:example/docs.git",
+		"default_branch": "main"
+	}
+}`
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParsePushEvent(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-GitHub-Delivery", "d-1")
+	header.Set("X-GitHub-Event", "push")
+	event, err := ParsePushEvent(WebhookGitHub, header, []byte(githubPush))
+	require.NoError(t, err)
+	require.Equal(t, "d-1", event.DeliveryID)
+	require.True(t, event.Push)
+	require.Equal(t, "refs/heads/main", event.Ref)
+	require.Equal(t, "main", event.DefaultBranch)
+	require.Len(t, event.RepoURLs, 3)
+
+	header = http.Header{}
+	header.Set("X-Gitlab-Event-UUID", "d-2")
+	header.Set("X-Gitlab-Event", "Push Hook")
+	event, err = ParsePushEvent(WebhookGitLab, header, []byte(gitlabPush))
+	require.NoError(t, err)
+	require.True(t, event.Push)
+	require.Equal(t, "main", event.DefaultBranch)
+	require.True(t, event.MatchesRepository("https://gitlab.com/example/docs"))
+
+	// Pings are parsed but are not pushes
+	header = http.Header{}
+	header.Set("X-GitHub-Delivery", "d-3")
+	header.Set("X-GitHub-Event", "ping")
+	event, err = ParsePushEvent(WebhookGitHub, header, []byte(githubPush))
+	require.NoError(t, err)
+	require.False(t, event.Push)
+
+	_, err = ParsePushEvent(WebhookGitHub, http.Header{}, []byte(githubPush))
+	require.Error(t, err)
+	_, err = ParsePushEvent("bitbucket", header, []byte(githubPush))
+	require.ErrorIs(t, err, ErrUnknownWebhookProvider)
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(githubPush)
+
+	header := http.Header{}
+	header.Set("X-Hub-Signature-256", "sha256="+sign("s3cret", githubPush))
+	require.True(t, VerifyWebhookSignature(WebhookGitHub, header, body, "s3cret"))
+	require.False(t, VerifyWebhookSignature(WebhookGitHub, header, body, "other"))
+	require.False(t, VerifyWebhookSignature(WebhookGitHub, header, []byte(githubPush+" "), "s3cret"))
+
+	header = http.Header{}
+	header.Set("X-Gitea-Signature", sign("s3cret", githubPush))
+	require.True(t, VerifyWebhookSignature(WebhookGitea, header, body, "s3cret"))
+	require.False(t, VerifyWebhookSignature(WebhookGitHub, header, body, "s3cret"))
+
+	header = http.Header{}
+	header.Set("X-Gitlab-Token", "s3cret")
+	require.True(t, VerifyWebhookSignature(WebhookGitLab, header, body, "s3cret"))
+	require.False(t, VerifyWebhookSignature(WebhookGitLab, header, body, "other"))
+	require.False(t, VerifyWebhookSignature(WebhookGitLab, http.Header{}, body, ""))
+}
+
+func TestNormalizeRepoURL(t *testing.T) {
+	want := "github.com/example/docs"
+	for _, u := range []string{
+		"https://github.com/Example/docs.git",
+		"https://github.com/example/docs/",
+		"https://token@github.com/example/docs",
+		"ssh://git@github.com/example/docs.git",
+		"git@github.com:example/docs.git",
+	} {
+		require.Equal(t, want, NormalizeRepoURL(u), u)
+	}
+	require.NotEqual(t, want, NormalizeRepoURL("https://github.com/example/docs-site"))
+	require.NotEqual(t, want, NormalizeRepoURL("https://gitlab.com/example/docs"))
+}
+
+func TestMatchesRef(t *testing.T) {
+	event := &PushEvent{Ref: "refs/heads/main", DefaultBranch: "main"}
+	require.True(t, event.MatchesRef(""))
+	require.True(t, event.MatchesRef("main"))
+	require.True(t, event.MatchesRef("refs/heads/main"))
+	require.False(t, event.MatchesRef("develop"))
+
+	tag := &PushEvent{Ref: "refs/tags/v1.0", DefaultBranch: "main"}
+	require.True(t, tag.MatchesRef("v1.0"))
+	require.False(t, tag.MatchesRef(""))
+}
+
+func TestParseWebhookPath(t *testing.T) {
+	id, err := parseWebhookPath("/projects/7/webhook")
+	require.NoError(t, err)
+	require.Equal(t, 7, id)
+
+	_, err = parseWebhookPath("/projects/x/webhook")
+	require.Error(t, err)
+	_, err = parseWebhookPath("/projects/7/credentials")
+	require.Error(t, err)
+}
+
+func TestParseReceiveWebhookPath(t *testing.T) {
+	provider, id, err := parseReceiveWebhookPath("/webhooks/github/7")
+	require.NoError(t, err)
+	require.Equal(t, "github", provider)
+	require.Equal(t, 7, id)
+
+	_, _, err = parseReceiveWebhookPath("/webhooks/github")
+	require.Error(t, err)
+	_, _, err = parseReceiveWebhookPath("/webhooks/github/x")
+	require.Error(t, err)
+	_, _, err = parseReceiveWebhookPath("/webhooks/github/7/extra")
+	require.Error(t, err)
+}
@@ -0,0 +1,450 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
+	"github.com/xeodocs/xeodocs-backend/internal/translation"
+)
+
+// maxBrowseFileSize caps the files returned by the raw and pair endpoints
+const maxBrowseFileSize = 5 << 20
+
+var (
+	errInvalidPath = errors.New("invalid path")
+	errInvalidRef  = errors.New("invalid ref")
+	errRefNotFound = errors.New("ref not found")
+	errTooLarge    = fmt.Errorf("file larger than %d bytes", maxBrowseFileSize)
+)
+
+// TreeEntry is a file, directory, symlink or submodule of a repository tree
+type TreeEntry struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+	Hash string `json:"hash"`
+	Size int64  `json:"size,omitempty"`
+}
+
+// FileTree lists a directory of the source checkout or a language copy at a commit
+type FileTree struct {
+	Ref      string      `json:"ref"`
+	Commit   string      `json:"commit"`
+	Language string      `json:"language,omitempty"`
+	Path     string      `json:"path"`
+	Entries  []TreeEntry `json:"entries"`
+}
+
+// FileVersion is the content of a file at a commit. Content is omitted for binary files.
+type FileVersion struct {
+	Commit  string `json:"commit"`
+	Hash    string `json:"hash"`
+	Size    int64  `json:"size"`
+	Binary  bool   `json:"binary"`
+	Content string `json:"content,omitempty"`
+}
+
+// FilePair is a source file next to its translation. Translation is nil when the
+// language copy does not have the file. SourceHash is the source blob the
+// translation was made from, as recorded by the translation status.
+type FilePair struct {
+	Path        string       `json:"path"`
+	Language    string       `json:"language"`
+	Source      *FileVersion `json:"source"`
+	Translation *FileVersion `json:"translation"`
+	Status      string       `json:"status,omitempty"`
+	SourceHash  *string      `json:"source_hash,omitempty"`
+}
+
+// cleanRepoPath validates a path inside a repository tree and returns it in
+// canonical form. Absolute paths, parent references, backslashes, control
+// characters and .git are rejected; an empty path is the repository root.
+func cleanRepoPath(p string) (string, error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return "", nil
+	}
+	if !utf8.ValidString(p) || strings.ContainsAny(p, "\\\x00") {
+		return "", errInvalidPath
+	}
+	for _, r := range p {
+		if r < 0x20 || r == 0x7f {
+			return "", errInvalidPath
+		}
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.EqualFold(segment, ".git") {
+			return "", errInvalidPath
+		}
+	}
+	return path.Clean(p), nil
+}
+
+// validRefName reports whether ref is a plain branch, tag or commit name, without
+// revision syntax such as HEAD~1 or ranges
+func validRefName(ref string) bool {
+	if ref == "" || len(ref) > 255 || strings.HasPrefix(ref, "-") || strings.Contains(ref, "..") || strings.HasSuffix(ref, ".lock") {
+		return false
+	}
+	for _, r := range ref {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '/', r == '.', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// resolveBrowseRef resolves the commit to browse. Without ref it is the checked
+// out source commit, or the language branch when language is set. Branches,
+// tags and full commit hashes of the source checkout are accepted.
+func resolveBrowseRef(repo *git.Repository, ref, language string) (string, plumbing.Hash, error) {
+	if ref == "" {
+		if language == "" {
+			hash, err := headCommit(repo)
+			return "HEAD", hash, err
+		}
+		ref = LanguageBranch(language)
+	}
+	if !validRefName(ref) {
+		return "", plumbing.ZeroHash, errInvalidRef
+	}
+
+	// Full names are limited to refs/ so that files such as .git/config are never read as refs
+	var candidates []plumbing.ReferenceName
+	if strings.HasPrefix(ref, "refs/") {
+		candidates = append(candidates, plumbing.ReferenceName(ref))
+	}
+	candidates = append(candidates,
+		plumbing.NewBranchReferenceName(ref),
+		plumbing.NewTagReferenceName(ref),
+		plumbing.NewRemoteReferenceName(sourceRemote, ref),
+	)
+	for _, name := range candidates {
+		r, err := repo.Reference(name, true)
+		if err != nil {
+			continue
+		}
+		hash, err := peelCommit(repo, r.Hash())
+		if err != nil {
+			return "", plumbing.ZeroHash, err
+		}
+		return ref, hash, nil
+	}
+	if plumbing.IsHash(ref) {
+		if _, err := repo.CommitObject(plumbing.NewHash(ref)); err == nil {
+			return ref, plumbing.NewHash(ref), nil
+		}
+	}
+	return "", plumbing.ZeroHash, errRefNotFound
+}
+
+// listTree returns the entries of dir in tree, or of the whole subtree when recursive is set
+func listTree(tree *object.Tree, dir string, recursive bool) ([]TreeEntry, error) {
+	if dir != "" {
+		sub, err := tree.Tree(dir)
+		if err != nil {
+			return nil, err
+		}
+		tree = sub
+	}
+
+	entries := []TreeEntry{}
+	walker := object.NewTreeWalker(tree, recursive, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		e := TreeEntry{Path: path.Join(dir, name), Hash: entry.Hash.String()}
+		switch entry.Mode {
+		case filemode.Dir:
+			e.Type = "dir"
+		case filemode.Submodule:
+			e.Type = "submodule"
+		case filemode.Symlink:
+			e.Type = "symlink"
+		default:
+			e.Type = "file"
+			if f, err := tree.TreeEntryFile(&entry); err == nil {
+				e.Size = f.Size
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// readFileVersion reads a regular file of tree. Symlinks and submodules are not
+// followed, so content never comes from outside the repository.
+func readFileVersion(tree *object.Tree, commit plumbing.Hash, file string) (*FileVersion, []byte, error) {
+	f, err := tree.File(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !f.Mode.IsFile() || f.Mode == filemode.Symlink {
+		return nil, nil, object.ErrFileNotFound
+	}
+	if f.Size > maxBrowseFileSize {
+		return nil, nil, errTooLarge
+	}
+	reader, err := f.Reader()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	version := &FileVersion{Commit: commit.String(), Hash: f.Hash.String(), Size: f.Size}
+	if utf8.Valid(data) && !strings.ContainsRune(string(data), 0) {
+		version.Content = string(data)
+	} else {
+		version.Binary = true
+	}
+	return version, data, nil
+}
+
+// browseRequest holds the project, checkout and query of a file browser request
+type browseRequest struct {
+	project  *project.Project
+	repo     *git.Repository
+	path     string
+	ref      string
+	language string
+}
+
+// parseBrowseRequest validates the project, path, ref and language of a file browser
+// request, writing an error response and returning nil when one is invalid
+func parseBrowseRequest(w http.ResponseWriter, r *http.Request, projectID int) *browseRequest {
+	query := r.URL.Query()
+	req := &browseRequest{ref: query.Get("ref"), language: query.Get("lang")}
+
+	var err error
+	if req.path, err = cleanRepoPath(query.Get("path")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	if req.ref != "" && !validRefName(req.ref) {
+		http.Error(w, errInvalidRef.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	if req.project, err = project.GetProjectByID(projectID); err != nil {
+		writeProjectError(w, err)
+		return nil
+	}
+	if req.language != "" && (!storage.ValidLanguage(req.language) || !slices.Contains(req.project.Languages, req.language)) {
+		http.Error(w, "Language not configured for project", http.StatusBadRequest)
+		return nil
+	}
+
+	if req.repo, err = git.PlainOpen(storage.Repos.ProjectPath(projectID)); err != nil {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return nil
+	}
+	return req
+}
+
+// treeAt resolves ref and returns the tree of the resulting commit
+func (req *browseRequest) treeAt(w http.ResponseWriter, ref, language string) (string, plumbing.Hash, *object.Tree, bool) {
+	name, hash, err := resolveBrowseRef(req.repo, ref, language)
+	if err != nil {
+		if errors.Is(err, errRefNotFound) || errors.Is(err, errInvalidRef) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			log.Printf("Error resolving ref: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return "", plumbing.ZeroHash, nil, false
+	}
+	tree, err := commitTree(req.repo, hash)
+	if err != nil {
+		log.Printf("Error reading tree: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return "", plumbing.ZeroHash, nil, false
+	}
+	return name, hash, tree, true
+}
+
+// FilesHandler handles GET /projects/{id}/files/{action} for the preview, tree,
+// raw and pair actions
+func FilesHandler(cfg *config.Config) http.HandlerFunc {
+	preview := PreviewFilesHandler(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, action, err := parseFilesPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		switch action {
+		case "preview":
+			preview(w, r)
+		case "tree":
+			fileTree(w, r, projectID)
+		case "raw":
+			rawFile(w, r, projectID)
+		case "pair":
+			filePair(w, r, projectID)
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+	}
+}
+
+// fileTree serves GET /projects/{id}/files/tree?path=&ref=&lang=&recursive=true
+func fileTree(w http.ResponseWriter, r *http.Request, projectID int) {
+	req := parseBrowseRequest(w, r, projectID)
+	if req == nil {
+		return
+	}
+	ref, commit, tree, ok := req.treeAt(w, req.ref, req.language)
+	if !ok {
+		return
+	}
+
+	entries, err := listTree(tree, req.path, r.URL.Query().Get("recursive") == "true")
+	if err != nil {
+		if errors.Is(err, object.ErrDirectoryNotFound) {
+			http.Error(w, "Directory not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error listing tree: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FileTree{
+		Ref:      ref,
+		Commit:   commit.String(),
+		Language: req.language,
+		Path:     req.path,
+		Entries:  entries,
+	})
+}
+
+// rawFile serves GET /projects/{id}/files/raw?path=&ref=&lang= as plain text or
+// octet-stream, with the commit and blob hash in headers
+func rawFile(w http.ResponseWriter, r *http.Request, projectID int) {
+	req := parseBrowseRequest(w, r, projectID)
+	if req == nil {
+		return
+	}
+	if req.path == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	_, commit, tree, ok := req.treeAt(w, req.ref, req.language)
+	if !ok {
+		return
+	}
+
+	version, data, err := readFileVersion(tree, commit, req.path)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+
+	// Never let browsers render repository content as HTML
+	if version.Binary {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Commit", version.Commit)
+	w.Header().Set("X-Blob-Hash", version.Hash)
+	w.Write(data)
+}
+
+// filePair serves GET /projects/{id}/files/pair?path=&lang=&ref=. ref selects the
+// source commit; the translation is read from the language branch.
+func filePair(w http.ResponseWriter, r *http.Request, projectID int) {
+	req := parseBrowseRequest(w, r, projectID)
+	if req == nil {
+		return
+	}
+	if req.path == "" || req.language == "" {
+		http.Error(w, "path and lang are required", http.StatusBadRequest)
+		return
+	}
+
+	_, sourceCommit, sourceTree, ok := req.treeAt(w, req.ref, "")
+	if !ok {
+		return
+	}
+	source, _, err := readFileVersion(sourceTree, sourceCommit, req.path)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+	pair := FilePair{Path: req.path, Language: req.language, Source: source}
+
+	_, langCommit, langTree, ok := req.treeAt(w, "", req.language)
+	if !ok {
+		return
+	}
+	translated, _, err := readFileVersion(langTree, langCommit, req.path)
+	if err != nil && !errors.Is(err, object.ErrFileNotFound) {
+		writeFileError(w, err)
+		return
+	}
+	pair.Translation = translated
+
+	status, err := translation.GetTranslationFile(projectID, req.language, req.path)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error getting translation status: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if status != nil {
+		pair.Status = status.Status
+		pair.SourceHash = status.SourceHash
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pair)
+}
+
+func writeFileError(w http.ResponseWriter, err error) {
+	if errors.Is(err, object.ErrFileNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errTooLarge) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	log.Printf("Error reading file: %v", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanRepoPath(t *testing.T) {
+	for input, expected := range map[string]string{
+		"":                "",
+		"/":               "",
+		"docs/intro.md":   "docs/intro.md",
+		"/docs/":          "docs",
+		"docs/.vitepress": "docs/.vitepress",
+	} {
+		cleaned, err := cleanRepoPath(input)
+		require.NoError(t, err, input)
+		require.Equal(t, expected, cleaned, input)
+	}
+
+	for _, input := range []string{
+		"../etc/passwd",
+		"docs/../../x",
+		"docs/./intro.md",
+		"docs//intro.md",
+		".git/config",
+		"docs/.GIT/HEAD",
+		"docs\\..\\x",
+		"docs/intro.md\x00",
+		"docs/\nintro.md",
+	} {
+		_, err := cleanRepoPath(input)
+		require.ErrorIs(t, err, errInvalidPath, input)
+	}
+}
+
+func TestValidRefName(t *testing.T) {
+	for _, ref := range []string{"main", "v1.0", "xeodocs/es", "refs/heads/main", "0123abcd"} {
+		require.True(t, validRefName(ref), ref)
+	}
+	for _, ref := range []string{"", "HEAD~1", "main^", "a..b", "-n", "main@{1}", "a:b", "x.lock", "a b"} {
+		require.False(t, validRefName(ref), ref)
+	}
+}
+
+func TestBrowseRepository(t *testing.T) {
+	_, repo := upstreamRepo(t)
+
+	for ref, expected := range map[string]string{"main": "docs/setup.md", "v2": "docs/v2.md", "v1.0": "README.md"} {
+		_, hash, err := resolveBrowseRef(repo, ref, "")
+		require.NoError(t, err, ref)
+		tree, err := commitTree(repo, hash)
+		require.NoError(t, err)
+		_, err = tree.File(expected)
+		require.NoError(t, err, ref)
+	}
+	_, _, err := resolveBrowseRef(repo, "v3", "")
+	require.ErrorIs(t, err, errRefNotFound)
+	_, _, err = resolveBrowseRef(repo, "config", "")
+	require.ErrorIs(t, err, errRefNotFound)
+	_, _, err = resolveBrowseRef(repo, "", "es")
+	require.ErrorIs(t, err, errRefNotFound)
+
+	name, head, err := resolveBrowseRef(repo, "", "")
+	require.NoError(t, err)
+	require.Equal(t, "HEAD", name)
+	byHash, hash, err := resolveBrowseRef(repo, head.String(), "")
+	require.NoError(t, err)
+	require.Equal(t, head.String(), byHash)
+	require.Equal(t, head, hash)
+
+	tree, err := commitTree(repo, head)
+	require.NoError(t, err)
+
+	entries, err := listTree(tree, "", false)
+	require.NoError(t, err)
+	require.Equal(t, []TreeEntry{
+		{Path: "README.md", Type: "file", Hash: entries[0].Hash, Size: 10},
+		{Path: "docs", Type: "dir", Hash: entries[1].Hash},
+	}, entries)
+
+	entries, err = listTree(tree, "docs", true)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "docs/intro.md", entries[0].Path)
+
+	_, err = listTree(tree, "missing", false)
+	require.ErrorIs(t, err, object.ErrDirectoryNotFound)
+
+	version, data, err := readFileVersion(tree, head, "docs/intro.md")
+	require.NoError(t, err)
+	require.Equal(t, "# Intro\n", string(data))
+	require.Equal(t, "# Intro\n", version.Content)
+	require.False(t, version.Binary)
+	require.Equal(t, head.String(), version.Commit)
+
+	_, _, err = readFileVersion(tree, head, "docs")
+	require.ErrorIs(t, err, object.ErrFileNotFound)
+}
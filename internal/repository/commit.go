@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/logging"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
+	"github.com/xeodocs/xeodocs-backend/internal/translation"
+)
+
+// commitLanguage commits changed files to the language branch and pushes the
+// branch when the project has a push remote. The source commit defaults to the
+// last synced commit. It returns the new commit hash, or "" when nothing changed.
+func commitLanguage(cfg *config.Config, proj *project.Project, language string, c project.TranslationCommit) (string, error) {
+	c.Language = language
+	if c.SourceCommit == "" {
+		state, err := GetRepositoryState(proj.ID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get repository state: %w", err)
+		}
+		if state != nil {
+			c.SourceCommit = state.CurrentCommit
+		}
+	}
+
+	path, err := storage.Repos.LanguagePath(proj.ID, language)
+	if err != nil {
+		return "", err
+	}
+	committer := project.CommitAuthor{Name: cfg.CommitAuthorName, Email: cfg.CommitAuthorEmail}
+	hash, err := project.CommitTranslations(path, c, committer)
+	if err != nil {
+		return "", fmt.Errorf("failed to commit %s translations: %w", language, err)
+	}
+	if hash == "" {
+		return "", nil
+	}
+
+	message := fmt.Sprintf("Committed %d files to %s as %s", len(c.Files), LanguageBranch(language), hash)
+	logging.LogActivity(cfg.LoggingServiceURL, "translations_committed", message, nil, &proj.ID, "info")
+
+	if proj.PushRemoteURL != "" {
+		auth, err := project.GetAuthMethod(proj.ID, cfg.CredentialsKey)
+		if err != nil {
+			return hash, fmt.Errorf("failed to get repository credentials: %w", err)
+		}
+		if err := project.PushBranch(path, proj.PushRemoteURL, LanguageBranch(language), auth); err != nil {
+			return hash, err
+		}
+		message := fmt.Sprintf("Pushed %s to %s", LanguageBranch(language), proj.PushRemoteURL)
+		logging.LogActivity(cfg.LoggingServiceURL, "translations_pushed", message, nil, &proj.ID, "info")
+	}
+	return hash, nil
+}
+
+// writeTranslations writes rendered translations into the worktree of language.
+// The caller holds the project lock.
+func writeTranslations(projectID int, language string, contents map[string]string) error {
+	if len(contents) == 0 {
+		return nil
+	}
+	// A deleted project or language copy must not be recreated by a late translation
+	dir, err := storage.Repos.LanguagePath(projectID, language)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("language copy %s is missing: %w", language, err)
+	}
+
+	for file, content := range contents {
+		target, err := storage.Repos.LanguageFile(projectID, language, file)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory of %s: %w", file, err)
+		}
+		if err := removeSpecialFile(target); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", file, err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// markCommitted records the committed translations of req as translated. The
+// translation service leaves them translating until they are on the branch.
+func markCommitted(req CommitTranslationsRequest) {
+	for _, file := range req.Files {
+		hash := ""
+		if content, ok := req.Contents[file]; ok {
+			hash = translation.GitBlobHash([]byte(content))
+		}
+		if err := translation.MarkFileTranslated(req.ProjectID, req.Language, file, hash); err != nil {
+			log.Printf("Error updating translation status of %s: %v", file, err)
+		}
+	}
+}
+
+// reviewersOf returns the editors who approved any of the files in language
+func reviewersOf(projectID int, language string, files []string) []project.CommitAuthor {
+	seen := map[int]bool{}
+	var ids []int
+	for _, file := range files {
+		record, err := translation.GetTranslationFile(projectID, language, file)
+		if err != nil || record.Status != translation.StatusReviewed || record.ReviewedBy == nil {
+			continue
+		}
+		if !seen[*record.ReviewedBy] {
+			seen[*record.ReviewedBy] = true
+			ids = append(ids, *record.ReviewedBy)
+		}
+	}
+	sort.Ints(ids)
+
+	var reviewers []project.CommitAuthor
+	for _, id := range ids {
+		author, err := project.GetCommitAuthor(id)
+		if err != nil {
+			log.Printf("Error getting reviewer %d: %v", id, err)
+			continue
+		}
+		reviewers = append(reviewers, *author)
+	}
+	return reviewers
+}
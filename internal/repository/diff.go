@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// DiffCommits lists the files added, modified, deleted and renamed between two commits
+func DiffCommits(repo *git.Repository, from, to plumbing.Hash) (*FileChanges, error) {
+	fromTree, err := commitTree(repo, from)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := commitTree(repo, to)
+	if err != nil {
+		return nil, err
+	}
+	return diffTrees(fromTree, toTree)
+}
+
+// diffTrees lists the files added, modified, deleted and renamed between two
+// trees, which may belong to different repositories
+func diffTrees(fromTree, toTree *object.Tree) (*FileChanges, error) {
+	changes, err := object.DiffTreeWithOptions(context.Background(), fromTree, toTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff trees: %w", err)
+	}
+
+	result := &FileChanges{}
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+
+		switch action {
+		case merkletrie.Insert:
+			result.Added = append(result.Added, change.To.Name)
+		case merkletrie.Delete:
+			result.Deleted = append(result.Deleted, change.From.Name)
+		case merkletrie.Modify:
+			if change.From.Name != change.To.Name {
+				result.Renamed = append(result.Renamed, Rename{
+					From:     change.From.Name,
+					To:       change.To.Name,
+					Modified: change.From.TreeEntry.Hash != change.To.TreeEntry.Hash,
+				})
+			} else {
+				result.Modified = append(result.Modified, change.To.Name)
+			}
+		}
+	}
+
+	sort.Strings(result.Added)
+	sort.Strings(result.Modified)
+	sort.Strings(result.Deleted)
+	sort.Slice(result.Renamed, func(i, j int) bool { return result.Renamed[i].To < result.Renamed[j].To })
+	return result, nil
+}
+
+// diffCheckouts diffs a commit of one checkout against a commit of another
+func diffCheckouts(fromRepo *git.Repository, from plumbing.Hash, toRepo *git.Repository, to plumbing.Hash) (*FileChanges, error) {
+	fromTree, err := commitTree(fromRepo, from)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := commitTree(toRepo, to)
+	if err != nil {
+		return nil, err
+	}
+	return diffTrees(fromTree, toTree)
+}
+
+// restrictChanges keeps the changes to files for which inRoot is true. A rename
+// across the boundary becomes an addition or deletion.
+func restrictChanges(changes *FileChanges, inRoot func(string) bool) *FileChanges {
+	result := &FileChanges{}
+	for _, file := range changes.Added {
+		if inRoot(file) {
+			result.Added = append(result.Added, file)
+		}
+	}
+	for _, file := range changes.Modified {
+		if inRoot(file) {
+			result.Modified = append(result.Modified, file)
+		}
+	}
+	for _, file := range changes.Deleted {
+		if inRoot(file) {
+			result.Deleted = append(result.Deleted, file)
+		}
+	}
+	for _, rename := range changes.Renamed {
+		switch from, to := inRoot(rename.From), inRoot(rename.To); {
+		case from && to:
+			result.Renamed = append(result.Renamed, rename)
+		case from:
+			result.Deleted = append(result.Deleted, rename.From)
+		case to:
+			result.Added = append(result.Added, rename.To)
+		}
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Deleted)
+	return result
+}
+
+func commitTree(repo *git.Repository, hash plumbing.Hash) (*object.Tree, error) {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load commit %s: %w", hash, err)
+	}
+	return commit.Tree()
+}
+
+// headCommit returns the commit hash HEAD points to
+func headCommit(repo *git.Repository) (plumbing.Hash, error) {
+	ref, err := repo.Head()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return ref.Hash(), nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+)
+
+func commitFiles(t *testing.T, repo *git.Repository, dir string, write map[string]string, remove []string) plumbing.Hash {
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	for name, content := range write {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		_, err := worktree.Add(name)
+		require.NoError(t, err)
+	}
+	for _, name := range remove {
+		_, err := worktree.Remove(name)
+		require.NoError(t, err)
+	}
+
+	hash, err := worktree.Commit("update", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+	return hash
+}
+
+func TestDiffCommits(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+
+	guide := "# Guide\n\nThis guide is long enough for rename detection to match it reliably.\n"
+	first := commitFiles(t, repo, dir, map[string]string{
+		"docs/intro.md":   "# Intro\n",
+		"docs/old.md":     "# Old\n",
+		"docs/guide.md":   guide,
+		"static/logo.svg": "<svg/>",
+	}, nil)
+
+	// Move the guide by writing it at the new path and removing the old one
+	second := commitFiles(t, repo, dir, map[string]string{
+		"docs/intro.md":        "# Intro\n\nUpdated.\n",
+		"docs/new.md":          "# New\n",
+		"docs/guides/guide.md": guide,
+	}, []string{"docs/old.md", "docs/guide.md"})
+
+	changes, err := DiffCommits(repo, first, second)
+	require.NoError(t, err)
+	require.Equal(t, []string{"docs/new.md"}, changes.Added)
+	require.Equal(t, []string{"docs/intro.md"}, changes.Modified)
+	require.Equal(t, []string{"docs/old.md"}, changes.Deleted)
+	require.Equal(t, []Rename{{From: "docs/guide.md", To: "docs/guides/guide.md", Modified: false}}, changes.Renamed)
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
+	"github.com/xeodocs/xeodocs-backend/internal/translation"
+)
+
+// ExcludedFile is a translatable file skipped by a pattern. Pattern is empty
+// when the file matched no include pattern.
+type ExcludedFile struct {
+	Path    string `json:"path"`
+	Pattern string `json:"pattern"`
+}
+
+// FilePreview lists the files that would be translated with a set of file rules
+type FilePreview struct {
+	Commit          string              `json:"commit"`
+	DocsRoot        string              `json:"docs_root"`
+	IncludePatterns []string            `json:"include_patterns"`
+	ExcludePatterns []string            `json:"exclude_patterns"`
+	DefaultExclude  map[string][]string `json:"default_exclude_patterns"`
+	Files           []string            `json:"files"`
+	Excluded        []ExcludedFile      `json:"excluded"`
+	Total           int                 `json:"total"`
+}
+
+// previewFiles applies rules to the supported files of tree inside the project's docs root
+func previewFiles(proj *project.Project, rules project.FileRules, tree *object.Tree) (*FilePreview, error) {
+	preview := &FilePreview{
+		DocsRoot:        proj.DocsRoot,
+		IncludePatterns: rules.Include,
+		ExcludePatterns: rules.Exclude,
+		DefaultExclude:  project.DefaultExcludePatterns,
+		Files:           []string{},
+		Excluded:        []ExcludedFile{},
+	}
+	err := tree.Files().ForEach(func(f *object.File) error {
+		if !isRegularMode(f.Mode) || !proj.InDocsRoot(f.Name) || !translation.IsSupportedFile(f.Name) {
+			return nil
+		}
+		if pattern, ok := rules.Match(f.Name); ok {
+			preview.Files = append(preview.Files, f.Name)
+		} else {
+			preview.Excluded = append(preview.Excluded, ExcludedFile{Path: f.Name, Pattern: pattern})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(preview.Files)
+	sort.Slice(preview.Excluded, func(i, j int) bool { return preview.Excluded[i].Path < preview.Excluded[j].Path })
+	preview.Total = len(preview.Files)
+	return preview, nil
+}
+
+// parseFilesPath extracts the project ID and the action from /projects/{id}/files/{action}
+func parseFilesPath(path string) (projectID int, action string, err error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/projects/"), "/"), "/")
+	if len(parts) != 3 || parts[1] != "files" {
+		return 0, "", errors.New("invalid files path")
+	}
+	if projectID, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, "", errors.New("invalid project ID")
+	}
+	return projectID, parts[2], nil
+}
+
+// PreviewFilesHandler handles GET /projects/{id}/files/preview. Repeated include
+// and exclude query parameters preview other patterns than the project's.
+func PreviewFilesHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, action, err := parseFilesPath(r.URL.Path)
+		if err != nil || action != "preview" {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		proj, err := project.GetProjectByID(projectID)
+		if err != nil {
+			writeProjectError(w, err)
+			return
+		}
+
+		rules := proj.FileRules()
+		query := r.URL.Query()
+		if include, ok := query["include"]; ok {
+			rules.Include = include
+		}
+		if exclude, ok := query["exclude"]; ok {
+			rules.Exclude = exclude
+		}
+		if err := rules.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		repo, err := git.PlainOpen(storage.Repos.ProjectPath(projectID))
+		if err != nil {
+			http.Error(w, "Repository not found", http.StatusNotFound)
+			return
+		}
+		head, err := headCommit(repo)
+		if err != nil {
+			log.Printf("Error reading HEAD: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		tree, err := commitTree(repo, head)
+		if err != nil {
+			log.Printf("Error reading tree: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		preview, err := previewFiles(proj, rules, tree)
+		if err != nil {
+			log.Printf("Error listing files: %v", err)
+			http.Error(w, "Failed to list files", http.StatusInternalServerError)
+			return
+		}
+		preview.Commit = head.String()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(preview)
+	}
+}
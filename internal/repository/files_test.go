@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+)
+
+func TestPreviewFiles(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	head := commitFiles(t, repo, dir, map[string]string{
+		"README.md":                "# Project\n",
+		"docs/intro.md":            "# Intro\n",
+		"docs/CHANGELOG.md":        "# Changes\n",
+		"docs/api/client.md":       "# Client\n",
+		"docs/images/logo.svg":     "<svg/>",
+		"docs/guides/install.mdx":  "# Install\n",
+		"docs/vendor/notes.md":     "# Notes\n",
+		"node_modules/pkg/doc.md":  "# Package\n",
+		"src/generated/types.md":   "# Types\n",
+		"website/i18n/en/app.json": `{"title": "Docs"}`,
+	}, nil)
+	tree, err := commitTree(repo, head)
+	require.NoError(t, err)
+
+	proj := &project.Project{DocsRoot: "docs", ExcludePatterns: project.Patterns{"docs/api/**"}}
+	preview, err := previewFiles(proj, proj.FileRules(), tree)
+	require.NoError(t, err)
+	require.Equal(t, []string{"docs/guides/install.mdx", "docs/intro.md", "docs/vendor/notes.md"}, preview.Files)
+	require.Equal(t, []ExcludedFile{
+		{Path: "docs/CHANGELOG.md", Pattern: "CHANGELOG*"},
+		{Path: "docs/api/client.md", Pattern: "docs/api/**"},
+	}, preview.Excluded)
+	require.Equal(t, 3, preview.Total)
+
+	// The sync only treats the same files as translatable
+	require.True(t, isTranslatable(proj, "docs/intro.md"))
+	require.False(t, isTranslatable(proj, "docs/api/client.md"))
+	require.False(t, isTranslatable(proj, "README.md"))
+}
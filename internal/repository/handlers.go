@@ -2,15 +2,20 @@ package repository
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/xeodocs/xeodocs-backend/internal/project"
 	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
 	"github.com/xeodocs/xeodocs-backend/internal/shared/logging"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
 )
 
 func CloneRepoHandler(cfg *config.Config) http.HandlerFunc {
@@ -26,25 +31,56 @@ func CloneRepoHandler(cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
-		// Clone repo to /repos/projectID
-		repoPath := fmt.Sprintf("/repos/%d", req.ProjectID)
-		if err := os.MkdirAll(repoPath, 0755); err != nil {
-			log.Printf("Error creating repo directory: %v", err)
+		proj, err := project.GetProjectByID(req.ProjectID)
+		if err != nil {
+			writeProjectError(w, err)
+			return
+		}
+
+		auth, err := project.GetAuthMethod(req.ProjectID, cfg.CredentialsKey)
+		if err != nil {
+			log.Printf("Error getting repository credentials: %v", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		_, err := git.PlainClone(repoPath, false, &git.CloneOptions{
-			URL: req.RepoURL,
-		})
+		ref, err := resolveSourceRef(req.RepoURL, proj.SourceRef, auth)
+		if err != nil {
+			log.Printf("Error resolving source ref: %v", err)
+			http.Error(w, "Failed to clone repository", http.StatusInternalServerError)
+			return
+		}
+
+		unlock, err := storage.LockProject(req.ProjectID, cfg.ProjectLockTimeout)
+		if err != nil {
+			writeLockError(w, err)
+			return
+		}
+		defer unlock()
+
+		// Clone only the source ref into the project's checkout
+		repo, err := cloneSource(storage.Repos.ProjectPath(req.ProjectID), req.RepoURL, ref, auth)
 		if err != nil {
 			log.Printf("Error cloning repo: %v", err)
 			http.Error(w, "Failed to clone repository", http.StatusInternalServerError)
 			return
 		}
 
+		// Record the cloned commit so the next sync can diff against it
+		head, err := headCommit(repo)
+		if err != nil {
+			log.Printf("Error reading HEAD: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := SaveRepositoryState(req.ProjectID, nil, head.String(), req.RepoURL, ref.String()); err != nil {
+			log.Printf("Error saving repository state: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
 		// Log the repository cloning
-		message := fmt.Sprintf("Repository cloned: %s for project %d", req.RepoURL, req.ProjectID)
+		message := fmt.Sprintf("Repository cloned: %s at %s for project %d", req.RepoURL, ref.Short(), req.ProjectID)
 		logging.LogActivity(cfg.LoggingServiceURL, "repo_cloned", message, nil, &req.ProjectID, "info")
 
 		w.Header().Set("Content-Type", "application/json")
@@ -65,16 +101,63 @@ func CreateLanguageCopiesHandler(cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
-		repoPath := fmt.Sprintf("/repos/%d", req.ProjectID)
+		proj, err := project.GetProjectByID(req.ProjectID)
+		if err != nil {
+			writeProjectError(w, err)
+			return
+		}
+
+		worktrees := make(map[string]string, len(req.Languages))
+		for _, lang := range req.Languages {
+			path, err := storage.Repos.LanguagePath(req.ProjectID, lang)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid language code: %s", lang), http.StatusBadRequest)
+				return
+			}
+			worktrees[lang] = path
+		}
+
+		unlock, err := storage.LockProject(req.ProjectID, cfg.ProjectLockTimeout)
+		if err != nil {
+			writeLockError(w, err)
+			return
+		}
+		defer unlock()
+
+		repoPath := storage.Repos.ProjectPath(req.ProjectID)
 		for _, lang := range req.Languages {
-			langPath := fmt.Sprintf("/repos/%d/%s", req.ProjectID, lang)
-			if err := copyDir(repoPath, langPath); err != nil {
-				log.Printf("Error copying to language dir %s: %v", lang, err)
+			if err := AddLanguageWorktree(repoPath, worktrees[lang], lang); err != nil {
+				log.Printf("Error creating %s worktree: %v", lang, err)
 				http.Error(w, "Failed to create language copies", http.StatusInternalServerError)
 				return
 			}
 		}
 
+		// Every translatable file starts out pending in each new language
+		repo, err := git.PlainOpen(repoPath)
+		if err != nil {
+			log.Printf("Error opening repo: %v", err)
+			http.Error(w, "Repository not found", http.StatusNotFound)
+			return
+		}
+		head, err := headCommit(repo)
+		if err != nil {
+			log.Printf("Error reading HEAD: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		tree, err := commitTree(repo, head)
+		if err != nil {
+			log.Printf("Error reading tree: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := trackTranslatableFiles(proj, req.Languages, tree); err != nil {
+			log.Printf("Error tracking translatable files: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
 		// Log the language copies creation
 		message := fmt.Sprintf("Language copies created for project %d: %v", req.ProjectID, req.Languages)
 		logging.LogActivity(cfg.LoggingServiceURL, "language_copies_created", message, nil, &req.ProjectID, "info")
@@ -97,7 +180,20 @@ func SyncRepoHandler(cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
-		repoPath := fmt.Sprintf("/repos/%d", req.ProjectID)
+		proj, err := project.GetProjectByID(req.ProjectID)
+		if err != nil {
+			writeProjectError(w, err)
+			return
+		}
+
+		unlock, err := storage.LockProject(req.ProjectID, cfg.ProjectLockTimeout)
+		if err != nil {
+			writeLockError(w, err)
+			return
+		}
+		defer unlock()
+
+		repoPath := storage.Repos.ProjectPath(req.ProjectID)
 		repo, err := git.PlainOpen(repoPath)
 		if err != nil {
 			log.Printf("Error opening repo: %v", err)
@@ -105,29 +201,225 @@ func SyncRepoHandler(cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
-		worktree, err := repo.Worktree()
+		auth, err := project.GetAuthMethod(req.ProjectID, cfg.CredentialsKey)
 		if err != nil {
-			log.Printf("Error getting worktree: %v", err)
+			log.Printf("Error getting repository credentials: %v", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		err = worktree.Pull(&git.PullOptions{})
-		if err != nil && err != git.NoErrAlreadyUpToDate {
-			log.Printf("Error pulling repo: %v", err)
+		ref, err := resolveSourceRef(proj.RepoURL, proj.SourceRef, auth)
+		if err != nil {
+			log.Printf("Error resolving source ref: %v", err)
 			http.Error(w, "Failed to sync repository", http.StatusInternalServerError)
 			return
 		}
 
+		before, err := headCommit(repo)
+		if err != nil {
+			log.Printf("Error reading HEAD: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Diff against the last recorded commit when we have one, so a sync that
+		// failed half-way is picked up again on the next run
+		state, err := GetRepositoryState(req.ProjectID)
+		if err != nil {
+			log.Printf("Error getting repository state: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		base := before
+		if state != nil {
+			if recorded := plumbing.NewHash(state.CurrentCommit); recorded != before {
+				if _, err := repo.CommitObject(recorded); err == nil {
+					base = recorded
+				}
+			}
+		}
+
+		var after plumbing.Hash
+		var changes *FileChanges
+		recloned := state.sourceChanged(proj.RepoURL, ref)
+		if recloned {
+			// A new repository URL or ref gets a fresh clone, prepared next to the
+			// checkout and diffed against it before it takes its place
+			staging := stagingPath(repoPath)
+			if err := os.RemoveAll(staging); err != nil {
+				log.Printf("Error clearing staging clone: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			clone, err := cloneSource(staging, proj.RepoURL, ref, auth)
+			if err != nil {
+				os.RemoveAll(staging)
+				log.Printf("Error re-cloning repo: %v", err)
+				http.Error(w, "Failed to sync repository", http.StatusInternalServerError)
+				return
+			}
+			if after, err = headCommit(clone); err == nil {
+				changes, err = diffCheckouts(repo, base, clone, after)
+			}
+			if err == nil {
+				err = replaceSource(repoPath, staging, storage.Repos.WorktreesPath(req.ProjectID), proj.Languages)
+			}
+			if err == nil {
+				repo, err = git.PlainOpen(repoPath)
+			}
+			if err != nil {
+				os.RemoveAll(staging)
+				log.Printf("Error replacing checkout: %v", err)
+				http.Error(w, "Failed to sync repository", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			if after, err = fetchSource(repo, ref, auth); err != nil {
+				log.Printf("Error fetching repo: %v", err)
+				http.Error(w, "Failed to sync repository", http.StatusInternalServerError)
+				return
+			}
+			if base != after {
+				if changes, err = DiffCommits(repo, base, after); err != nil {
+					log.Printf("Error diffing commits: %v", err)
+					http.Error(w, "Failed to compute changes", http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+
+		response := SyncRepoResponse{Success: true, Message: "Repository synced successfully", CurrentCommit: after.String(), Recloned: recloned}
+		if changes != nil {
+			// Only the docs subtree is translated and mirrored into the language copies
+			changes = restrictChanges(changes, proj.InDocsRoot)
+
+			tree, err := commitTree(repo, after)
+			if err != nil {
+				log.Printf("Error reading tree: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			if err := propagateChanges(cfg, req.ProjectID, after.String(), changes, tree); err != nil {
+				log.Printf("Error propagating changes: %v", err)
+				http.Error(w, "Failed to propagate changes", http.StatusInternalServerError)
+				return
+			}
+
+			response.PreviousCommit = base.String()
+			response.Changes = changes
+		}
+
+		if state == nil || base != after || recloned || state.SourceRef != ref.String() || state.SourceURL != proj.RepoURL {
+			var previous *string
+			if response.PreviousCommit != "" {
+				previous = &response.PreviousCommit
+			}
+			if err := SaveRepositoryState(req.ProjectID, previous, after.String(), proj.RepoURL, ref.String()); err != nil {
+				log.Printf("Error saving repository state: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
 		// Log the repository sync
-		message := fmt.Sprintf("Repository synced for project %d", req.ProjectID)
+		message := fmt.Sprintf("Repository synced for project %d at %s (%s)", req.ProjectID, after.String(), ref.Short())
+		if recloned {
+			message += ", re-cloned for the new source"
+		}
+		if response.Changes != nil {
+			changes := response.Changes
+			message += fmt.Sprintf(" (%d added, %d modified, %d deleted, %d renamed)", len(changes.Added), len(changes.Modified), len(changes.Deleted), len(changes.Renamed))
+		}
 		logging.LogActivity(cfg.LoggingServiceURL, "repo_synced", message, nil, &req.ProjectID, "info")
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(RepoResponse{Success: true, Message: "Repository synced successfully"})
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CommitTranslationsHandler commits translated files to the language branch,
+// attributed to their reviewers, and pushes it to the project's push remote
+func CommitTranslationsHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req CommitTranslationsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if !storage.ValidLanguage(req.Language) {
+			http.Error(w, fmt.Sprintf("Invalid language code: %s", req.Language), http.StatusBadRequest)
+			return
+		}
+
+		proj, err := project.GetProjectByID(req.ProjectID)
+		if err != nil {
+			writeProjectError(w, err)
+			return
+		}
+
+		unlock, err := storage.LockProject(req.ProjectID, cfg.ProjectLockTimeout)
+		if err != nil {
+			writeLockError(w, err)
+			return
+		}
+		defer unlock()
+
+		if err := writeTranslations(req.ProjectID, req.Language, req.Contents); err != nil {
+			if errors.Is(err, storage.ErrInvalidPath) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("Error writing translations: %v", err)
+			http.Error(w, "Failed to write translations", http.StatusInternalServerError)
+			return
+		}
+
+		hash, err := commitLanguage(cfg, proj, req.Language, project.TranslationCommit{
+			Files:     req.Files,
+			Providers: req.Providers,
+			Reviewers: reviewersOf(req.ProjectID, req.Language, req.Files),
+		})
+		if err != nil {
+			log.Printf("Error committing translations: %v", err)
+			message := fmt.Sprintf("Failed to commit %s translations for project %d: %v", req.Language, req.ProjectID, err)
+			logging.LogActivity(cfg.LoggingServiceURL, "translations_commit_failed", message, nil, &req.ProjectID, "error")
+			http.Error(w, "Failed to commit translations", http.StatusInternalServerError)
+			return
+		}
+		markCommitted(req)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CommitTranslationsResponse{Success: true, Commit: hash, Pushed: hash != "" && proj.PushRemoteURL != ""})
 	}
 }
 
+// writeProjectError reports a failed project lookup
+func writeProjectError(w http.ResponseWriter, err error) {
+	if err.Error() == "project not found" {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Error getting project: %v", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
+// writeLockError reports a project lock that could not be taken. Busy projects
+// get 409 Conflict so that callers can retry later.
+func writeLockError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrBusy) {
+		http.Error(w, "Project is busy", http.StatusConflict)
+		return
+	}
+	log.Printf("Error locking project: %v", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
 func DeleteRepoHandler(cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodDelete {
@@ -141,8 +433,14 @@ func DeleteRepoHandler(cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
-		repoPath := fmt.Sprintf("/repos/%d", req.ProjectID)
-		if err := os.RemoveAll(repoPath); err != nil {
+		unlock, err := storage.LockProject(req.ProjectID, cfg.ProjectLockTimeout)
+		if err != nil {
+			writeLockError(w, err)
+			return
+		}
+		defer unlock()
+
+		if err := storage.Repos.Remove(req.ProjectID); err != nil {
 			log.Printf("Error deleting repo: %v", err)
 			http.Error(w, "Failed to delete repository", http.StatusInternalServerError)
 			return
@@ -156,29 +454,38 @@ func DeleteRepoHandler(cfg *config.Config) http.HandlerFunc {
 	}
 }
 
-// Helper function to copy directory
-func copyDir(src, dst string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// StorageUsageHandler handles GET /projects/{id}/storage with the disk usage of
+// the project's source checkout and language worktrees
+func StorageUsageHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
 		}
 
-		relPath, err := filepath.Rel(src, path)
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/projects/"), "/"), "/")
+		if len(parts) != 2 || parts[1] != "storage" {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		projectID, err := strconv.Atoi(parts[0])
 		if err != nil {
-			return err
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
 		}
-
-		dstPath := filepath.Join(dst, relPath)
-
-		if info.IsDir() {
-			return os.MkdirAll(dstPath, info.Mode())
+		if _, err := project.GetProjectByID(projectID); err != nil {
+			writeProjectError(w, err)
+			return
 		}
 
-		data, err := os.ReadFile(path)
+		usage, err := storage.Repos.DiskUsage(projectID)
 		if err != nil {
-			return err
+			log.Printf("Error measuring disk usage: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
 		}
 
-		return os.WriteFile(dstPath, data, info.Mode())
-	})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(usage)
+	}
 }
@@ -1,7 +1,16 @@
 package repository
 
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+)
+
 type CloneRepoRequest struct {
-	RepoURL  string `json:"repoUrl"`
+	RepoURL   string `json:"repoUrl"`
 	ProjectID int    `json:"projectId"`
 }
 
@@ -18,7 +27,92 @@ type DeleteRepoRequest struct {
 	ProjectID int `json:"projectId"`
 }
 
+// CommitTranslationsRequest lists translated files to commit to a language branch.
+// Contents holds the rendered translation of files by path; they are written to
+// the language worktree while the project is locked, so they cannot race a sync.
+type CommitTranslationsRequest struct {
+	ProjectID int               `json:"projectId"`
+	Language  string            `json:"language"`
+	Files     []string          `json:"files"`
+	Contents  map[string]string `json:"contents,omitempty"`
+	Providers []string          `json:"providers"`
+}
+
+// CommitTranslationsResponse is returned by POST /internal/commit-translations.
+// Commit is empty when the files did not change.
+type CommitTranslationsResponse struct {
+	Success bool   `json:"success"`
+	Commit  string `json:"commit,omitempty"`
+	Pushed  bool   `json:"pushed"`
+}
+
 type RepoResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
 }
+
+// Rename is a source file moved between two commits
+type Rename struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Modified bool   `json:"modified"`
+}
+
+// FileChanges lists the source files changed between two commits
+type FileChanges struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Deleted  []string `json:"deleted"`
+	Renamed  []Rename `json:"renamed"`
+}
+
+// SyncRepoResponse is returned by PUT /internal/sync-repo
+type SyncRepoResponse struct {
+	Success        bool         `json:"success"`
+	Message        string       `json:"message,omitempty"`
+	PreviousCommit string       `json:"previousCommit,omitempty"`
+	CurrentCommit  string       `json:"currentCommit"`
+	Changes        *FileChanges `json:"changes,omitempty"`
+	// Recloned is set when the checkout was replaced to follow a new repository URL or ref
+	Recloned bool `json:"recloned,omitempty"`
+}
+
+// RepositoryState records the last synced commit of a project's source checkout
+// and the repository and reference it was cloned from
+type RepositoryState struct {
+	ProjectID      int       `json:"projectId"`
+	PreviousCommit *string   `json:"previousCommit,omitempty"`
+	CurrentCommit  string    `json:"currentCommit"`
+	SourceURL      string    `json:"sourceUrl"`
+	SourceRef      string    `json:"sourceRef"`
+	SyncedAt       time.Time `json:"syncedAt"`
+}
+
+// sourceChanged reports whether the checkout must be re-cloned to follow url and ref.
+// States recorded before the source was tracked are never re-cloned.
+func (s *RepositoryState) sourceChanged(url string, ref plumbing.ReferenceName) bool {
+	return s != nil && s.SourceRef != "" && (s.SourceURL != url || s.SourceRef != ref.String())
+}
+
+// GetRepositoryState returns the recorded state of a project, or nil if none exists
+func GetRepositoryState(projectID int) (*RepositoryState, error) {
+	state := &RepositoryState{}
+	query := `SELECT project_id, previous_commit, current_commit, source_url, source_ref, synced_at FROM repository_states WHERE project_id = $1`
+	err := db.DB.QueryRow(query, projectID).Scan(&state.ProjectID, &state.PreviousCommit, &state.CurrentCommit, &state.SourceURL, &state.SourceRef, &state.SyncedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return state, nil
+}
+
+// SaveRepositoryState records a new HEAD commit, keeping the prior one as previous_commit
+func SaveRepositoryState(projectID int, previousCommit *string, currentCommit, sourceURL, sourceRef string) error {
+	query := `INSERT INTO repository_states (project_id, previous_commit, current_commit, source_url, source_ref, synced_at) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (project_id) DO UPDATE SET previous_commit = EXCLUDED.previous_commit, current_commit = EXCLUDED.current_commit,
+			source_url = EXCLUDED.source_url, source_ref = EXCLUDED.source_ref, synced_at = EXCLUDED.synced_at`
+	_, err := db.DB.Exec(query, projectID, previousCommit, currentCommit, sourceURL, sourceRef, time.Now())
+	return err
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
+)
+
+// sourceRemote is the remote of the source repository in a checkout
+const sourceRemote = "origin"
+
+// errSourceRefNotFound is returned when the source ref does not exist in the remote
+var errSourceRefNotFound = errors.New("source ref not found")
+
+// resolveSourceRef returns the remote reference that ref names: a full reference,
+// a branch or a tag, in that order. An empty ref is the remote's default branch.
+func resolveSourceRef(url, ref string, auth transport.AuthMethod) (plumbing.ReferenceName, error) {
+	remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{Name: sourceRemote, URLs: []string{url}})
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return "", fmt.Errorf("failed to list remote references: %w", err)
+	}
+
+	if ref == "" {
+		var head *plumbing.Reference
+		for _, r := range refs {
+			if r.Name() == plumbing.HEAD {
+				head = r
+			}
+		}
+		if head == nil {
+			return "", fmt.Errorf("%w: remote has no default branch", errSourceRefNotFound)
+		}
+		if head.Type() == plumbing.SymbolicReference {
+			return head.Target(), nil
+		}
+		// Servers that do not advertise the HEAD symref: pick the branch at the same commit
+		for _, r := range refs {
+			if r.Name().IsBranch() && r.Hash() == head.Hash() {
+				return r.Name(), nil
+			}
+		}
+		return "", fmt.Errorf("%w: remote has no default branch", errSourceRefNotFound)
+	}
+
+	candidates := []plumbing.ReferenceName{plumbing.ReferenceName(ref)}
+	if !strings.HasPrefix(ref, "refs/") {
+		candidates = []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)}
+	}
+	for _, candidate := range candidates {
+		for _, r := range refs {
+			if r.Name() == candidate {
+				return candidate, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s", errSourceRefNotFound, ref)
+}
+
+// cloneSource makes a shallow, single-branch clone of ref at path
+func cloneSource(path, url string, ref plumbing.ReferenceName, auth transport.AuthMethod) (*git.Repository, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	return git.PlainClone(path, false, &git.CloneOptions{
+		URL:           url,
+		Auth:          auth,
+		ReferenceName: ref,
+		SingleBranch:  true,
+		Depth:         1,
+		Tags:          git.NoTags,
+	})
+}
+
+// fetchSource fetches the tip of ref into a shallow checkout and moves the source
+// worktree to it. The checkout is never modified locally, so it is reset rather
+// than merged. It returns the new HEAD commit.
+func fetchSource(repo *git.Repository, ref plumbing.ReferenceName, auth transport.AuthMethod) (plumbing.Hash, error) {
+	local := trackingRef(ref)
+	err := repo.Fetch(&git.FetchOptions{
+		RemoteName: sourceRemote,
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec("+" + ref.String() + ":" + local.String())},
+		Depth:      1,
+		Auth:       auth,
+		Tags:       git.NoTags,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return plumbing.ZeroHash, fmt.Errorf("failed to fetch %s: %w", ref, err)
+	}
+
+	tip, err := repo.Reference(local, true)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	commit, err := peelCommit(repo, tip.Hash())
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := worktree.Reset(&git.ResetOptions{Commit: commit, Mode: git.HardReset}); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to check out %s: %w", commit, err)
+	}
+	return commit, nil
+}
+
+// trackingRef is where a fetched source ref is stored, e.g. refs/remotes/origin/main for a branch
+func trackingRef(ref plumbing.ReferenceName) plumbing.ReferenceName {
+	if ref.IsBranch() {
+		return plumbing.NewRemoteReferenceName(sourceRemote, ref.Short())
+	}
+	return ref
+}
+
+// peelCommit returns the commit an annotated tag points to, or hash itself
+func peelCommit(repo *git.Repository, hash plumbing.Hash) (plumbing.Hash, error) {
+	tag, err := repo.TagObject(hash)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return hash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	commit, err := tag.Commit()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return commit.Hash, nil
+}
+
+// stagingPath is where a replacement clone is prepared before it takes over repoPath
+func stagingPath(repoPath string) string {
+	return repoPath + ".reclone"
+}
+
+// replaceSource swaps the source checkout at repoPath for the clone at staging.
+// The language branches are carried over and their worktrees recreated, so
+// translations committed to them survive a change of the source ref.
+func replaceSource(repoPath, staging, worktreesPath string, languages []string) error {
+	if _, err := os.Stat(repoPath); err == nil {
+		if _, err := runGit(staging, "fetch", "--update-shallow", repoPath, "+refs/heads/"+languageBranchPrefix+"*:refs/heads/"+languageBranchPrefix+"*"); err != nil {
+			return fmt.Errorf("failed to carry over language branches: %w", err)
+		}
+	}
+
+	previous := repoPath + ".previous"
+	if err := os.RemoveAll(previous); err != nil {
+		return err
+	}
+	if err := os.Rename(repoPath, previous); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(staging, repoPath); err != nil {
+		// Put the old checkout back so the project keeps working
+		if restoreErr := os.Rename(previous, repoPath); restoreErr != nil {
+			return fmt.Errorf("failed to replace checkout: %w (restore failed: %v)", err, restoreErr)
+		}
+		return err
+	}
+
+	// The old worktrees belong to the old checkout
+	if err := os.RemoveAll(worktreesPath); err != nil {
+		return err
+	}
+	for _, lang := range languages {
+		if !storage.ValidLanguage(lang) {
+			continue
+		}
+		if _, err := runGit(repoPath, "rev-parse", "--verify", "--quiet", "refs/heads/"+LanguageBranch(lang)); err != nil {
+			continue
+		}
+		if err := AddLanguageWorktree(repoPath, filepath.Join(worktreesPath, lang), lang); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(previous)
+}
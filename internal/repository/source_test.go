@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+)
+
+// upstreamRepo creates a repository with a main branch, a v2 branch and an annotated v1.0 tag
+func upstreamRepo(t *testing.T) (string, *git.Repository) {
+	dir := filepath.Join(t.TempDir(), "upstream")
+	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+	})
+	require.NoError(t, err)
+
+	release := commitFiles(t, repo, dir, map[string]string{"docs/intro.md": "# Intro\n", "README.md": "# Project\n"}, nil)
+	_, err = repo.CreateTag("v1.0", release, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Message: "v1.0",
+	})
+	require.NoError(t, err)
+
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("v2"), Create: true}))
+	commitFiles(t, repo, dir, map[string]string{"docs/v2.md": "# Version 2\n"}, nil)
+	require.NoError(t, worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("main")}))
+	commitFiles(t, repo, dir, map[string]string{"docs/setup.md": "# Setup\n"}, nil)
+	return dir, repo
+}
+
+func TestResolveSourceRef(t *testing.T) {
+	url, _ := upstreamRepo(t)
+
+	for ref, expected := range map[string]string{
+		"":                "refs/heads/main",
+		"v2":              "refs/heads/v2",
+		"v1.0":            "refs/tags/v1.0",
+		"refs/heads/main": "refs/heads/main",
+	} {
+		name, err := resolveSourceRef(url, ref, nil)
+		require.NoError(t, err, ref)
+		require.Equal(t, expected, name.String(), ref)
+	}
+
+	_, err := resolveSourceRef(url, "v3", nil)
+	require.ErrorIs(t, err, errSourceRefNotFound)
+}
+
+func TestCloneAndFetchSource(t *testing.T) {
+	url, upstream := upstreamRepo(t)
+	path := filepath.Join(t.TempDir(), "1")
+
+	repo, err := cloneSource(path, url, plumbing.NewBranchReferenceName("main"), nil)
+	require.NoError(t, err)
+	before, err := headCommit(repo)
+	require.NoError(t, err)
+
+	// Shallow and single-branch
+	_, err = os.Stat(filepath.Join(path, ".git", "shallow"))
+	require.NoError(t, err)
+	_, err = repo.Reference(plumbing.NewRemoteReferenceName(sourceRemote, "v2"), false)
+	require.Error(t, err)
+
+	// Nothing new upstream
+	after, err := fetchSource(repo, plumbing.NewBranchReferenceName("main"), nil)
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+
+	head := commitFiles(t, upstream, url, map[string]string{"docs/setup.md": "# Setup\n\nUpdated.\n"}, []string{"README.md"})
+	after, err = fetchSource(repo, plumbing.NewBranchReferenceName("main"), nil)
+	require.NoError(t, err)
+	require.Equal(t, head, after)
+
+	content, err := os.ReadFile(filepath.Join(path, "docs/setup.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Setup\n\nUpdated.\n", string(content))
+
+	changes, err := DiffCommits(repo, before, after)
+	require.NoError(t, err)
+	require.Equal(t, []string{"docs/setup.md"}, changes.Modified)
+	require.Equal(t, []string{"README.md"}, changes.Deleted)
+}
+
+func TestCloneSourceTag(t *testing.T) {
+	url, upstream := upstreamRepo(t)
+	tag, err := upstream.Tag("v1.0")
+	require.NoError(t, err)
+	release, err := peelCommit(upstream, tag.Hash())
+	require.NoError(t, err)
+
+	repo, err := cloneSource(filepath.Join(t.TempDir(), "1"), url, plumbing.NewTagReferenceName("v1.0"), nil)
+	require.NoError(t, err)
+	head, err := headCommit(repo)
+	require.NoError(t, err)
+	require.Equal(t, release, head)
+
+	after, err := fetchSource(repo, plumbing.NewTagReferenceName("v1.0"), nil)
+	require.NoError(t, err)
+	require.Equal(t, release, after)
+}
+
+func TestReplaceSourceKeepsLanguageBranches(t *testing.T) {
+	url, _ := upstreamRepo(t)
+	root := t.TempDir()
+	repoPath := filepath.Join(root, "1")
+	worktreesPath := filepath.Join(root, "worktrees", "1")
+
+	_, err := cloneSource(repoPath, url, plumbing.NewBranchReferenceName("main"), nil)
+	require.NoError(t, err)
+	esPath := filepath.Join(worktreesPath, "es")
+	require.NoError(t, AddLanguageWorktree(repoPath, esPath, "es"))
+	require.NoError(t, os.WriteFile(filepath.Join(esPath, "docs/intro.md"), []byte("# Introducción\n"), 0644))
+	_, err = runGit(esPath, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-qam", "Translate intro")
+	require.NoError(t, err)
+
+	staging := stagingPath(repoPath)
+	clone, err := cloneSource(staging, url, plumbing.NewBranchReferenceName("v2"), nil)
+	require.NoError(t, err)
+	v2, err := headCommit(clone)
+	require.NoError(t, err)
+
+	require.NoError(t, replaceSource(repoPath, staging, worktreesPath, []string{"es", "fr"}))
+
+	_, err = os.Stat(staging)
+	require.True(t, os.IsNotExist(err))
+	head, err := runGit(repoPath, "rev-parse", "HEAD")
+	require.NoError(t, err)
+	require.Equal(t, v2.String(), strings.TrimSpace(head))
+
+	// The translation survives on its branch and in its recreated worktree
+	subject, err := runGit(repoPath, "log", "-1", "--format=%s", LanguageBranch("es"))
+	require.NoError(t, err)
+	require.Equal(t, "Translate intro", strings.TrimSpace(subject))
+	content, err := os.ReadFile(filepath.Join(esPath, "docs/intro.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Introducción\n", string(content))
+	require.Equal(t, []string{"es"}, existingWorktrees(worktreesPath, []string{"es", "fr"}))
+}
+
+func TestRestrictChanges(t *testing.T) {
+	inDocs := func(path string) bool { return strings.HasPrefix(path, "docs/") }
+	changes := restrictChanges(&FileChanges{
+		Added:    []string{"docs/new.md", "src/main.go"},
+		Modified: []string{"README.md", "docs/intro.md"},
+		Deleted:  []string{"docs/old.md"},
+		Renamed: []Rename{
+			{From: "docs/a.md", To: "docs/b.md"},
+			{From: "docs/c.md", To: "archive/c.md"},
+			{From: "drafts/d.md", To: "docs/d.md", Modified: true},
+		},
+	}, inDocs)
+
+	require.Equal(t, &FileChanges{
+		Added:    []string{"docs/d.md", "docs/new.md"},
+		Modified: []string{"docs/intro.md"},
+		Deleted:  []string{"docs/c.md", "docs/old.md"},
+		Renamed:  []Rename{{From: "docs/a.md", To: "docs/b.md"}},
+	}, changes)
+}
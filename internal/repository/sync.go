@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/queue"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
+	"github.com/xeodocs/xeodocs-backend/internal/translation"
+)
+
+// translateBatchSize caps the number of files sent in a single translate_files task
+const translateBatchSize = 50
+
+// propagateChanges mirrors source changes into every language copy of a project.
+// Deleted and renamed files are applied directly, other files are copied as-is
+// and translatable files are marked pending and enqueued for translation, except
+// for reviewed files, whose changed segments are marked stale instead.
+// The mirrored files are committed to each language branch. tree is the source
+// tree at sourceCommit, after the changes, and provides the blob hashes.
+func propagateChanges(cfg *config.Config, projectID int, sourceCommit string, changes *FileChanges, tree *object.Tree) error {
+	proj, err := project.GetProjectByID(projectID)
+	if err != nil {
+		return fmt.Errorf("failed to get project: %w", err)
+	}
+
+	repoPath := storage.Repos.ProjectPath(projectID)
+
+	// Files excluded from translation are mirrored as-is
+	var toTranslate, toCopy []string
+	for _, file := range append(append([]string{}, changes.Added...), changes.Modified...) {
+		if isTranslatable(proj, file) && isRegularFile(tree, file) {
+			toTranslate = append(toTranslate, file)
+		} else {
+			toCopy = append(toCopy, file)
+		}
+	}
+	for _, rename := range changes.Renamed {
+		if rename.Modified {
+			if isTranslatable(proj, rename.To) && isRegularFile(tree, rename.To) {
+				toTranslate = append(toTranslate, rename.To)
+			} else {
+				toCopy = append(toCopy, rename.To)
+			}
+		}
+	}
+
+	for _, file := range changes.Deleted {
+		if err := translation.DeleteTranslationFiles(projectID, file); err != nil {
+			return fmt.Errorf("failed to delete translation status of %s: %w", file, err)
+		}
+	}
+	for _, rename := range changes.Renamed {
+		if err := translation.RenameTranslationFiles(projectID, rename.From, rename.To); err != nil {
+			return fmt.Errorf("failed to rename translation status of %s: %w", rename.From, err)
+		}
+	}
+
+	documents := sourceDocuments(tree, toTranslate)
+
+	mirrored := append(append([]string{}, changes.Deleted...), toCopy...)
+	for _, rename := range changes.Renamed {
+		mirrored = append(mirrored, rename.From, rename.To)
+	}
+
+	for _, lang := range existingWorktrees(storage.Repos.WorktreesPath(projectID), proj.Languages) {
+		langPath, err := storage.Repos.LanguagePath(projectID, lang)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range changes.Deleted {
+			if err := os.Remove(filepath.Join(langPath, file)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to delete %s from %s copy: %w", file, lang, err)
+			}
+		}
+
+		for _, rename := range changes.Renamed {
+			target := filepath.Join(langPath, rename.To)
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Rename(filepath.Join(langPath, rename.From), target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to rename %s in %s copy: %w", rename.From, lang, err)
+			}
+		}
+
+		for _, file := range toCopy {
+			if err := copyFile(filepath.Join(repoPath, file), filepath.Join(langPath, file)); err != nil {
+				return fmt.Errorf("failed to copy %s to %s copy: %w", file, lang, err)
+			}
+		}
+
+		// Reviewed files only have their changed segments marked stale, to be
+		// updated by an editor, and are not retranslated
+		var pending []string
+		for _, file := range toTranslate {
+			tracked, stale := false, false
+			if doc := documents[file]; doc != nil {
+				tracked, stale, err = translation.MarkSegmentsStale(projectID, lang, file, blobHash(tree, file), sourceCommit, doc)
+				if err != nil {
+					return fmt.Errorf("failed to update stale segments of %s: %w", file, err)
+				}
+			}
+			if !tracked {
+				if err := translation.MarkFilePending(projectID, lang, file, blobHash(tree, file)); err != nil {
+					return fmt.Errorf("failed to update translation status of %s: %w", file, err)
+				}
+			}
+			if !stale {
+				pending = append(pending, file)
+			}
+		}
+
+		if len(mirrored) > 0 {
+			_, err := commitLanguage(cfg, proj, lang, project.TranslationCommit{
+				SourceCommit: sourceCommit,
+				Files:        mirrored,
+				Summary:      fmt.Sprintf("Sync %d files from source %s", len(mirrored), shortCommit(sourceCommit)),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := enqueueTranslations(cfg, projectID, lang, pending); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enqueueTranslations publishes translate_files tasks in batches of translateBatchSize
+func enqueueTranslations(cfg *config.Config, projectID int, language string, files []string) error {
+	for start := 0; start < len(files); start += translateBatchSize {
+		end := min(start+translateBatchSize, len(files))
+		payload := map[string]interface{}{
+			"projectId": projectID,
+			"language":  language,
+			"files":     files[start:end],
+		}
+		id := fmt.Sprintf("translate-%d-%s-%d", projectID, language, time.Now().UnixNano())
+		if err := queue.PublishTask(cfg, "translate_files", "translate_files", id, payload); err != nil {
+			return fmt.Errorf("failed to enqueue translate_files for %s: %w", language, err)
+		}
+		log.Printf("Enqueued translate_files for project %d (%s): %d files", projectID, language, end-start)
+	}
+	return nil
+}
+
+// isTranslatable reports whether a source file is translated: a supported format
+// inside the docs root that the project's include and exclude patterns select
+func isTranslatable(proj *project.Project, file string) bool {
+	return proj.InDocsRoot(file) && translation.IsSupportedFile(file) && proj.FileRules().Translatable(file)
+}
+
+// isRegularFile reports whether path is a regular or executable file of tree.
+// Symlinks are never translated: their target may be any file of the host.
+func isRegularFile(tree *object.Tree, path string) bool {
+	f, err := tree.File(path)
+	if err != nil {
+		return false
+	}
+	return isRegularMode(f.Mode)
+}
+
+// isRegularMode reports whether a tree entry mode is a regular or executable file
+func isRegularMode(mode filemode.FileMode) bool {
+	return mode == filemode.Regular || mode == filemode.Executable || mode == filemode.Deprecated
+}
+
+// trackTranslatableFiles records every translatable file of tree as pending in each language
+func trackTranslatableFiles(proj *project.Project, languages []string, tree *object.Tree) error {
+	return tree.Files().ForEach(func(f *object.File) error {
+		if !isRegularMode(f.Mode) || !isTranslatable(proj, f.Name) {
+			return nil
+		}
+		for _, lang := range languages {
+			if err := translation.MarkFilePending(proj.ID, lang, f.Name, f.Hash.String()); err != nil {
+				return fmt.Errorf("failed to update translation status of %s: %w", f.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// sourceDocuments parses the translatable files of tree into segments, by path.
+// Files that cannot be read or parsed are left out.
+func sourceDocuments(tree *object.Tree, files []string) map[string]*translation.Document {
+	documents := make(map[string]*translation.Document, len(files))
+	for _, file := range files {
+		f, err := tree.File(file)
+		if err != nil {
+			continue
+		}
+		content, err := f.Contents()
+		if err != nil {
+			log.Printf("Error reading %s for stale segments: %v", file, err)
+			continue
+		}
+		doc, err := translation.ParseDocument(file, content)
+		if err != nil {
+			log.Printf("Error parsing %s for stale segments: %v", file, err)
+			continue
+		}
+		documents[file] = doc
+	}
+	return documents
+}
+
+// shortCommit abbreviates a commit hash for messages
+func shortCommit(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// blobHash returns the blob hash of path in tree, or an empty string if it is missing
+func blobHash(tree *object.Tree, path string) string {
+	f, err := tree.File(path)
+	if err != nil {
+		return ""
+	}
+	return f.Hash.String()
+}
+
+// copyFile mirrors a file of the source checkout into a language copy. Symlinks
+// are copied as links and never followed, and other special files are skipped.
+func copyFile(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink == 0 && !info.Mode().IsRegular() {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+	data, err := storage.ReadRegularFile(src)
+	if err != nil {
+		return err
+	}
+	if err := removeSpecialFile(dst); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, info.Mode().Perm())
+}
+
+// removeSpecialFile deletes path if it is a symlink or another special file, so
+// that writing to it does not write through a link left by an earlier sync
+func removeSpecialFile(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode().IsRegular() || info.IsDir() {
+		return nil
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
+)
+
+func TestSymlinkedSourceFilesAreNotRead(t *testing.T) {
+	root := t.TempDir()
+	secret := filepath.Join(root, "service.env")
+	require.NoError(t, os.WriteFile(secret, []byte("CREDENTIALS_KEY=secret\n"), 0600))
+
+	dir := filepath.Join(root, "repo")
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "docs"), 0755))
+	require.NoError(t, os.Symlink(secret, filepath.Join(dir, "docs/x.md")))
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = worktree.Add("docs/x.md")
+	require.NoError(t, err)
+	head := commitFiles(t, repo, dir, map[string]string{"docs/intro.md": "# Intro\n"}, nil)
+	tree, err := commitTree(repo, head)
+	require.NoError(t, err)
+
+	// The symlink is neither listed nor translated
+	proj := &project.Project{DocsRoot: "docs"}
+	require.True(t, isRegularFile(tree, "docs/intro.md"))
+	require.False(t, isRegularFile(tree, "docs/x.md"))
+	preview, err := previewFiles(proj, proj.FileRules(), tree)
+	require.NoError(t, err)
+	require.Equal(t, []string{"docs/intro.md"}, preview.Files)
+
+	_, err = storage.ReadRegularFile(filepath.Join(dir, "docs/x.md"))
+	require.ErrorIs(t, err, storage.ErrNotRegularFile)
+	content, err := storage.ReadRegularFile(filepath.Join(dir, "docs/intro.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Intro\n", string(content))
+
+	// Mirroring copies the link, not the file it points to
+	langPath := filepath.Join(root, "es")
+	require.NoError(t, copyFile(filepath.Join(dir, "docs/x.md"), filepath.Join(langPath, "docs/x.md")))
+	target, err := os.Readlink(filepath.Join(langPath, "docs/x.md"))
+	require.NoError(t, err)
+	require.Equal(t, secret, target)
+
+	// A regular file replaces the link instead of writing through it
+	require.NoError(t, copyFile(filepath.Join(dir, "docs/intro.md"), filepath.Join(langPath, "docs/x.md")))
+	info, err := os.Lstat(filepath.Join(langPath, "docs/x.md"))
+	require.NoError(t, err)
+	require.True(t, info.Mode().IsRegular())
+	content, err = os.ReadFile(secret)
+	require.NoError(t, err)
+	require.Equal(t, "CREDENTIALS_KEY=secret\n", string(content))
+}
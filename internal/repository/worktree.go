@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
+)
+
+// languageBranchPrefix namespaces the branches that hold translated copies
+const languageBranchPrefix = "xeodocs/"
+
+// LanguageBranch is the branch checked out in a language worktree, e.g. xeodocs/es
+func LanguageBranch(language string) string {
+	return languageBranchPrefix + language
+}
+
+// AddLanguageWorktree checks out the language branch of the repository at repoPath
+// into worktreePath. A new branch starts at the source HEAD; an existing branch
+// keeps its history. Nothing is done when the worktree already exists.
+func AddLanguageWorktree(repoPath, worktreePath, language string) error {
+	if !storage.ValidLanguage(language) {
+		return fmt.Errorf("%w: %q", storage.ErrInvalidLanguage, language)
+	}
+	if isWorktree(worktreePath) {
+		return nil
+	}
+
+	// Forget worktrees whose directories were removed so their branches can be checked out again
+	if _, err := runGit(repoPath, "worktree", "prune"); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(worktreePath), 0755); err != nil {
+		return err
+	}
+
+	branch := LanguageBranch(language)
+	if _, err := runGit(repoPath, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		_, err = runGit(repoPath, "worktree", "add", worktreePath, branch)
+		return err
+	}
+	_, err := runGit(repoPath, "worktree", "add", "-b", branch, worktreePath, "HEAD")
+	return err
+}
+
+// isWorktree reports whether path is the root of a linked worktree
+func isWorktree(path string) bool {
+	info, err := os.Stat(filepath.Join(path, ".git"))
+	return err == nil && !info.IsDir()
+}
+
+// existingWorktrees returns the languages that have a worktree under worktreesPath
+func existingWorktrees(worktreesPath string, languages []string) []string {
+	var existing []string
+	for _, lang := range languages {
+		if storage.ValidLanguage(lang) && isWorktree(filepath.Join(worktreesPath, lang)) {
+			existing = append(existing, lang)
+		}
+	}
+	return existing
+}
+
+// runGit runs the git command line in dir. go-git does not manage linked worktrees.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
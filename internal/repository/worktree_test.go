@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
+)
+
+func TestAddLanguageWorktree(t *testing.T) {
+	root := t.TempDir()
+	repoPath := filepath.Join(root, "repos", "1")
+	require.NoError(t, os.MkdirAll(repoPath, 0755))
+	repo, err := git.PlainInit(repoPath, false)
+	require.NoError(t, err)
+	head := commitFiles(t, repo, repoPath, map[string]string{"docs/intro.md": "# Intro\n"}, nil)
+
+	worktreePath := filepath.Join(root, "worktrees", "1", "es")
+	require.NoError(t, AddLanguageWorktree(repoPath, worktreePath, "es"))
+
+	// The copy lives outside the source checkout, on its own branch starting at HEAD
+	content, err := os.ReadFile(filepath.Join(worktreePath, "docs/intro.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Intro\n", string(content))
+	_, err = os.Stat(filepath.Join(repoPath, "es"))
+	require.True(t, os.IsNotExist(err))
+
+	branch, err := runGit(worktreePath, "rev-parse", "--abbrev-ref", "HEAD")
+	require.NoError(t, err)
+	require.Equal(t, "xeodocs/es", strings.TrimSpace(branch))
+	tip, err := runGit(repoPath, "rev-parse", "refs/heads/xeodocs/es")
+	require.NoError(t, err)
+	require.Equal(t, head.String(), strings.TrimSpace(tip))
+
+	// Creating it again is a no-op
+	require.NoError(t, AddLanguageWorktree(repoPath, worktreePath, "es"))
+	require.Equal(t, []string{"es"}, existingWorktrees(filepath.Join(root, "worktrees", "1"), []string{"es", "fr"}))
+
+	// A removed worktree is recreated from the existing branch, keeping its commits
+	require.NoError(t, os.WriteFile(filepath.Join(worktreePath, "docs/intro.md"), []byte("# Introducción\n"), 0644))
+	_, err = runGit(worktreePath, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-am", "Translate intro")
+	require.NoError(t, err)
+	require.NoError(t, os.RemoveAll(worktreePath))
+
+	require.NoError(t, AddLanguageWorktree(repoPath, worktreePath, "es"))
+	content, err = os.ReadFile(filepath.Join(worktreePath, "docs/intro.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Introducción\n", string(content))
+
+	require.Error(t, AddLanguageWorktree(repoPath, filepath.Join(root, "worktrees", "1", "x"), "../x"))
+}
+
+func TestWriteTranslations(t *testing.T) {
+	previous := storage.Repos
+	storage.Repos = storage.NewWorkspace(t.TempDir())
+	t.Cleanup(func() { storage.Repos = previous })
+
+	// Nothing is written for a language copy that does not exist
+	err := writeTranslations(1, "es", map[string]string{"docs/intro.md": "# Introducción\n"})
+	require.Error(t, err)
+	_, err = os.Stat(storage.Repos.WorktreesPath(1))
+	require.True(t, os.IsNotExist(err))
+
+	langPath, err := storage.Repos.LanguagePath(1, "es")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(langPath, 0755))
+	require.NoError(t, writeTranslations(1, "es", map[string]string{"docs/guide/intro.md": "# Introducción\n"}))
+	content, err := os.ReadFile(filepath.Join(langPath, "docs/guide/intro.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Introducción\n", string(content))
+
+	err = writeTranslations(1, "es", map[string]string{"../fr/intro.md": "x"})
+	require.ErrorIs(t, err, storage.ErrInvalidPath)
+}
@@ -11,6 +11,26 @@ import (
 	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
 )
 
+// IDs of the roles seeded by the first migration
+const (
+	RoleAdmin  = 1
+	RoleEditor = 2
+	RoleViewer = 3
+)
+
+// HasRole reports whether the claims satisfy requiredRole. Admins satisfy every
+// role, editors satisfy "editor", and an empty role only requires a valid token.
+func HasRole(claims *Claims, requiredRole string) bool {
+	switch requiredRole {
+	case "":
+		return true
+	case "editor":
+		return claims.RoleID == RoleAdmin || claims.RoleID == RoleEditor
+	default:
+		return claims.RoleID == RoleAdmin
+	}
+}
+
 type Claims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
@@ -71,7 +91,7 @@ func JWTMiddleware(cfg *config.Config, requiredRole string) func(http.HandlerFun
 				return
 			}
 
-			if requiredRole != "" && claims.RoleID != 1 {
+			if !HasRole(claims, requiredRole) {
 				http.Error(w, "Insufficient permissions", http.StatusForbidden)
 				return
 			}
@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasRole(t *testing.T) {
+	admin := &Claims{RoleID: RoleAdmin}
+	editor := &Claims{RoleID: RoleEditor}
+	viewer := &Claims{RoleID: RoleViewer}
+
+	require.True(t, HasRole(viewer, ""))
+	require.True(t, HasRole(admin, "editor"))
+	require.True(t, HasRole(editor, "editor"))
+	require.False(t, HasRole(viewer, "editor"))
+	require.True(t, HasRole(admin, "admin"))
+	require.False(t, HasRole(editor, "admin"))
+}
@@ -0,0 +1,38 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE IF NOT EXISTS translation_memory (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL,
+    language VARCHAR(35) NOT NULL,
+    source_hash CHAR(64) NOT NULL,
+    source_text TEXT NOT NULL,
+    target_text TEXT NOT NULL,
+    origin VARCHAR(20) NOT NULL DEFAULT 'machine',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+    UNIQUE (project_id, language, source_hash)
+);
+
+-- Trigram index for fuzzy matching
+CREATE INDEX IF NOT EXISTS idx_translation_memory_source_trgm ON translation_memory USING gin (source_text gin_trgm_ops);
+
+CREATE TABLE IF NOT EXISTS translation_jobs (
+    id VARCHAR(255) PRIMARY KEY,
+    project_id INTEGER NOT NULL,
+    language VARCHAR(35) NOT NULL,
+    files INTEGER NOT NULL DEFAULT 0,
+    segments INTEGER NOT NULL DEFAULT 0,
+    tm_exact_hits INTEGER NOT NULL DEFAULT 0,
+    tm_fuzzy_hits INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_translation_jobs_project_id ON translation_jobs(project_id);
+
+-- +goose Down
+DROP TABLE translation_jobs;
+DROP TABLE translation_memory;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS glossary_terms (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL,
+    language VARCHAR(35) NOT NULL DEFAULT '',
+    term TEXT NOT NULL,
+    translation TEXT NOT NULL DEFAULT '',
+    do_not_translate BOOLEAN NOT NULL DEFAULT FALSE,
+    case_sensitive BOOLEAN NOT NULL DEFAULT FALSE,
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+    UNIQUE (project_id, language, term)
+);
+
+CREATE INDEX IF NOT EXISTS idx_glossary_terms_project_language ON glossary_terms(project_id, language);
+
+-- +goose Down
+DROP TABLE glossary_terms;
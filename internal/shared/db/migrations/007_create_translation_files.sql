@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS translation_files (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL,
+    language VARCHAR(35) NOT NULL,
+    source_path TEXT NOT NULL,
+    source_hash VARCHAR(40),
+    translated_hash VARCHAR(40),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    provider VARCHAR(50),
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    translated_at TIMESTAMP,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+    UNIQUE (project_id, language, source_path)
+);
+
+CREATE INDEX IF NOT EXISTS idx_translation_files_project_language_status ON translation_files(project_id, language, status);
+
+-- +goose Down
+DROP TABLE translation_files;
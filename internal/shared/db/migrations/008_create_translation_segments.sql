@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS translation_segments (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL,
+    language VARCHAR(35) NOT NULL,
+    source_path TEXT NOT NULL,
+    segment_index INTEGER NOT NULL,
+    context VARCHAR(100) NOT NULL DEFAULT '',
+    source_hash CHAR(64) NOT NULL,
+    source_text TEXT NOT NULL,
+    target_text TEXT NOT NULL DEFAULT '',
+    origin VARCHAR(20) NOT NULL DEFAULT 'machine',
+    placeholders JSONB,
+    updated_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+    FOREIGN KEY (updated_by) REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE (project_id, language, source_path, segment_index)
+);
+
+ALTER TABLE translation_files ADD COLUMN IF NOT EXISTS reviewed_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE translation_files ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP;
+ALTER TABLE translation_files ADD COLUMN IF NOT EXISTS review_comment TEXT;
+
+-- +goose Down
+ALTER TABLE translation_files DROP COLUMN IF EXISTS review_comment;
+ALTER TABLE translation_files DROP COLUMN IF EXISTS reviewed_at;
+ALTER TABLE translation_files DROP COLUMN IF EXISTS reviewed_by;
+DROP TABLE translation_segments;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS translation_qa_findings (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL,
+    language VARCHAR(35) NOT NULL,
+    source_path TEXT NOT NULL,
+    check_name VARCHAR(30) NOT NULL,
+    severity VARCHAR(10) NOT NULL,
+    segment_index INTEGER,
+    message TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_translation_qa_findings_file ON translation_qa_findings(project_id, language, source_path);
+
+-- +goose Down
+DROP TABLE translation_qa_findings;
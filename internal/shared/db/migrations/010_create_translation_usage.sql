@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS translation_usage (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL,
+    language VARCHAR(35) NOT NULL,
+    job_id VARCHAR(255),
+    source_path TEXT NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    requests INTEGER NOT NULL DEFAULT 0,
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    cost NUMERIC(12, 6) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_translation_usage_project_created ON translation_usage(project_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_translation_usage_job ON translation_usage(job_id);
+
+-- +goose Down
+DROP TABLE translation_usage;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS prompt_template TEXT NOT NULL DEFAULT '';
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS language_prompts JSONB NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE projects DROP COLUMN IF EXISTS language_prompts;
+ALTER TABLE projects DROP COLUMN IF EXISTS prompt_template;
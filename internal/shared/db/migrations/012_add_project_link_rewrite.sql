@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS link_rewrite JSONB NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE projects DROP COLUMN IF EXISTS link_rewrite;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS push_remote_url TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE projects DROP COLUMN IF EXISTS push_remote_url;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS project_credentials (
+    project_id INTEGER PRIMARY KEY,
+    type VARCHAR(10) NOT NULL,
+    username VARCHAR(255) NOT NULL DEFAULT '',
+    secret BYTEA NOT NULL,
+    known_hosts TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE project_credentials;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS source_ref TEXT NOT NULL DEFAULT '';
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS docs_root TEXT NOT NULL DEFAULT '';
+ALTER TABLE repository_states ADD COLUMN IF NOT EXISTS source_ref TEXT NOT NULL DEFAULT '';
+ALTER TABLE repository_states ADD COLUMN IF NOT EXISTS source_url TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE repository_states DROP COLUMN IF EXISTS source_url;
+ALTER TABLE repository_states DROP COLUMN IF EXISTS source_ref;
+ALTER TABLE projects DROP COLUMN IF EXISTS docs_root;
+ALTER TABLE projects DROP COLUMN IF EXISTS source_ref;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS include_patterns JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS exclude_patterns JSONB NOT NULL DEFAULT '[]';
+
+-- +goose Down
+ALTER TABLE projects DROP COLUMN IF EXISTS exclude_patterns;
+ALTER TABLE projects DROP COLUMN IF EXISTS include_patterns;
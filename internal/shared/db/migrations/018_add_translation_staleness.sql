@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE translation_files ADD COLUMN IF NOT EXISTS stale_since VARCHAR(64);
+ALTER TABLE translation_segments ADD COLUMN IF NOT EXISTS stale_since VARCHAR(64);
+ALTER TABLE translation_segments ADD COLUMN IF NOT EXISTS previous_source TEXT;
+
+-- +goose Down
+ALTER TABLE translation_segments DROP COLUMN IF EXISTS previous_source;
+ALTER TABLE translation_segments DROP COLUMN IF EXISTS stale_since;
+ALTER TABLE translation_files DROP COLUMN IF EXISTS stale_since;
@@ -0,0 +1,92 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+)
+
+// PublishTask publishes a worker task to the given durable queue
+func PublishTask(cfg *config.Config, queueName, taskType, id string, payload map[string]interface{}) error {
+	task := map[string]interface{}{
+		"type":    taskType,
+		"payload": payload,
+		"id":      id,
+	}
+	return publish(cfg, queueName, nil, task)
+}
+
+// DelayedQueue is the holding queue of queueName for one delay. Messages expire
+// from it into queueName. RabbitMQ only expires the message at the head of a
+// queue, so each delay has its own queue and a short retry never waits behind a
+// long one.
+func DelayedQueue(queueName string, delay time.Duration) string {
+	return fmt.Sprintf("%s.delayed.%d", queueName, delay.Milliseconds())
+}
+
+// PublishDelayedTask publishes a worker task that reaches queueName after delay,
+// rounded up to a whole second to bound the number of delayed queues.
+// attempt counts the retries of the task and is passed on to the worker.
+func PublishDelayedTask(cfg *config.Config, queueName, taskType, id string, payload map[string]interface{}, attempt int, delay time.Duration) error {
+	task := map[string]interface{}{
+		"type":    taskType,
+		"payload": payload,
+		"id":      id,
+		"attempt": attempt,
+	}
+	delay = (max(delay, time.Second) + time.Second - 1).Truncate(time.Second)
+	// Unused delayed queues are deleted once the last message published to them has expired
+	args := amqp091.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": queueName,
+		"x-message-ttl":             delay.Milliseconds(),
+		"x-expires":                 (2*delay + time.Minute).Milliseconds(),
+	}
+	return publish(cfg, DelayedQueue(queueName, delay), args, task)
+}
+
+// publish declares a durable queue with args and publishes task to it
+func publish(cfg *config.Config, queueName string, args amqp091.Table, task map[string]interface{}) error {
+	conn, err := amqp091.Dial(cfg.RabbitMQURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open a channel: %w", err)
+	}
+	defer ch.Close()
+
+	_, err = ch.QueueDeclare(
+		queueName, // name
+		true,      // durable
+		false,     // delete when unused
+		false,     // exclusive
+		false,     // no-wait
+		args,      // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", queueName, err)
+	}
+
+	body, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	return ch.Publish(
+		"",        // exchange
+		queueName, // routing key
+		false,     // mandatory
+		false,     // immediate
+		amqp091.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp091.Persistent,
+			Body:         body,
+		})
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+)
+
+// lockNamespace is the first key of project advisory locks, keeping them apart
+// from other advisory locks in the database
+const lockNamespace = 0x78646f63
+
+// lockPollInterval is how often a busy project lock is tried again
+const lockPollInterval = 250 * time.Millisecond
+
+// ErrBusy is returned when another operation holds the lock of a project
+var ErrBusy = errors.New("project is busy")
+
+// LockProject takes the Postgres advisory lock of a project, so that git and
+// build operations on its checkouts are serialized across services and replicas.
+// It waits up to timeout and then fails with ErrBusy. The lock is held by a
+// dedicated database session and released by the returned function, or by
+// Postgres when the session ends.
+func LockProject(projectID int, timeout time.Duration) (func(), error) {
+	ctx := context.Background()
+	conn, err := db.DB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var acquired bool
+		err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1, $2)`, lockNamespace, projectID).Scan(&acquired)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to lock project %d: %w", projectID, err)
+		}
+		if acquired {
+			break
+		}
+		if time.Now().After(deadline) {
+			conn.Close()
+			return nil, fmt.Errorf("%w: project %d", ErrBusy, projectID)
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	return func() {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1, $2)`, lockNamespace, projectID); err != nil {
+			// Drop the session instead of returning it to the pool still holding the lock
+			log.Printf("Error unlocking project %d: %v", projectID, err)
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, nil
+}
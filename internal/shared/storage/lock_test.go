@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+)
+
+// advisoryLocks fakes Postgres session-level advisory locks: a lock belongs to the
+// connection that took it, which may take it again, until that connection unlocks it
+type advisoryLocks struct {
+	mu     sync.Mutex
+	owners map[[2]int64]*advisoryConn
+}
+
+func (l *advisoryLocks) Open(string) (driver.Conn, error) {
+	return &advisoryConn{locks: l}, nil
+}
+
+type advisoryConn struct {
+	locks *advisoryLocks
+}
+
+func (c *advisoryConn) Prepare(query string) (driver.Stmt, error) {
+	return &advisoryStmt{conn: c, query: query}, nil
+}
+
+func (c *advisoryConn) Close() error { return nil }
+
+func (c *advisoryConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type advisoryStmt struct {
+	conn  *advisoryConn
+	query string
+}
+
+func (s *advisoryStmt) Close() error  { return nil }
+func (s *advisoryStmt) NumInput() int { return -1 }
+
+func (s *advisoryStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.run(args)
+	return driver.RowsAffected(0), nil
+}
+
+func (s *advisoryStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &boolRows{value: s.run(args)}, nil
+}
+
+// run takes or releases the lock named by args and reports whether it succeeded
+func (s *advisoryStmt) run(args []driver.Value) bool {
+	key := [2]int64{args[0].(int64), args[1].(int64)}
+	l := s.conn.locks
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	owner := l.owners[key]
+	switch {
+	case strings.Contains(s.query, "pg_try_advisory_lock"):
+		if owner != nil && owner != s.conn {
+			return false
+		}
+		l.owners[key] = s.conn
+		return true
+	case strings.Contains(s.query, "pg_advisory_unlock"):
+		if owner != s.conn {
+			return false
+		}
+		delete(l.owners, key)
+		return true
+	}
+	return false
+}
+
+// boolRows is a result with a single boolean row
+type boolRows struct {
+	value bool
+	done  bool
+}
+
+func (r *boolRows) Columns() []string { return []string{"result"} }
+func (r *boolRows) Close() error      { return nil }
+
+func (r *boolRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+// fakeLocks backs the "advisorylocks" driver used by the tests
+var (
+	fakeLocks         = &advisoryLocks{}
+	registerFakeLocks sync.Once
+)
+
+// useAdvisoryLocks points db.DB at an empty set of fake advisory locks for one test
+func useAdvisoryLocks(t *testing.T) {
+	registerFakeLocks.Do(func() { sql.Register("advisorylocks", fakeLocks) })
+	fakeLocks.mu.Lock()
+	fakeLocks.owners = map[[2]int64]*advisoryConn{}
+	fakeLocks.mu.Unlock()
+
+	conn, err := sql.Open("advisorylocks", "")
+	require.NoError(t, err)
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() {
+		conn.Close()
+		db.DB = previous
+	})
+}
+
+func TestLockProjectSerializesOperations(t *testing.T) {
+	useAdvisoryLocks(t)
+
+	unlock, err := LockProject(7, time.Second)
+	require.NoError(t, err)
+
+	// Another operation on the same project gives up as busy after the timeout
+	start := time.Now()
+	_, err = LockProject(7, 300*time.Millisecond)
+	require.ErrorIs(t, err, ErrBusy)
+	require.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+
+	// Other projects are not blocked
+	unlockOther, err := LockProject(8, 0)
+	require.NoError(t, err)
+	unlockOther()
+
+	// A waiting operation gets the lock once it is released
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		unlock()
+		close(released)
+	}()
+	unlock, err = LockProject(7, 5*time.Second)
+	require.NoError(t, err)
+	<-released
+	unlock()
+}
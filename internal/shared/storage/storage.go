@@ -1 +1,209 @@
 package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+)
+
+// worktreesDir holds the language worktrees of every project, outside the source checkouts
+const worktreesDir = "worktrees"
+
+var (
+	// ErrInvalidLanguage is returned for language codes that are not safe in paths and branch names
+	ErrInvalidLanguage = errors.New("invalid language code")
+	// ErrInvalidPath is returned for file paths that would leave a checkout
+	ErrInvalidPath = errors.New("invalid file path")
+	// ErrNotRegularFile is returned when a checkout path is a symlink or another special file
+	ErrNotRegularFile = errors.New("not a regular file")
+)
+
+// languageCodePattern accepts codes such as es, pt-BR or zh-Hant
+var languageCodePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(?:[-_][A-Za-z0-9]{2,8})*$`)
+
+// ValidLanguage reports whether language is safe to use in paths and branch names
+func ValidLanguage(language string) bool {
+	return languageCodePattern.MatchString(language)
+}
+
+// Repos is the workspace of the running service, set by Init
+var Repos *Workspace
+
+// Init sets up Repos at the configured root
+func Init(cfg *config.Config) {
+	Repos = NewWorkspace(cfg.ReposRoot)
+}
+
+// Workspace lays out project checkouts under a root directory:
+//
+//	{root}/{id}                    source checkout
+//	{root}/worktrees/{id}/{lang}   language worktrees
+//
+// Every path it returns is inside root. Changes to a project's checkouts are
+// serialized with LockProject.
+type Workspace struct {
+	root string
+}
+
+// NewWorkspace returns a workspace rooted at root
+func NewWorkspace(root string) *Workspace {
+	return &Workspace{root: filepath.Clean(root)}
+}
+
+// Root is the directory that holds every project
+func (w *Workspace) Root() string {
+	return w.root
+}
+
+// ProjectPath is the source checkout of a project
+func (w *Workspace) ProjectPath(projectID int) string {
+	return filepath.Join(w.root, strconv.Itoa(projectID))
+}
+
+// WorktreesPath holds the language worktrees of a project
+func (w *Workspace) WorktreesPath(projectID int) string {
+	return filepath.Join(w.root, worktreesDir, strconv.Itoa(projectID))
+}
+
+// LanguagePath is the worktree with the translated copy of a project in one language
+func (w *Workspace) LanguagePath(projectID int, language string) (string, error) {
+	if !ValidLanguage(language) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidLanguage, language)
+	}
+	return filepath.Join(w.WorktreesPath(projectID), language), nil
+}
+
+// SourceFile is the path of a file relative to the source checkout
+func (w *Workspace) SourceFile(projectID int, file string) (string, error) {
+	return join(w.ProjectPath(projectID), file)
+}
+
+// LanguageFile is the path of a file relative to a language worktree
+func (w *Workspace) LanguageFile(projectID int, language, file string) (string, error) {
+	dir, err := w.LanguagePath(projectID, language)
+	if err != nil {
+		return "", err
+	}
+	return join(dir, file)
+}
+
+// join appends a relative file path to dir, rejecting absolute paths and .. that leave dir
+func join(dir, file string) (string, error) {
+	if !filepath.IsLocal(file) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPath, file)
+	}
+	return filepath.Join(dir, file), nil
+}
+
+// ReadRegularFile reads a file of a checkout without following symlinks. A
+// repository may contain a symlink to any file of the host, such as a secret,
+// so anything but a regular file fails with ErrNotRegularFile.
+func ReadRegularFile(path string) ([]byte, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s", ErrNotRegularFile, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// The path may have been replaced between the checks
+	opened, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !os.SameFile(info, opened) {
+		return nil, fmt.Errorf("%w: %s", ErrNotRegularFile, path)
+	}
+	return io.ReadAll(f)
+}
+
+// Remove deletes the source checkout and the language worktrees of a project
+func (w *Workspace) Remove(projectID int) error {
+	if err := os.RemoveAll(w.WorktreesPath(projectID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.RemoveAll(w.ProjectPath(projectID))
+}
+
+// LanguageUsage is the disk usage of one language worktree
+type LanguageUsage struct {
+	Language string `json:"language"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// DiskUsage is the disk usage of a project's checkouts, in bytes
+type DiskUsage struct {
+	ProjectID   int             `json:"project_id"`
+	SourceBytes int64           `json:"source_bytes"`
+	Languages   []LanguageUsage `json:"languages"`
+	TotalBytes  int64           `json:"total_bytes"`
+}
+
+// DiskUsage sums the sizes of the files in a project's source checkout and
+// language worktrees. Symlinks are counted by their own size and not followed.
+func (w *Workspace) DiskUsage(projectID int) (*DiskUsage, error) {
+	usage := &DiskUsage{ProjectID: projectID, Languages: []LanguageUsage{}}
+
+	var err error
+	if usage.SourceBytes, err = dirSize(w.ProjectPath(projectID)); err != nil {
+		return nil, err
+	}
+	usage.TotalBytes = usage.SourceBytes
+
+	entries, err := os.ReadDir(w.WorktreesPath(projectID))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !ValidLanguage(entry.Name()) {
+			continue
+		}
+		size, err := dirSize(filepath.Join(w.WorktreesPath(projectID), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		usage.Languages = append(usage.Languages, LanguageUsage{Language: entry.Name(), Bytes: size})
+		usage.TotalBytes += size
+	}
+	sort.Slice(usage.Languages, func(i, j int) bool { return usage.Languages[i].Language < usage.Languages[j].Language })
+	return usage, nil
+}
+
+// dirSize sums the sizes of the files below dir, or returns 0 if it does not exist
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
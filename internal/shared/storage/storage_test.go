@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidLanguage(t *testing.T) {
+	for _, code := range []string{"es", "pt-BR", "zh-Hant", "sr_Latn"} {
+		require.True(t, ValidLanguage(code), code)
+	}
+	for _, code := range []string{"", "e", "../es", "../../etc", "es/docs", "-es", "es..", ".git"} {
+		require.False(t, ValidLanguage(code), code)
+	}
+}
+
+func TestWorkspacePaths(t *testing.T) {
+	w := NewWorkspace("/data/repos/")
+	require.Equal(t, "/data/repos", w.Root())
+	require.Equal(t, "/data/repos/7", w.ProjectPath(7))
+	require.Equal(t, "/data/repos/worktrees/7", w.WorktreesPath(7))
+
+	path, err := w.LanguagePath(7, "pt-BR")
+	require.NoError(t, err)
+	require.Equal(t, "/data/repos/worktrees/7/pt-BR", path)
+	_, err = w.LanguagePath(7, "../../etc")
+	require.ErrorIs(t, err, ErrInvalidLanguage)
+
+	path, err = w.SourceFile(7, "docs/intro.md")
+	require.NoError(t, err)
+	require.Equal(t, "/data/repos/7/docs/intro.md", path)
+	path, err = w.LanguageFile(7, "es", "docs/intro.md")
+	require.NoError(t, err)
+	require.Equal(t, "/data/repos/worktrees/7/es/docs/intro.md", path)
+
+	for _, file := range []string{"../8/secret", "docs/../../8", "/etc/passwd", ""} {
+		_, err := w.SourceFile(7, file)
+		require.ErrorIs(t, err, ErrInvalidPath, file)
+	}
+	_, err = w.LanguageFile(7, "es/../..", "docs/intro.md")
+	require.ErrorIs(t, err, ErrInvalidLanguage)
+}
+
+func TestDiskUsage(t *testing.T) {
+	w := NewWorkspace(t.TempDir())
+
+	usage, err := w.DiskUsage(1)
+	require.NoError(t, err)
+	require.Equal(t, &DiskUsage{ProjectID: 1, Languages: []LanguageUsage{}}, usage)
+
+	write := func(path string, size int) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, make([]byte, size), 0644))
+	}
+	write(filepath.Join(w.ProjectPath(1), "docs", "intro.md"), 100)
+	write(filepath.Join(w.ProjectPath(1), "README.md"), 20)
+	write(filepath.Join(w.WorktreesPath(1), "es", "docs", "intro.md"), 110)
+	write(filepath.Join(w.WorktreesPath(1), "de", "docs", "intro.md"), 120)
+	write(filepath.Join(w.ProjectPath(2), "README.md"), 1000)
+	require.NoError(t, os.Symlink("/etc", filepath.Join(w.ProjectPath(1), "link")))
+
+	usage, err = w.DiskUsage(1)
+	require.NoError(t, err)
+	require.Equal(t, []LanguageUsage{{Language: "de", Bytes: 120}, {Language: "es", Bytes: 110}}, usage.Languages)
+	require.Greater(t, usage.SourceBytes, int64(120))
+	require.Less(t, usage.SourceBytes, int64(1000))
+	require.Equal(t, usage.SourceBytes+230, usage.TotalBytes)
+
+	require.NoError(t, w.Remove(1))
+	usage, err = w.DiskUsage(1)
+	require.NoError(t, err)
+	require.Zero(t, usage.TotalBytes)
+	_, err = os.Stat(w.ProjectPath(2))
+	require.NoError(t, err)
+}
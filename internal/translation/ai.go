@@ -1 +1,335 @@
 package translation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+)
+
+// Translator translates batches of text segments from one language to another.
+// Implementations must return exactly one translation per input segment, in order.
+type Translator interface {
+	Name() string
+	Translate(ctx context.Context, req TranslateRequest) (*TranslateResult, error)
+}
+
+// TranslateRequest is a batch of segments sent to a provider
+type TranslateRequest struct {
+	SourceLanguage string
+	TargetLanguage string
+	Segments       []string
+	References     []Reference
+	Glossary       []project.GlossaryTerm
+	// Instructions is the rendered prompt template; empty uses the default template
+	Instructions string
+}
+
+// Reference is a similar, previously approved translation given to the model as context
+type Reference struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// Usage reports the tokens consumed by a provider call
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+}
+
+// TranslateResult holds the translations returned by a provider
+type TranslateResult struct {
+	Translations []string
+	Usage        Usage
+	// Provider names the provider that answered, when it differs from the one called
+	Provider string
+	// Cost is the estimated price of the request in USD, set by PricedTranslator
+	Cost float64
+}
+
+// NewTranslator builds the provider selected by cfg.AIProvider, with retries, a
+// concurrency limit and a circuit breaker, falling back to cfg.AIFallbackProvider
+// when one is configured. Each provider prices its requests with its own prices.
+func NewTranslator(cfg *config.Config) (Translator, error) {
+	primary, err := newProvider(cfg.AIProvider, cfg.AIBaseURL, cfg.AIAPIKey, cfg.AIModel)
+	if err != nil {
+		return nil, err
+	}
+	policy := RetryPolicy{MaxRetries: cfg.AIMaxRetries, BaseDelay: DefaultRetryPolicy.BaseDelay, MaxDelay: cfg.AIMaxRetryDelay}
+	providers := []Translator{
+		NewPricedTranslator(
+			NewResilientProvider(primary, policy, cfg.AIMaxConcurrency, cfg.AIBreakerThreshold, cfg.AIBreakerCooldown),
+			Pricing{PromptPerMillion: cfg.AIPromptPrice, CompletionPerMillion: cfg.AICompletionPrice},
+		),
+	}
+
+	if cfg.AIFallbackProvider != "" {
+		fallback, err := newProvider(cfg.AIFallbackProvider, cfg.AIFallbackBaseURL, cfg.AIFallbackAPIKey, cfg.AIFallbackModel)
+		if err != nil {
+			return nil, fmt.Errorf("fallback provider: %w", err)
+		}
+		providers = append(providers, NewPricedTranslator(
+			NewResilientProvider(fallback, policy, cfg.AIMaxConcurrency, cfg.AIBreakerThreshold, cfg.AIBreakerCooldown),
+			Pricing{PromptPerMillion: cfg.AIFallbackPromptPrice, CompletionPerMillion: cfg.AIFallbackCompletionPrice},
+		))
+	}
+	if len(providers) == 1 {
+		return providers[0], nil
+	}
+	return NewFallbackTranslator(providers...), nil
+}
+
+// newProvider builds a single provider by name
+func newProvider(name, baseURL, apiKey, model string) (Translator, error) {
+	switch name {
+	case "openai":
+		if apiKey == "" {
+			return nil, fmt.Errorf("an API key is required for the openai provider")
+		}
+		return NewOpenAIProvider(baseURL, apiKey, model), nil
+	case "stub", "":
+		return NewStubProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider: %s", name)
+	}
+}
+
+// OpenAIProvider talks to any OpenAI-compatible chat completions API
+type OpenAIProvider struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// NewOpenAIProvider creates a provider for the chat completions API at baseURL
+func NewOpenAIProvider(baseURL, apiKey, model string) *OpenAIProvider {
+	return &OpenAIProvider{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+func (p *OpenAIProvider) Translate(ctx context.Context, req TranslateRequest) (*TranslateResult, error) {
+	if len(req.Segments) == 0 {
+		return &TranslateResult{Translations: []string{}}, nil
+	}
+
+	segments, err := segmentsPayload(req.Segments)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(chatCompletionRequest{
+		Model: p.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: systemPrompt(req)},
+			{Role: "user", Content: segments},
+		},
+		Temperature: 0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &ProviderError{Provider: p.Name(), Message: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &ProviderError{
+			Provider:   p.Name(),
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Message:    strings.TrimSpace(string(msg)),
+		}
+	}
+
+	var completion chatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return nil, fmt.Errorf("failed to decode AI provider response: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("AI provider returned no choices")
+	}
+
+	translations, err := parseTranslations(completion.Choices[0].Message.Content, len(req.Segments))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TranslateResult{
+		Translations: translations,
+		Usage: Usage{
+			PromptTokens:     completion.Usage.PromptTokens,
+			CompletionTokens: completion.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+// outputInstructions end every system prompt so that replies can be parsed,
+// whatever the project's prompt template says
+const outputInstructions = `Reply with a JSON array of strings only, with exactly one translation per input string and in the same order.
+Keep Markdown formatting, whitespace and placeholders such as <ph id="0"/> exactly as they appear.`
+
+// systemPrompt instructs the model to answer with a JSON array matching the input.
+// Requests without rendered instructions use the default prompt template.
+func systemPrompt(req TranslateRequest) string {
+	instructions := req.Instructions
+	if instructions == "" {
+		// The default template always renders
+		instructions, _ = project.RenderPrompt(project.DefaultPromptTemplate, promptVariables(req, "", ""))
+	}
+	return instructions + "\n\n" + outputInstructions
+}
+
+// segmentsPayload is the user message holding the strings to translate
+func segmentsPayload(segments []string) (string, error) {
+	payload, err := json.Marshal(segments)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal segments: %w", err)
+	}
+	return string(payload), nil
+}
+
+// promptVariables fills the prompt template variables for a request
+func promptVariables(req TranslateRequest, path, context string) project.PromptVariables {
+	return project.PromptVariables{
+		SourceLanguage:     req.SourceLanguage,
+		TargetLanguage:     req.TargetLanguage,
+		SourceLanguageName: languageName(req.SourceLanguage),
+		TargetLanguageName: languageName(req.TargetLanguage),
+		FilePath:           path,
+		Context:            context,
+		Glossary:           glossaryPrompt(req.Glossary),
+		References:         referencesPrompt(req.References),
+	}
+}
+
+// referencesPrompt lists similar earlier translations for the system prompt
+func referencesPrompt(references []Reference) string {
+	if len(references) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nReuse the wording of these earlier translations of similar text where it fits:")
+	for _, ref := range references {
+		fmt.Fprintf(&sb, "\n- %q => %q", ref.Source, ref.Target)
+	}
+	return sb.String()
+}
+
+// parseTranslations extracts the JSON array from a model reply, tolerating code fences
+func parseTranslations(content string, expected int) ([]string, error) {
+	content = strings.TrimSpace(content)
+	if start := strings.Index(content, "["); start > 0 {
+		content = content[start:]
+	}
+	if end := strings.LastIndex(content, "]"); end >= 0 {
+		content = content[:end+1]
+	}
+
+	var translations []string
+	if err := json.Unmarshal([]byte(content), &translations); err != nil {
+		return nil, fmt.Errorf("AI provider returned an invalid translation array: %w", err)
+	}
+	if len(translations) != expected {
+		return nil, fmt.Errorf("AI provider returned %d translations for %d segments", len(translations), expected)
+	}
+	return translations, nil
+}
+
+// languageName returns a human readable name for common language codes
+func languageName(code string) string {
+	names := map[string]string{
+		"en": "English",
+		"es": "Spanish",
+		"fr": "French",
+		"de": "German",
+		"it": "Italian",
+		"pt": "Portuguese",
+		"ja": "Japanese",
+		"ko": "Korean",
+		"zh": "Chinese",
+		"ru": "Russian",
+	}
+	if name, ok := names[strings.ToLower(code)]; ok {
+		return name
+	}
+	return code
+}
+
+// StubProvider is a deterministic offline provider used for development and tests.
+// It prefixes each segment with the target language code.
+type StubProvider struct{}
+
+// NewStubProvider creates a stub provider
+func NewStubProvider() *StubProvider {
+	return &StubProvider{}
+}
+
+func (p *StubProvider) Name() string {
+	return "stub"
+}
+
+func (p *StubProvider) Translate(ctx context.Context, req TranslateRequest) (*TranslateResult, error) {
+	translations := make([]string, len(req.Segments))
+	usage := Usage{}
+	for i, segment := range req.Segments {
+		if strings.TrimSpace(segment) == "" {
+			translations[i] = segment
+			continue
+		}
+		translations[i] = fmt.Sprintf("[%s] %s", req.TargetLanguage, segment)
+		usage.PromptTokens += len(segment) / 4
+		usage.CompletionTokens += len(translations[i]) / 4
+	}
+	return &TranslateResult{Translations: translations, Usage: usage}, nil
+}
@@ -0,0 +1,70 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStubProviderIsDeterministic(t *testing.T) {
+	provider := NewStubProvider()
+	req := TranslateRequest{SourceLanguage: "en", TargetLanguage: "es", Segments: []string{"Hello", "  ", "World"}}
+
+	first, err := provider.Translate(context.Background(), req)
+	require.NoError(t, err)
+	second, err := provider.Translate(context.Background(), req)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"[es] Hello", "  ", "[es] World"}, first.Translations)
+	require.Equal(t, first, second)
+}
+
+func TestOpenAIProviderTranslate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/chat/completions", r.URL.Path)
+		require.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+
+		var req chatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "test-model", req.Model)
+		require.Len(t, req.Messages, 2)
+		require.JSONEq(t, `["Hello","World"]`, req.Messages[1].Content)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": "```json\n[\"Hola\",\"Mundo\"]\n```"}},
+			},
+			"usage": map[string]int{"prompt_tokens": 42, "completion_tokens": 7},
+		})
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(server.URL+"/", "test-key", "test-model")
+	result, err := provider.Translate(context.Background(), TranslateRequest{
+		SourceLanguage: "en",
+		TargetLanguage: "es",
+		Segments:       []string{"Hello", "World"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Hola", "Mundo"}, result.Translations)
+	require.Equal(t, Usage{PromptTokens: 42, CompletionTokens: 7}, result.Usage)
+}
+
+func TestOpenAIProviderRejectsWrongSegmentCount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": `["Hola"]`}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(server.URL, "test-key", "test-model")
+	_, err := provider.Translate(context.Background(), TranslateRequest{TargetLanguage: "es", Segments: []string{"Hello", "World"}})
+	require.Error(t, err)
+}
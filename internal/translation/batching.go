@@ -0,0 +1,133 @@
+package translation
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// DefaultTokenBudget is the request size used when a pipeline has no budget configured
+const DefaultTokenBudget = 8000
+
+// minSegmentTokens is the smallest piece an oversized segment is split into,
+// however little of the budget the prompt leaves
+const minSegmentTokens = 64
+
+// splitPatterns are tried in order to break oversized segments: paragraphs,
+// lines, sentences and finally words
+var splitPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\n[ \t]*\n\s*`),
+	regexp.MustCompile(`\n`),
+	regexp.MustCompile(`[.!?:;][ \t]+|[。！？]`),
+	regexp.MustCompile(`[ \t]+`),
+}
+
+// estimateTokens approximates the tokens of text without a tokenizer: about four
+// bytes per token for ASCII text, and one token per character otherwise
+func estimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// batchUnit is a piece of text sent to the provider: a whole segment, or a part of an oversized one
+type batchUnit struct {
+	segment    int
+	text       string
+	references []Reference
+}
+
+// cost estimates the tokens a unit adds to a request: its text, JSON quoting,
+// its reference translations and the translation the provider sends back
+func (u batchUnit) cost() int {
+	tokens := 2*estimateTokens(u.text) + 4
+	for _, ref := range u.references {
+		tokens += estimateTokens(ref.Source) + estimateTokens(ref.Target) + 4
+	}
+	return tokens
+}
+
+// planBatches groups consecutive units into requests whose estimated size stays
+// within budget once overhead, the fixed size of the prompt, is added. A unit
+// larger than the budget on its own gets a request of its own.
+func planBatches(units []batchUnit, budget, overhead int) [][]batchUnit {
+	var batches [][]batchUnit
+	var current []batchUnit
+	size := overhead
+	for _, unit := range units {
+		cost := unit.cost()
+		if len(current) > 0 && size+cost > budget {
+			batches = append(batches, current)
+			current, size = nil, overhead
+		}
+		current = append(current, unit)
+		size += cost
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// splitText breaks text into pieces of at most maxTokens, cutting at paragraph,
+// line, sentence or word boundaries and never inside a placeholder. The original
+// text is pieces[0] + separators[0] + pieces[1] + ... + pieces[n].
+func splitText(text string, maxTokens int) (pieces, separators []string) {
+	return splitWith(text, maxTokens, 0)
+}
+
+func splitWith(text string, maxTokens, level int) ([]string, []string) {
+	if estimateTokens(text) <= maxTokens || level >= len(splitPatterns) {
+		return []string{text}, nil
+	}
+
+	var cuts [][]int
+	protected := placeholderPattern.FindAllStringIndex(text, -1)
+	for _, cut := range splitPatterns[level].FindAllStringIndex(text, -1) {
+		if cut[0] > 0 && cut[1] < len(text) && !overlaps(cut, protected) {
+			cuts = append(cuts, cut)
+		}
+	}
+	if len(cuts) == 0 {
+		return splitWith(text, maxTokens, level+1)
+	}
+
+	// Pack consecutive parts greedily, splitting parts that are still too large further
+	var pieces, separators []string
+	emit := func(piece string) {
+		subPieces, subSeparators := splitWith(piece, maxTokens, level+1)
+		pieces = append(pieces, subPieces...)
+		separators = append(separators, subSeparators...)
+	}
+	current := text[:cuts[0][0]]
+	for k, cut := range cuts {
+		end := len(text)
+		if k+1 < len(cuts) {
+			end = cuts[k+1][0]
+		}
+		separator, next := text[cut[0]:cut[1]], text[cut[1]:end]
+		if estimateTokens(current+separator+next) <= maxTokens {
+			current += separator + next
+			continue
+		}
+		emit(current)
+		separators = append(separators, separator)
+		current = next
+	}
+	emit(current)
+	return pieces, separators
+}
+
+func overlaps(span []int, others [][]int) bool {
+	for _, other := range others {
+		if span[0] < other[1] && other[0] < span[1] {
+			return true
+		}
+	}
+	return false
+}
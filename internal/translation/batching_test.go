@@ -0,0 +1,115 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func joinPieces(pieces, separators []string) string {
+	var sb strings.Builder
+	for i, piece := range pieces {
+		if i > 0 {
+			sb.WriteString(separators[i-1])
+		}
+		sb.WriteString(piece)
+	}
+	return sb.String()
+}
+
+func TestEstimateTokens(t *testing.T) {
+	require.Equal(t, 0, estimateTokens(""))
+	require.Equal(t, 4, estimateTokens("Install the CLI"))
+	require.Equal(t, 6, estimateTokens("インストール"))
+}
+
+func TestSplitTextKeepsPlaceholdersAndSeparators(t *testing.T) {
+	text := strings.Repeat(`Run <ph id="0"/> to install the tool on every machine. `, 20) + "\n\n" + strings.Repeat("Then start it. ", 20)
+
+	pieces, separators := splitText(text, 40)
+	require.Greater(t, len(pieces), 2)
+	require.Len(t, separators, len(pieces)-1)
+	require.Equal(t, text, joinPieces(pieces, separators))
+	for _, piece := range pieces {
+		require.LessOrEqual(t, estimateTokens(piece), 40, piece)
+		require.Equal(t, strings.Count(piece, "<ph"), strings.Count(piece, `"/>`), piece)
+	}
+
+	// Short text is never split
+	pieces, separators = splitText("Short text.", 40)
+	require.Equal(t, []string{"Short text."}, pieces)
+	require.Empty(t, separators)
+}
+
+func TestPlanBatchesRespectsBudget(t *testing.T) {
+	units := []batchUnit{{text: strings.Repeat("a", 40)}, {text: strings.Repeat("b", 40)}, {text: strings.Repeat("c", 40)}, {text: strings.Repeat("d", 400)}}
+	batches := planBatches(units, 70, 20)
+	require.Len(t, batches, 3)
+	require.Len(t, batches[0], 2)
+	require.Len(t, batches[1], 1)
+	// Units larger than the budget get a request of their own
+	require.Len(t, batches[2], 1)
+}
+
+func TestPipelineBatchesToTokenBudget(t *testing.T) {
+	paragraph := strings.Repeat("This sentence explains how the tool installs itself. ", 30)
+	content := "# Install\n\n" + paragraph + "\n\nShort paragraph.\n"
+
+	translator := &recordingTranslator{}
+	pipeline := &Pipeline{Translator: NewPricedTranslator(translator, Pricing{PromptPerMillion: 1, CompletionPerMillion: 2}), TokenBudget: 400}
+	result, err := pipeline.TranslateDocument(context.Background(), TranslateDocumentRequest{
+		SourceLanguage: "en",
+		TargetLanguage: "es",
+		Path:           "docs/install.md",
+		Content:        content,
+	})
+	require.NoError(t, err)
+	require.Greater(t, len(translator.requests), 1)
+	require.Equal(t, len(translator.requests), result.Requests)
+
+	// The long paragraph was split but its parts were joined back into one segment
+	require.Len(t, result.Translations, 3)
+	translated := result.Translations[1]
+	require.Greater(t, strings.Count(translated.Translation, "[es] "), 1)
+	require.Equal(t, translated.Source, strings.ReplaceAll(translated.Translation, "[es] ", ""))
+	require.True(t, strings.HasPrefix(result.Content, "# [es] Install\n\n[es] This sentence"))
+
+	expected := float64(result.Usage.PromptTokens)/1e6 + 2*float64(result.Usage.CompletionTokens)/1e6
+	require.InDelta(t, expected, result.Cost, 1e-12)
+}
+
+// failingTranslator answers its first requests and then fails
+type failingTranslator struct {
+	StubProvider
+	answers int
+}
+
+func (t *failingTranslator) Translate(ctx context.Context, req TranslateRequest) (*TranslateResult, error) {
+	if t.answers == 0 {
+		return nil, errors.New("provider returned 2 translations for 1 segments")
+	}
+	t.answers--
+	return t.StubProvider.Translate(ctx, req)
+}
+
+func TestPipelineReportsUsageOfFailedDocument(t *testing.T) {
+	paragraph := strings.Repeat("This sentence explains how the tool installs itself. ", 30)
+	content := "# Install\n\n" + paragraph + "\n\nShort paragraph.\n"
+
+	translator := NewPricedTranslator(&failingTranslator{answers: 1}, Pricing{PromptPerMillion: 1, CompletionPerMillion: 2})
+	pipeline := &Pipeline{Translator: translator, TokenBudget: 400}
+	result, err := pipeline.TranslateDocument(context.Background(), TranslateDocumentRequest{
+		SourceLanguage: "en",
+		TargetLanguage: "es",
+		Path:           "docs/install.md",
+		Content:        content,
+	})
+	require.Error(t, err)
+	require.NotNil(t, result)
+	require.Equal(t, 1, result.Requests)
+	require.Greater(t, result.Usage.PromptTokens, 0)
+	require.Greater(t, result.Cost, 0.0)
+}
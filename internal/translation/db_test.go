@@ -0,0 +1,100 @@
+package translation
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/require"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+)
+
+// useTestDatabase points db.DB at a fresh, migrated schema of the Postgres
+// database in TEST_DATABASE_URL for one test, and skips the test without it
+func useTestDatabase(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	_, err = admin.Exec(`CREATE SCHEMA ` + schema)
+	require.NoError(t, err)
+
+	// Extensions such as pg_trgm stay reachable in public
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	conn, err := sql.Open("postgres", dsn+separator+"search_path="+url.QueryEscape(schema+",public"))
+	require.NoError(t, err)
+	goose.SetLogger(goose.NopLogger())
+	require.NoError(t, goose.SetDialect("postgres"))
+	require.NoError(t, goose.Up(conn, "../shared/db/migrations"))
+
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() {
+		db.DB = previous
+		conn.Close()
+		admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		admin.Close()
+	})
+}
+
+// createTestProject inserts a project and returns its ID
+func createTestProject(t *testing.T) int {
+	var id int
+	err := db.DB.QueryRow(`INSERT INTO projects (name, doc_url, repo_url) VALUES ('Docs', 'https://docs.example.com', 'https://github.com/example/docs') RETURNING id`).Scan(&id)
+	require.NoError(t, err)
+	return id
+}
+
+func TestTranslationStatusFollowsCommit(t *testing.T) {
+	useTestDatabase(t)
+	projectID := createTestProject(t)
+
+	for _, file := range []string{"docs/a.md", "docs/b.md", "docs/c.md"} {
+		require.NoError(t, MarkFilePending(projectID, "es", file, "source"))
+		require.NoError(t, MarkFileTranslating(projectID, "es", file, "source"))
+	}
+
+	// Published translations wait for their commit, held ones are final
+	status, err := RecordFileTranslation(projectID, "es", "docs/a.md", "hash-a", "openai", true)
+	require.NoError(t, err)
+	require.Equal(t, StatusTranslating, status)
+	status, err = RecordFileTranslation(projectID, "es", "docs/b.md", "hash-b", "openai", true)
+	require.NoError(t, err)
+	require.Equal(t, StatusTranslating, status)
+	status, err = RecordFileTranslation(projectID, "es", "docs/c.md", "hash-c", "openai", false)
+	require.NoError(t, err)
+	require.Equal(t, StatusNeedsReview, status)
+
+	// A commit of an older translation does not mark the file translated
+	require.NoError(t, MarkFileTranslated(projectID, "es", "docs/a.md", "stale-hash"))
+	file, err := GetTranslationFile(projectID, "es", "docs/a.md")
+	require.NoError(t, err)
+	require.Equal(t, StatusTranslating, file.Status)
+
+	require.NoError(t, MarkFileTranslated(projectID, "es", "docs/a.md", "hash-a"))
+	require.NoError(t, MarkFileCommitFailed(projectID, "es", "docs/b.md", "commit failed: project is busy"))
+	require.NoError(t, MarkFileCommitFailed(projectID, "es", "docs/c.md", "commit failed: project is busy"))
+
+	file, err = GetTranslationFile(projectID, "es", "docs/a.md")
+	require.NoError(t, err)
+	require.Equal(t, StatusTranslated, file.Status)
+	file, err = GetTranslationFile(projectID, "es", "docs/b.md")
+	require.NoError(t, err)
+	require.Equal(t, StatusFailed, file.Status)
+	require.Equal(t, "commit failed: project is busy", *file.Error)
+	file, err = GetTranslationFile(projectID, "es", "docs/c.md")
+	require.NoError(t, err)
+	require.Equal(t, StatusNeedsReview, file.Status)
+}
@@ -0,0 +1,311 @@
+package translation
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrUnsupportedFormat is returned when no parser handles a file extension
+var ErrUnsupportedFormat = errors.New("unsupported document format")
+
+// placeholderPattern matches the inline placeholders that stand in for protected spans
+var placeholderPattern = regexp.MustCompile(`<ph\s+id=["'](\d+)["']\s*/>`)
+
+// Segment is a unit of translatable text extracted from a document.
+// Protected inline spans are replaced by <ph id="N"/> placeholders in Text.
+type Segment struct {
+	Index        int      `json:"index"`
+	Text         string   `json:"text"`
+	Context      string   `json:"context,omitempty"`
+	Placeholders []string `json:"placeholders,omitempty"`
+}
+
+// Document is a parsed file made of protected spans and translatable segments
+type Document struct {
+	Segments []Segment
+	parts    []part
+}
+
+// part is a slice of the original file; segment is -1 for protected text
+type part struct {
+	raw     string
+	segment int
+	encode  func(string) string
+}
+
+// documentParsers maps file extensions to the parser that handles them
+var documentParsers = map[string]func(content string) (*Document, error){
+	".md":       parseMarkdownDocument,
+	".mdx":      parseMarkdownDocument,
+	".markdown": parseMarkdownDocument,
+}
+
+func parseMarkdownDocument(content string) (*Document, error) {
+	return ParseMarkdown(content), nil
+}
+
+// parserFor returns the parser for a file. Markdown is recognised anywhere, while
+// JSON, YAML and TOML catalogs only inside locale directories.
+func parserFor(path string) (func(content string) (*Document, error), bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if parse, ok := documentParsers[ext]; ok {
+		return parse, true
+	}
+	if parse, ok := structuredParsers[ext]; ok && isLocalePath(path) {
+		return parse, true
+	}
+	return nil, false
+}
+
+// ParseDocument selects a parser based on the file extension and location
+func ParseDocument(path, content string) (*Document, error) {
+	parse, ok := parserFor(path)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, path)
+	}
+	return parse(content)
+}
+
+// IsSupportedFile reports whether ParseDocument can handle the file
+func IsSupportedFile(path string) bool {
+	_, ok := parserFor(path)
+	return ok
+}
+
+// Texts returns the source text of every segment, in order
+func (d *Document) Texts() []string {
+	texts := make([]string, len(d.Segments))
+	for i, segment := range d.Segments {
+		texts[i] = segment.Text
+	}
+	return texts
+}
+
+// Render reassembles the document using one translation per segment.
+// Empty translations, or translations equal to the source, keep the original bytes.
+func (d *Document) Render(translations []string) string {
+	var sb strings.Builder
+	for _, p := range d.parts {
+		if p.segment < 0 {
+			sb.WriteString(p.raw)
+			continue
+		}
+
+		segment := d.Segments[p.segment]
+		if p.segment >= len(translations) || translations[p.segment] == "" || translations[p.segment] == segment.Text {
+			sb.WriteString(p.raw)
+			continue
+		}
+
+		text := fillPlaceholders(translations[p.segment], segment.Placeholders)
+		if p.encode != nil {
+			text = p.encode(text)
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+// ValidatePlaceholders checks that a translation uses every placeholder of the segment exactly once
+func ValidatePlaceholders(segment Segment, translation string) error {
+	counts := make(map[int]int)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(translation, -1) {
+		id, _ := strconv.Atoi(match[1])
+		if id >= len(segment.Placeholders) {
+			return fmt.Errorf("unknown placeholder %d", id)
+		}
+		counts[id]++
+	}
+	for id := range segment.Placeholders {
+		switch counts[id] {
+		case 0:
+			return fmt.Errorf("missing placeholder %d", id)
+		case 1:
+		default:
+			return fmt.Errorf("placeholder %d used %d times", id, counts[id])
+		}
+	}
+	return nil
+}
+
+// fillPlaceholders substitutes the protected spans back into a translation
+func fillPlaceholders(text string, placeholders []string) string {
+	if len(placeholders) == 0 {
+		return text
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		id, _ := strconv.Atoi(placeholderPattern.FindStringSubmatch(match)[1])
+		if id < len(placeholders) {
+			return placeholders[id]
+		}
+		return match
+	})
+}
+
+func placeholder(id int) string {
+	return fmt.Sprintf(`<ph id="%d"/>`, id)
+}
+
+// inlineToken is a run of text inside a segment; protected tokens become placeholders
+type inlineToken struct {
+	text      string
+	protected bool
+}
+
+// docBuilder accumulates the parts of a document while it is being parsed
+type docBuilder struct {
+	doc *Document
+}
+
+func newDocBuilder() *docBuilder {
+	return &docBuilder{doc: &Document{}}
+}
+
+// protect appends text that must be copied verbatim
+func (b *docBuilder) protect(text string) {
+	if text == "" {
+		return
+	}
+	if n := len(b.doc.parts); n > 0 && b.doc.parts[n-1].segment < 0 {
+		b.doc.parts[n-1].raw += text
+		return
+	}
+	b.doc.parts = append(b.doc.parts, part{raw: text, segment: -1})
+}
+
+// translateScalar appends an escaped value (such as a quoted YAML string) as one segment.
+// The segment text is the decoded value, and encode escapes the translation again.
+func (b *docBuilder) translateScalar(raw, context string, decode, encode func(string) string) {
+	b.scalarSegment(raw, context, tokenizeInline(decode(raw)), encode)
+}
+
+// translateMessage is translateScalar for UI strings of i18n files, whose ICU
+// arguments, templates and printf verbs are protected instead of Markdown syntax
+func (b *docBuilder) translateMessage(raw, context string, decode, encode func(string) string) {
+	b.scalarSegment(raw, context, tokenizeMessage(decode(raw), false), encode)
+}
+
+func (b *docBuilder) scalarSegment(raw, context string, tokens []inlineToken, encode func(string) string) {
+	tokens = mergeTokens(tokens)
+	if !hasLetters(tokens) {
+		b.protect(raw)
+		return
+	}
+
+	segment := Segment{Index: len(b.doc.Segments), Context: context}
+	var text strings.Builder
+	for _, token := range tokens {
+		if token.protected {
+			text.WriteString(placeholder(len(segment.Placeholders)))
+			segment.Placeholders = append(segment.Placeholders, token.text)
+		} else {
+			text.WriteString(token.text)
+		}
+	}
+	segment.Text = text.String()
+	b.doc.Segments = append(b.doc.Segments, segment)
+	b.doc.parts = append(b.doc.parts, part{raw: raw, segment: segment.Index, encode: encode})
+}
+
+// translateTokens appends a run of tokens as a segment. Leading and trailing whitespace
+// and protected tokens stay outside the segment, and runs without any letters are protected.
+func (b *docBuilder) translateTokens(tokens []inlineToken, context string) {
+	tokens = mergeTokens(tokens)
+
+	var lead, trail strings.Builder
+	for len(tokens) > 0 {
+		first := tokens[0]
+		if first.protected {
+			lead.WriteString(first.text)
+			tokens = tokens[1:]
+			continue
+		}
+		trimmed := strings.TrimLeftFunc(first.text, unicode.IsSpace)
+		lead.WriteString(first.text[:len(first.text)-len(trimmed)])
+		if trimmed == "" {
+			tokens = tokens[1:]
+			continue
+		}
+		tokens[0].text = trimmed
+		break
+	}
+
+	var trailing []string
+	for len(tokens) > 0 {
+		last := tokens[len(tokens)-1]
+		if last.protected {
+			trailing = append(trailing, last.text)
+			tokens = tokens[:len(tokens)-1]
+			continue
+		}
+		trimmed := strings.TrimRightFunc(last.text, unicode.IsSpace)
+		trailing = append(trailing, last.text[len(trimmed):])
+		if trimmed == "" {
+			tokens = tokens[:len(tokens)-1]
+			continue
+		}
+		tokens[len(tokens)-1].text = trimmed
+		break
+	}
+	for i := len(trailing) - 1; i >= 0; i-- {
+		trail.WriteString(trailing[i])
+	}
+
+	b.protect(lead.String())
+	if hasLetters(tokens) {
+		segment := Segment{Index: len(b.doc.Segments), Context: context}
+		var raw, text strings.Builder
+		for _, token := range tokens {
+			raw.WriteString(token.text)
+			if token.protected {
+				text.WriteString(placeholder(len(segment.Placeholders)))
+				segment.Placeholders = append(segment.Placeholders, token.text)
+			} else {
+				text.WriteString(token.text)
+			}
+		}
+		segment.Text = text.String()
+		b.doc.Segments = append(b.doc.Segments, segment)
+		b.doc.parts = append(b.doc.parts, part{raw: raw.String(), segment: segment.Index})
+	} else {
+		for _, token := range tokens {
+			b.protect(token.text)
+		}
+	}
+	b.protect(trail.String())
+}
+
+// mergeTokens joins adjacent tokens of the same kind and drops empty ones
+func mergeTokens(tokens []inlineToken) []inlineToken {
+	merged := make([]inlineToken, 0, len(tokens))
+	for _, token := range tokens {
+		if token.text == "" {
+			continue
+		}
+		if n := len(merged); n > 0 && merged[n-1].protected == token.protected {
+			merged[n-1].text += token.text
+			continue
+		}
+		merged = append(merged, token)
+	}
+	return merged
+}
+
+func hasLetters(tokens []inlineToken) bool {
+	for _, token := range tokens {
+		if token.protected {
+			continue
+		}
+		for _, r := range token.text {
+			if unicode.IsLetter(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
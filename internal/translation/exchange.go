@@ -0,0 +1,242 @@
+package translation
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedExchangeFormat is returned for export and import formats other than XLIFF and PO
+var ErrUnsupportedExchangeFormat = errors.New("unsupported exchange format")
+
+// Exchange formats for offline translation with CAT tools
+const (
+	FormatXLIFF = "xliff"
+	FormatPO    = "po"
+)
+
+// ExchangeUnit is one segment of an exported or imported file.
+// Target is empty for segments that still need a translation.
+type ExchangeUnit struct {
+	Path         string
+	Index        int
+	SourceHash   string
+	Context      string
+	Source       string
+	Target       string
+	Origin       string
+	Placeholders []string
+}
+
+// ExchangeFile is a set of units for one target language
+type ExchangeFile struct {
+	SourceLanguage string
+	TargetLanguage string
+	Units          []ExchangeUnit
+}
+
+// UnitID identifies a unit across formats as path#index
+func (u ExchangeUnit) UnitID() string {
+	return u.Path + "#" + strconv.Itoa(u.Index)
+}
+
+// parseUnitID splits a path#index unit ID
+func parseUnitID(id string) (string, int, error) {
+	i := strings.LastIndex(id, "#")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("invalid unit ID %q", id)
+	}
+	index, err := strconv.Atoi(id[i+1:])
+	if err != nil || index < 0 {
+		return "", 0, fmt.Errorf("invalid unit ID %q", id)
+	}
+	return id[:i], index, nil
+}
+
+// Export filters for the units of a language
+const (
+	ExportPending    = "pending"
+	ExportTranslated = "translated"
+)
+
+// CollectExchangeUnits returns the stored segments of every file in a language.
+// which is ExportPending for untranslated and stale segments, ExportTranslated
+// for translated ones, or empty for both.
+func CollectExchangeUnits(projectID int, language, pathPrefix, which string) ([]ExchangeUnit, error) {
+	files, err := GetTranslationFiles(projectID, TranslationFileFilter{Language: language, PathPrefix: pathPrefix})
+	if err != nil {
+		return nil, err
+	}
+
+	var units []ExchangeUnit
+	for _, file := range files {
+		segments, err := GetFileSegments(projectID, language, file.SourcePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, segment := range segments {
+			// Stale segments still need a translation of their new source
+			pending := segment.Translation == "" || segment.StaleSince != ""
+			if which == ExportPending && !pending {
+				continue
+			}
+			if which == ExportTranslated && pending {
+				continue
+			}
+			units = append(units, ExchangeUnit{
+				Path:         file.SourcePath,
+				Index:        segment.Index,
+				SourceHash:   segment.SourceHash,
+				Context:      segment.Context,
+				Source:       segment.Source,
+				Target:       segment.Translation,
+				Origin:       segment.Origin,
+				Placeholders: segment.Placeholders,
+			})
+		}
+	}
+	return units, nil
+}
+
+// EncodeExchangeFile writes units in the requested format
+func EncodeExchangeFile(format string, file ExchangeFile) ([]byte, error) {
+	switch format {
+	case FormatXLIFF:
+		return EncodeXLIFF(file)
+	case FormatPO:
+		return EncodePO(file), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedExchangeFormat, format)
+	}
+}
+
+// DecodeExchangeFile parses units in the requested format
+func DecodeExchangeFile(format string, data []byte) (*ExchangeFile, error) {
+	switch format {
+	case FormatXLIFF:
+		return DecodeXLIFF(data)
+	case FormatPO:
+		return DecodePO(data)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedExchangeFormat, format)
+	}
+}
+
+// UnitIssue explains why an imported unit was not applied
+type UnitIssue struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// ImportReport summarizes an import
+type ImportReport struct {
+	Applied    int         `json:"applied"`
+	Unchanged  int         `json:"unchanged"`
+	Skipped    int         `json:"skipped"`
+	Stale      []UnitIssue `json:"stale"`
+	Mismatched []UnitIssue `json:"mismatched"`
+	Files      []string    `json:"files"`
+}
+
+// segmentStore is the subset of segment storage used by imports
+type segmentStore struct {
+	file     func(projectID int, language, sourcePath string) (*TranslationFile, error)
+	segments func(projectID int, language, sourcePath string) ([]SegmentTranslation, error)
+	apply    func(projectID int, language, sourcePath string, corrections []SegmentCorrection, editorID *int) error
+}
+
+var defaultSegmentStore = segmentStore{
+	file:     GetTranslationFile,
+	segments: GetFileSegments,
+	apply:    ApplyCorrections,
+}
+
+// ImportExchangeFile validates imported units against the current source segments
+// and stores the valid translations as editor corrections. Units of files whose
+// source changed since they were segmented, or whose source text differs, are stale.
+func ImportExchangeFile(projectID int, language string, file *ExchangeFile, editorID *int) (*ImportReport, error) {
+	return importExchangeFile(defaultSegmentStore, projectID, language, file, editorID)
+}
+
+func importExchangeFile(store segmentStore, projectID int, language string, file *ExchangeFile, editorID *int) (*ImportReport, error) {
+	report := &ImportReport{Stale: []UnitIssue{}, Mismatched: []UnitIssue{}, Files: []string{}}
+	if file.TargetLanguage != "" && !strings.EqualFold(file.TargetLanguage, language) {
+		return nil, fmt.Errorf("%w: file targets %s, not %s", ErrInvalidCorrection, file.TargetLanguage, language)
+	}
+
+	byPath := make(map[string][]ExchangeUnit)
+	for _, unit := range file.Units {
+		byPath[unit.Path] = append(byPath[unit.Path], unit)
+	}
+	paths := make([]string, 0, len(byPath))
+	for path := range byPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		units := byPath[path]
+
+		current, err := store.file(projectID, language, path)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if err != nil {
+			for _, unit := range units {
+				report.Mismatched = append(report.Mismatched, UnitIssue{ID: unit.UnitID(), Reason: "unknown file"})
+			}
+			continue
+		}
+		if !editable(current) {
+			for _, unit := range units {
+				report.Stale = append(report.Stale, UnitIssue{ID: unit.UnitID(), Reason: "file is " + current.Status})
+			}
+			continue
+		}
+
+		segments, err := store.segments(projectID, language, path)
+		if err != nil {
+			return nil, err
+		}
+
+		var corrections []SegmentCorrection
+		for _, unit := range units {
+			if unit.Index >= len(segments) {
+				report.Mismatched = append(report.Mismatched, UnitIssue{ID: unit.UnitID(), Reason: "unknown segment"})
+				continue
+			}
+			segment := segments[unit.Index]
+			if (unit.SourceHash != "" && unit.SourceHash != segment.SourceHash) || (unit.Source != "" && SegmentHash(unit.Source) != segment.SourceHash) {
+				report.Stale = append(report.Stale, UnitIssue{ID: unit.UnitID(), Reason: "source segment changed"})
+				continue
+			}
+			if unit.Target == "" {
+				report.Skipped++
+				continue
+			}
+			if err := ValidatePlaceholders(Segment{Index: unit.Index, Placeholders: segment.Placeholders}, unit.Target); err != nil {
+				report.Mismatched = append(report.Mismatched, UnitIssue{ID: unit.UnitID(), Reason: err.Error()})
+				continue
+			}
+			// Confirming the translation of a stale segment clears it
+			if unit.Target == segment.Translation && segment.StaleSince == "" {
+				report.Unchanged++
+				continue
+			}
+			corrections = append(corrections, SegmentCorrection{Index: unit.Index, Translation: unit.Target})
+		}
+
+		if len(corrections) > 0 {
+			if err := store.apply(projectID, language, path, corrections, editorID); err != nil {
+				return nil, err
+			}
+			report.Applied += len(corrections)
+			report.Files = append(report.Files, path)
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,124 @@
+package translation
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sampleExchangeFile() ExchangeFile {
+	return ExchangeFile{
+		SourceLanguage: "en",
+		TargetLanguage: "es",
+		Units: []ExchangeUnit{
+			{Path: "docs/intro.md", Index: 0, SourceHash: SegmentHash("Getting started"), Context: "heading", Source: "Getting started", Target: "Primeros pasos", Origin: OriginHuman},
+			{Path: "docs/intro.md", Index: 1, SourceHash: SegmentHash(`Run <ph id="0"/> & "enjoy"`), Source: `Run <ph id="0"/> & "enjoy"`, Target: `Ejecuta <ph id="0"/> y "disfruta"`, Origin: OriginMachine, Placeholders: []string{"`make <all>`"}},
+			{Path: "docs/guide.md", Index: 0, SourceHash: SegmentHash("Line one\nline two"), Source: "Line one\nline two"},
+		},
+	}
+}
+
+func TestXLIFFRoundTrip(t *testing.T) {
+	data, err := EncodeXLIFF(sampleExchangeFile())
+	require.NoError(t, err)
+	require.Contains(t, string(data), `<ph id="0" disp="`+"`make &lt;all&gt;`"+`"/>`)
+	require.Contains(t, string(data), `<segment state="reviewed">`)
+
+	decoded, err := DecodeXLIFF(data)
+	require.NoError(t, err)
+	require.Equal(t, "en", decoded.SourceLanguage)
+	require.Equal(t, "es", decoded.TargetLanguage)
+	require.Len(t, decoded.Units, 3)
+	for i, unit := range sampleExchangeFile().Units {
+		require.Equal(t, unit.UnitID(), decoded.Units[i].UnitID())
+		require.Equal(t, unit.SourceHash, decoded.Units[i].SourceHash)
+		require.Equal(t, unit.Source, decoded.Units[i].Source)
+		require.Equal(t, unit.Target, decoded.Units[i].Target)
+	}
+}
+
+func TestDecodeXLIFFRejectsUnknownInlineMarkup(t *testing.T) {
+	data := `<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en" trgLang="es">
+<file id="f1" original="a.md"><unit id="u0"><segment><source>Hi <pc id="1">there</pc></source></segment></unit></file></xliff>`
+	_, err := DecodeXLIFF([]byte(data))
+	require.ErrorContains(t, err, "unsupported inline element <pc>")
+}
+
+func TestPORoundTrip(t *testing.T) {
+	data := EncodePO(sampleExchangeFile())
+	require.Contains(t, string(data), "#, fuzzy\nmsgctxt \"docs/intro.md#1\"\n")
+	require.Contains(t, string(data), "msgid \"\"\n\"Line one\\n\"\n\"line two\"\n")
+
+	decoded, err := DecodePO(data)
+	require.NoError(t, err)
+	require.Equal(t, "en", decoded.SourceLanguage)
+	require.Equal(t, "es", decoded.TargetLanguage)
+	require.Len(t, decoded.Units, 3)
+
+	expected := sampleExchangeFile().Units
+	require.Equal(t, expected[0].Target, decoded.Units[0].Target)
+	// Fuzzy machine translations are not imported
+	require.Equal(t, "", decoded.Units[1].Target)
+	require.Equal(t, expected[1].Source, decoded.Units[1].Source)
+	require.Equal(t, expected[2].Source, decoded.Units[2].Source)
+	require.Equal(t, expected[2].SourceHash, decoded.Units[2].SourceHash)
+}
+
+func TestImportExchangeFileReportsStaleAndMismatchedUnits(t *testing.T) {
+	files := map[string]*TranslationFile{
+		"docs/intro.md": {SourcePath: "docs/intro.md", Status: StatusTranslated},
+		"docs/old.md":   {SourcePath: "docs/old.md", Status: StatusStale},
+	}
+	segments := []SegmentTranslation{
+		{Index: 0, SourceHash: SegmentHash("Getting started"), Source: "Getting started", Translation: "Empezando"},
+		{Index: 1, SourceHash: SegmentHash(`Run <ph id="0"/>`), Source: `Run <ph id="0"/>`, Translation: `Ejecuta <ph id="0"/>`, Placeholders: []string{"`make`"}},
+		{Index: 2, SourceHash: SegmentHash("Done"), Source: "Done", Translation: "Hecho"},
+	}
+
+	var applied []SegmentCorrection
+	store := segmentStore{
+		file: func(projectID int, language, sourcePath string) (*TranslationFile, error) {
+			if f, ok := files[sourcePath]; ok {
+				return f, nil
+			}
+			return nil, sql.ErrNoRows
+		},
+		segments: func(projectID int, language, sourcePath string) ([]SegmentTranslation, error) {
+			return segments, nil
+		},
+		apply: func(projectID int, language, sourcePath string, corrections []SegmentCorrection, editorID *int) error {
+			applied = append(applied, corrections...)
+			return nil
+		},
+	}
+
+	file := &ExchangeFile{TargetLanguage: "es", Units: []ExchangeUnit{
+		{Path: "docs/intro.md", Index: 0, SourceHash: SegmentHash("Getting started"), Target: "Primeros pasos"},
+		{Path: "docs/intro.md", Index: 1, Source: `Run <ph id="0"/>`, Target: "Ejecuta"},
+		{Path: "docs/intro.md", Index: 2, Source: "Finished", Target: "Terminado"},
+		{Path: "docs/intro.md", Index: 3, Target: "Extra"},
+		{Path: "docs/intro.md", Index: 2, Source: "Done", Target: "Hecho"},
+		{Path: "docs/old.md", Index: 0, Target: "Viejo"},
+		{Path: "docs/missing.md", Index: 0, Target: "Falta"},
+	}}
+
+	report, err := importExchangeFile(store, 1, "es", file, nil)
+	require.NoError(t, err)
+	require.Equal(t, []SegmentCorrection{{Index: 0, Translation: "Primeros pasos"}}, applied)
+	require.Equal(t, 1, report.Applied)
+	require.Equal(t, 1, report.Unchanged)
+	require.Equal(t, []string{"docs/intro.md"}, report.Files)
+	require.Equal(t, []UnitIssue{
+		{ID: "docs/intro.md#2", Reason: "source segment changed"},
+		{ID: "docs/old.md#0", Reason: "file is stale"},
+	}, report.Stale)
+	require.Equal(t, []UnitIssue{
+		{ID: "docs/intro.md#1", Reason: "missing placeholder 0"},
+		{ID: "docs/intro.md#3", Reason: "unknown segment"},
+		{ID: "docs/missing.md#0", Reason: "unknown file"},
+	}, report.Mismatched)
+
+	_, err = importExchangeFile(store, 1, "fr", file, nil)
+	require.ErrorIs(t, err, ErrInvalidCorrection)
+}
@@ -0,0 +1,31 @@
+package translation
+
+import (
+	"errors"
+
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+)
+
+// ErrExcludedFile is returned for files the project's file patterns exclude from translation
+var ErrExcludedFile = errors.New("file is excluded from translation")
+
+// Files provides the include and exclude rules of a project
+type Files interface {
+	Rules(projectID int) (project.FileRules, error)
+}
+
+// ProjectFiles reads file rules from the project tables
+type ProjectFiles struct{}
+
+// NewProjectFiles creates a file rule source backed by the shared database
+func NewProjectFiles() *ProjectFiles {
+	return &ProjectFiles{}
+}
+
+func (f *ProjectFiles) Rules(projectID int) (project.FileRules, error) {
+	proj, err := project.GetProjectByID(projectID)
+	if err != nil {
+		return project.FileRules{}, err
+	}
+	return proj.FileRules(), nil
+}
@@ -0,0 +1,32 @@
+package translation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+)
+
+type staticFiles project.FileRules
+
+func (f staticFiles) Rules(projectID int) (project.FileRules, error) {
+	return project.FileRules(f), nil
+}
+
+func TestPipelineSkipsExcludedFiles(t *testing.T) {
+	pipeline := &Pipeline{
+		Translator: NewStubProvider(),
+		Files:      staticFiles{Exclude: []string{"docs/api/**"}},
+	}
+	translate := func(path string) error {
+		_, err := pipeline.TranslateDocument(context.Background(), TranslateDocumentRequest{
+			ProjectID: 1, SourceLanguage: "en", TargetLanguage: "es", Path: path, Content: "Hello.\n",
+		})
+		return err
+	}
+
+	require.NoError(t, translate("docs/intro.md"))
+	require.ErrorIs(t, translate("docs/api/client.md"), ErrExcludedFile)
+	require.ErrorIs(t, translate("CHANGELOG.md"), ErrExcludedFile)
+}
@@ -0,0 +1,120 @@
+package translation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+)
+
+// Glossary provides the glossary terms that apply to a project and target language
+type Glossary interface {
+	Terms(projectID int, language string) ([]project.GlossaryTerm, error)
+}
+
+// ProjectGlossary reads glossary terms from the project tables
+type ProjectGlossary struct{}
+
+// NewProjectGlossary creates a glossary backed by the shared database
+func NewProjectGlossary() *ProjectGlossary {
+	return &ProjectGlossary{}
+}
+
+func (g *ProjectGlossary) Terms(projectID int, language string) ([]project.GlossaryTerm, error) {
+	return project.GetGlossaryTerms(projectID, language)
+}
+
+// GlossaryViolation flags a segment whose translation does not use a mandated term
+type GlossaryViolation struct {
+	Segment  int    `json:"segment"`
+	Term     string `json:"term"`
+	Expected string `json:"expected"`
+}
+
+// containsTerm reports whether term occurs in text as a whole word
+func containsTerm(text, term string, caseSensitive bool) bool {
+	if term == "" {
+		return false
+	}
+	if !caseSensitive {
+		text = strings.ToLower(text)
+		term = strings.ToLower(term)
+	}
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], term)
+		if i < 0 {
+			return false
+		}
+		start := offset + i
+		end := start + len(term)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
+
+// termsInSegments returns the terms that occur in at least one of the texts
+func termsInSegments(terms []project.GlossaryTerm, texts []string) []project.GlossaryTerm {
+	var relevant []project.GlossaryTerm
+	for _, term := range terms {
+		for _, text := range texts {
+			if containsTerm(text, term.Term, term.CaseSensitive) {
+				relevant = append(relevant, term)
+				break
+			}
+		}
+	}
+	return relevant
+}
+
+// CheckGlossary returns the terms found in source whose mandated form is missing from translation
+func CheckGlossary(index int, source, translation string, terms []project.GlossaryTerm) []GlossaryViolation {
+	var violations []GlossaryViolation
+	for _, term := range terms {
+		if !containsTerm(source, term.Term, term.CaseSensitive) {
+			continue
+		}
+		expected := term.Expected()
+		if containsTerm(translation, expected, term.CaseSensitive) || (!isWordBounded(expected) && strings.Contains(translation, expected)) {
+			continue
+		}
+		violations = append(violations, GlossaryViolation{Segment: index, Term: term.Term, Expected: expected})
+	}
+	return violations
+}
+
+// isWordBounded is false for scripts such as Chinese or Japanese, which do not separate words with spaces
+func isWordBounded(text string) bool {
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai) {
+			return false
+		}
+	}
+	return true
+}
+
+// glossaryPrompt lists the glossary rules for the system prompt
+func glossaryPrompt(terms []project.GlossaryTerm) string {
+	if len(terms) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nFollow this glossary strictly:")
+	for _, term := range terms {
+		if term.DoNotTranslate {
+			fmt.Fprintf(&sb, "\n- Keep %q untranslated.", term.Term)
+		} else {
+			fmt.Fprintf(&sb, "\n- Always translate %q as %q.", term.Term, term.Translation)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,70 @@
+package translation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+)
+
+type staticGlossary []project.GlossaryTerm
+
+func (g staticGlossary) Terms(projectID int, language string) ([]project.GlossaryTerm, error) {
+	return g, nil
+}
+
+func TestContainsTermMatchesWholeWords(t *testing.T) {
+	require.True(t, containsTerm("Install the CLI now", "cli", false))
+	require.False(t, containsTerm("Install the CLI now", "cli", true))
+	require.False(t, containsTerm("Clients connect", "CLI", false))
+	require.True(t, containsTerm("Use --force, then retry", "--force", true))
+	require.True(t, containsTerm("Abre el panel de Xeo.", "Xeo", true))
+}
+
+func TestCheckGlossary(t *testing.T) {
+	terms := []project.GlossaryTerm{
+		{Term: "XeoDocs", DoNotTranslate: true, CaseSensitive: true},
+		{Term: "repository", Translation: "repositorio"},
+		{Term: "pull request", Translation: "プルリクエスト"},
+	}
+
+	require.Empty(t, CheckGlossary(0, "Open the XeoDocs repository.", "Abre el repositorio de XeoDocs.", terms))
+	require.Equal(t, []GlossaryViolation{
+		{Segment: 1, Term: "XeoDocs", Expected: "XeoDocs"},
+		{Segment: 1, Term: "repository", Expected: "repositorio"},
+	}, CheckGlossary(1, "Open the XeoDocs repository.", "Abre el repo de Xeodocs.", terms))
+	require.Empty(t, CheckGlossary(2, "Open a pull request.", "プルリクエストを開きます。", terms))
+}
+
+func TestPipelineFlagsGlossaryViolations(t *testing.T) {
+	translator := &recordingTranslator{}
+	memory := newFakeMemory()
+	pipeline := &Pipeline{
+		Translator: translator,
+		Memory:     memory,
+		Glossary: staticGlossary{
+			{Term: "XeoDocs", DoNotTranslate: true, CaseSensitive: true},
+			{Term: "workspace", Translation: "espacio de trabajo"},
+			{Term: "unused", Translation: "sin usar"},
+		},
+	}
+
+	result, err := pipeline.TranslateDocument(context.Background(), TranslateDocumentRequest{
+		ProjectID:      1,
+		SourceLanguage: "en",
+		TargetLanguage: "es",
+		Path:           "intro.md",
+		Content:        "Welcome to XeoDocs.\n\nCreate a workspace.\n",
+	})
+	require.NoError(t, err)
+
+	// The stub keeps English text, so only the fixed translation is missing
+	require.Equal(t, []GlossaryViolation{{Segment: 1, Term: "workspace", Expected: "espacio de trabajo"}}, result.GlossaryViolations)
+	require.Len(t, result.Warnings, 1)
+	require.Len(t, memory.entries, 1)
+
+	require.Len(t, translator.requests[0].Glossary, 2)
+	require.Contains(t, systemPrompt(translator.requests[0]), `Always translate "workspace" as "espacio de trabajo".`)
+	require.Contains(t, systemPrompt(translator.requests[0]), `Keep "XeoDocs" untranslated.`)
+}
@@ -1 +1,992 @@
 package translation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/auth"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/logging"
+)
+
+// TranslateHandler handles POST /internal/translate to translate a batch of segments
+func TranslateHandler(cfg *config.Config, translator Translator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req TranslateSegmentsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		if req.TargetLanguage == "" {
+			http.Error(w, "targetLanguage is required", http.StatusBadRequest)
+			return
+		}
+		if req.SourceLanguage == "" {
+			req.SourceLanguage = "en"
+		}
+
+		result, err := translator.Translate(r.Context(), TranslateRequest{
+			SourceLanguage: req.SourceLanguage,
+			TargetLanguage: req.TargetLanguage,
+			Segments:       req.Segments,
+		})
+		if err != nil {
+			log.Printf("Error translating segments: %v", err)
+			message := fmt.Sprintf("Translation to %s failed with provider %s: %v", req.TargetLanguage, translator.Name(), err)
+			logging.LogActivity(cfg.LoggingServiceURL, "translation_error", message, nil, projectIDPtr(req.ProjectID), "error")
+			writeProviderError(w, err, "Failed to translate segments")
+			return
+		}
+		provider := result.Provider
+		if provider == "" {
+			provider = translator.Name()
+		}
+
+		// Log the translation
+		message := fmt.Sprintf("Translated %d segments to %s with provider %s", len(req.Segments), req.TargetLanguage, provider)
+		logging.LogActivity(cfg.LoggingServiceURL, "segments_translated", message, nil, projectIDPtr(req.ProjectID), "info")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(TranslateSegmentsResponse{
+			Translations: result.Translations,
+			Provider:     provider,
+			Usage:        result.Usage,
+		})
+	}
+}
+
+// writeProviderError answers 503 with a Retry-After hint when the provider is
+// unavailable, so callers can postpone the work, and 502 for other failures
+func writeProviderError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, ErrProviderUnavailable) || isTemporary(err) {
+		if wait := ProviderRetryAfter(err); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		}
+		http.Error(w, ErrProviderUnavailable.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, message, http.StatusBadGateway)
+}
+
+// projectIDPtr returns nil for unset project IDs so logs are not attached to project 0
+func projectIDPtr(projectID int) *int {
+	if projectID == 0 {
+		return nil
+	}
+	return &projectID
+}
+
+// TranslateDocumentHandler handles POST /internal/translate-document to translate a whole file
+func TranslateDocumentHandler(cfg *config.Config, pipeline *Pipeline) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req TranslateDocumentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		if req.TargetLanguage == "" {
+			http.Error(w, "targetLanguage is required", http.StatusBadRequest)
+			return
+		}
+		if req.Path == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+		if req.SourceLanguage == "" {
+			req.SourceLanguage = "en"
+		}
+
+		// Track the file status when the document belongs to a project
+		tracked := req.ProjectID != 0
+		if tracked {
+			if err := MarkFileTranslating(req.ProjectID, req.TargetLanguage, req.Path, GitBlobHash([]byte(req.Content))); err != nil {
+				log.Printf("Error updating translation status of %s: %v", req.Path, err)
+			}
+		}
+
+		result, err := pipeline.TranslateDocument(r.Context(), req)
+		if err != nil {
+			if tracked {
+				// Requests answered before the failure are billed all the same
+				recordDocumentUsage(req, result)
+				if err := MarkFileFailed(req.ProjectID, req.TargetLanguage, req.Path, err.Error()); err != nil {
+					log.Printf("Error updating translation status of %s: %v", req.Path, err)
+				}
+			}
+			if errors.Is(err, ErrUnsupportedFormat) || errors.Is(err, ErrExcludedFile) {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			log.Printf("Error translating document %s: %v", req.Path, err)
+			message := fmt.Sprintf("Translation of %s to %s failed with provider %s: %v", req.Path, req.TargetLanguage, pipeline.Translator.Name(), err)
+			logging.LogActivity(cfg.LoggingServiceURL, "translation_error", message, nil, projectIDPtr(req.ProjectID), "error")
+			writeProviderError(w, err, "Failed to translate document")
+			return
+		}
+
+		if tracked {
+			// Reviewed files are published even when quality checks fail. Published
+			// files are marked translated by the repository service once committed.
+			status, err := RecordFileTranslation(req.ProjectID, req.TargetLanguage, req.Path, GitBlobHash([]byte(result.Content)), result.Provider, !result.NeedsReview)
+			if err != nil {
+				log.Printf("Error updating translation status of %s: %v", req.Path, err)
+			} else {
+				result.NeedsReview = status == StatusNeedsReview
+			}
+			if err := SaveFileSegments(req.ProjectID, req.TargetLanguage, req.Path, result.Translations); err != nil {
+				log.Printf("Error saving segments of %s: %v", req.Path, err)
+			}
+			if err := SaveQAFindings(req.ProjectID, req.TargetLanguage, req.Path, result.QAFindings); err != nil {
+				log.Printf("Error saving quality findings of %s: %v", req.Path, err)
+			}
+		}
+
+		if req.JobID != "" && tracked {
+			if err := RecordTranslationJob(req.JobID, req.ProjectID, req.TargetLanguage, result.Memory); err != nil {
+				log.Printf("Error recording translation job %s: %v", req.JobID, err)
+			}
+		}
+
+		if tracked {
+			recordDocumentUsage(req, result)
+		}
+
+		// Log the document translation
+		message := fmt.Sprintf("Translated %s to %s (%d segments, %d memory hits, %d requests, %d tokens, $%.4f, %d glossary violations, %d warnings, %d quality findings)",
+			req.Path, req.TargetLanguage, result.Segments, result.Memory.ExactHits, result.Requests, result.Usage.PromptTokens+result.Usage.CompletionTokens,
+			result.Cost, len(result.GlossaryViolations), len(result.Warnings), len(result.QAFindings))
+		if result.NeedsReview {
+			message += ", held for review"
+		}
+		level := "info"
+		if len(result.Warnings) > 0 || result.NeedsReview {
+			level = "warning"
+		}
+		logging.LogActivity(cfg.LoggingServiceURL, "document_translated", message, nil, projectIDPtr(req.ProjectID), level)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// recordDocumentUsage stores the provider usage of a document translation, if it made any requests
+func recordDocumentUsage(req TranslateDocumentRequest, result *TranslateDocumentResponse) {
+	if result == nil || result.Requests == 0 {
+		return
+	}
+	err := RecordUsage(UsageRecord{
+		ProjectID:  req.ProjectID,
+		Language:   req.TargetLanguage,
+		JobID:      req.JobID,
+		SourcePath: req.Path,
+		Provider:   result.Provider,
+		Requests:   result.Requests,
+		Usage:      result.Usage,
+		Cost:       result.Cost,
+	})
+	if err != nil {
+		log.Printf("Error recording provider usage of %s: %v", req.Path, err)
+	}
+}
+
+// CommitFailedHandler handles POST /internal/commit-failed to mark translated
+// files that never reached the language branch as failed
+func CommitFailedHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req CommitFailedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if req.ProjectID == 0 || req.Language == "" {
+			http.Error(w, "projectId and language are required", http.StatusBadRequest)
+			return
+		}
+
+		message := "commit failed: " + req.Error
+		for _, file := range req.Files {
+			if err := MarkFileCommitFailed(req.ProjectID, req.Language, file, message); err != nil {
+				log.Printf("Error updating translation status of %s: %v", file, err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// GetJobHandler handles GET /internal/jobs/{id} to report translation memory hit counts of a job
+func GetJobHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		jobID := strings.TrimPrefix(r.URL.Path, "/internal/jobs/")
+		if jobID == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		job, err := GetTranslationJob(jobID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Job not found", http.StatusNotFound)
+			} else {
+				log.Println("Error getting translation job:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
+// ListTranslationsHandler handles GET /projects/{id}/translations with optional
+// language, status and path filters, and summarizes coverage per language
+func ListTranslationsHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, err := projectIDFromPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		query := r.URL.Query()
+		filter := TranslationFileFilter{
+			Language:   query.Get("language"),
+			Status:     query.Get("status"),
+			PathPrefix: query.Get("path"),
+		}
+		if filter.Status != "" && !IsFileStatus(filter.Status) {
+			http.Error(w, "Invalid status", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := project.GetProjectByID(projectID); err != nil {
+			if err.Error() == "project not found" {
+				http.Error(w, "Project not found", http.StatusNotFound)
+			} else {
+				log.Println("Error getting project:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		files, err := GetTranslationFiles(projectID, filter)
+		if err != nil {
+			log.Println("Error getting translation files:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		response := map[string]interface{}{
+			"files":   files,
+			"total":   len(files),
+			"summary": CoverageSummary(files),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// GetUsageHandler handles GET /projects/{id}/usage?from=&to= and returns the tokens
+// and estimated cost of AI translation per language and per job. from and to
+// accept RFC 3339 timestamps or YYYY-MM-DD dates; to is exclusive.
+func GetUsageHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, err := projectIDFromPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		query := r.URL.Query()
+		from, err := parseTimeParam(query.Get("from"))
+		if err != nil {
+			http.Error(w, "Invalid from", http.StatusBadRequest)
+			return
+		}
+		to, err := parseTimeParam(query.Get("to"))
+		if err != nil {
+			http.Error(w, "Invalid to", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := project.GetProjectByID(projectID); err != nil {
+			if err.Error() == "project not found" {
+				http.Error(w, "Project not found", http.StatusNotFound)
+			} else {
+				log.Println("Error getting project:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		usage, err := GetProjectUsage(projectID, from, to)
+		if err != nil {
+			log.Println("Error getting translation usage:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(usage)
+	}
+}
+
+// parseTimeParam parses an optional RFC 3339 timestamp or YYYY-MM-DD date
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		if t, err = time.Parse("2006-01-02", value); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+// getUserIDFromContext extracts user ID from request context
+func getUserIDFromContext(ctx context.Context) *int {
+	claims, ok := ctx.Value("claims").(*auth.Claims)
+	if !ok {
+		return nil
+	}
+	return &claims.UserID
+}
+
+// parseReviewPath extracts the project ID, language and action from
+// /projects/{id}/translations/{language}/{action}
+func parseReviewPath(path string) (projectID int, language, action string, err error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/projects/"), "/"), "/")
+	if len(parts) != 4 || parts[1] != "translations" || parts[2] == "" {
+		return 0, "", "", errors.New("invalid translation path")
+	}
+	if projectID, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, "", "", errors.New("invalid project ID")
+	}
+	return projectID, parts[2], parts[3], nil
+}
+
+// writeReviewError maps review errors to HTTP responses
+func writeReviewError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Translation not found", http.StatusNotFound)
+	case errors.Is(err, ErrInvalidCorrection):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrNotReviewable), errors.Is(err, ErrStaleSegments):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Println("Error reviewing translation:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// GetReviewHandler handles GET /projects/{id}/translations/{language}/segments?path=
+// and returns the source and translated segments of a file side-by-side
+func GetReviewHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, language, _, err := parseReviewPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sourcePath := r.URL.Query().Get("path")
+		if sourcePath == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+
+		file, err := GetTranslationFile(projectID, language, sourcePath)
+		if err != nil {
+			writeReviewError(w, err)
+			return
+		}
+		segments, err := GetFileSegments(projectID, language, sourcePath)
+		if err != nil {
+			writeReviewError(w, err)
+			return
+		}
+
+		findings, err := GetQAFindings(projectID, language, sourcePath)
+		if err != nil {
+			writeReviewError(w, err)
+			return
+		}
+
+		response := map[string]interface{}{
+			"file":        file,
+			"segments":    segments,
+			"qa_findings": findings,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// GetQAFindingsHandler handles GET /projects/{id}/translations/{language}/qa?path=
+// and returns the quality check findings of the latest translation of a file
+func GetQAFindingsHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, language, _, err := parseReviewPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sourcePath := r.URL.Query().Get("path")
+		if sourcePath == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+
+		file, err := GetTranslationFile(projectID, language, sourcePath)
+		if err != nil {
+			writeReviewError(w, err)
+			return
+		}
+		findings, err := GetQAFindings(projectID, language, sourcePath)
+		if err != nil {
+			writeReviewError(w, err)
+			return
+		}
+
+		response := map[string]interface{}{
+			"file":     file,
+			"passed":   QAPassed(findings),
+			"findings": findings,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// GetStaleReportHandler handles GET /projects/{id}/translations/{language}/stale
+// and lists the reviewed files whose source changed upstream, with their stale segments
+func GetStaleReportHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, language, _, err := parseReviewPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		report, err := GetStaleReport(projectID, language)
+		if err != nil {
+			writeReviewError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// SubmitCorrectionsHandler handles PUT /projects/{id}/translations/{language}/segments?path=
+func SubmitCorrectionsHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, language, _, err := parseReviewPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sourcePath := r.URL.Query().Get("path")
+		if sourcePath == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+
+		var req ReviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if len(req.Segments) == 0 {
+			http.Error(w, "segments is required", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := reviewableFile(projectID, language, sourcePath); err != nil {
+			writeReviewError(w, err)
+			return
+		}
+
+		userID := getUserIDFromContext(r.Context())
+		if err := ApplyCorrections(projectID, language, sourcePath, req.Segments, userID); err != nil {
+			writeReviewError(w, err)
+			return
+		}
+
+		segments, err := GetFileSegments(projectID, language, sourcePath)
+		if err != nil {
+			writeReviewError(w, err)
+			return
+		}
+
+		// Log the corrections
+		message := fmt.Sprintf("Corrected %d segments of %s (%s)", len(req.Segments), sourcePath, language)
+		logging.LogActivity(cfg.LoggingServiceURL, "translation_corrected", message, userID, &projectID, "info")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"segments": segments})
+	}
+}
+
+// ApproveFileHandler handles POST /projects/{id}/translations/{language}/approve?path=
+func ApproveFileHandler(cfg *config.Config, memory Memory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, language, _, err := parseReviewPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sourcePath := r.URL.Query().Get("path")
+		if sourcePath == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+
+		var req ReviewRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request", http.StatusBadRequest)
+				return
+			}
+		}
+
+		userID := getUserIDFromContext(r.Context())
+		render, err := ApproveFile(memory, projectID, language, sourcePath, userID, req.Comment)
+		if err != nil {
+			writeReviewError(w, err)
+			return
+		}
+
+		// Render the file again so the published copy contains the approved translation
+		if render {
+			if err := EnqueueFileTranslation(cfg, projectID, language, sourcePath); err != nil {
+				log.Printf("Error enqueueing translation of %s: %v", sourcePath, err)
+			}
+		}
+
+		file, err := GetTranslationFile(projectID, language, sourcePath)
+		if err != nil {
+			writeReviewError(w, err)
+			return
+		}
+
+		// Log the approval
+		message := fmt.Sprintf("Translation of %s (%s) approved", sourcePath, language)
+		logging.LogActivity(cfg.LoggingServiceURL, "translation_approved", message, userID, &projectID, "info")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(file)
+	}
+}
+
+// RejectFileHandler handles POST /projects/{id}/translations/{language}/reject?path=
+func RejectFileHandler(cfg *config.Config, memory Memory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, language, _, err := parseReviewPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sourcePath := r.URL.Query().Get("path")
+		if sourcePath == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+
+		var req ReviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Comment == "" {
+			http.Error(w, "comment is required", http.StatusBadRequest)
+			return
+		}
+
+		userID := getUserIDFromContext(r.Context())
+		if err := RejectFile(memory, projectID, language, sourcePath, userID, req.Comment); err != nil {
+			writeReviewError(w, err)
+			return
+		}
+
+		if err := EnqueueFileTranslation(cfg, projectID, language, sourcePath); err != nil {
+			log.Printf("Error enqueueing translation of %s: %v", sourcePath, err)
+		}
+
+		file, err := GetTranslationFile(projectID, language, sourcePath)
+		if err != nil {
+			writeReviewError(w, err)
+			return
+		}
+
+		// Log the rejection
+		message := fmt.Sprintf("Translation of %s (%s) rejected: %s", sourcePath, language, req.Comment)
+		logging.LogActivity(cfg.LoggingServiceURL, "translation_rejected", message, userID, &projectID, "info")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(file)
+	}
+}
+
+// maxImportSize caps the size of uploaded XLIFF and PO files
+const maxImportSize = 20 << 20
+
+// ExportHandler handles GET /projects/{id}/translations/{language}/export?format=xliff|po
+// with optional units (pending or translated), path prefix and source_language parameters
+func ExportHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, language, _, err := parseReviewPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		query := r.URL.Query()
+		format := query.Get("format")
+		if format == "" {
+			format = FormatXLIFF
+		}
+		which := query.Get("units")
+		if which != "" && which != ExportPending && which != ExportTranslated {
+			http.Error(w, "units must be pending or translated", http.StatusBadRequest)
+			return
+		}
+		sourceLanguage := query.Get("source_language")
+		if sourceLanguage == "" {
+			sourceLanguage = "en"
+		}
+
+		units, err := CollectExchangeUnits(projectID, language, query.Get("path"), which)
+		if err != nil {
+			log.Println("Error collecting segments:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		data, err := EncodeExchangeFile(format, ExchangeFile{SourceLanguage: sourceLanguage, TargetLanguage: language, Units: units})
+		if err != nil {
+			if errors.Is(err, ErrUnsupportedExchangeFormat) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			} else {
+				log.Println("Error encoding export:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// Log the export
+		userID := getUserIDFromContext(r.Context())
+		message := fmt.Sprintf("Exported %d segments for %s as %s", len(units), language, format)
+		logging.LogActivity(cfg.LoggingServiceURL, "translations_exported", message, userID, &projectID, "info")
+
+		filename := fmt.Sprintf("project-%d-%s.xlf", projectID, language)
+		contentType := "application/xliff+xml"
+		if format == FormatPO {
+			filename = fmt.Sprintf("project-%d-%s.po", projectID, language)
+			contentType = "text/x-gettext-translation; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.Write(data)
+	}
+}
+
+// ImportHandler handles POST /projects/{id}/translations/{language}/import?format=xliff|po.
+// Valid units are stored as corrections; stale and mismatched units are reported.
+func ImportHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, language, _, err := parseReviewPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = FormatXLIFF
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+		if err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		file, err := DecodeExchangeFile(format, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		userID := getUserIDFromContext(r.Context())
+		report, err := ImportExchangeFile(projectID, language, file, userID)
+		if err != nil {
+			writeReviewError(w, err)
+			return
+		}
+
+		// Log the import
+		message := fmt.Sprintf("Imported %s for %s: %d applied, %d stale, %d mismatched", format, language, report.Applied, len(report.Stale), len(report.Mismatched))
+		level := "info"
+		if len(report.Stale) > 0 || len(report.Mismatched) > 0 {
+			level = "warning"
+		}
+		logging.LogActivity(cfg.LoggingServiceURL, "translations_imported", message, userID, &projectID, level)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// PromptPreviewRequest is the body of POST /projects/{id}/translations/{language}/prompt
+// Content is read from the project's source checkout at Ref when it is empty.
+type PromptPreviewRequest struct {
+	Path           string `json:"path"`
+	Content        string `json:"content"`
+	Ref            string `json:"ref"`
+	SourceLanguage string `json:"source_language"`
+}
+
+// PreviewPromptHandler handles POST /projects/{id}/translations/{language}/prompt.
+// It renders the prompts that translating the given file would send to the provider,
+// without calling it.
+func PreviewPromptHandler(cfg *config.Config, pipeline *Pipeline) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		projectID, language, _, err := parseReviewPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req PromptPreviewRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportSize)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if req.Path == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+		if !IsSupportedFile(req.Path) {
+			http.Error(w, "path is not a translatable file", http.StatusBadRequest)
+			return
+		}
+		if req.SourceLanguage == "" {
+			req.SourceLanguage = "en"
+		}
+		if req.Content != "" && req.Ref != "" {
+			http.Error(w, "content and ref cannot be combined", http.StatusBadRequest)
+			return
+		}
+
+		proj, err := project.GetProjectByID(projectID)
+		if err != nil {
+			if err.Error() == "project not found" {
+				http.Error(w, "Project not found", http.StatusNotFound)
+			} else {
+				log.Println("Error getting project:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+		if !proj.HasLanguage(language) {
+			http.Error(w, "language is not configured for this project", http.StatusBadRequest)
+			return
+		}
+
+		// Without pasted content the file is previewed as it is in the repository
+		if req.Content == "" {
+			req.Content, err = fetchSourceFile(cfg, r.Header.Get("Authorization"), projectID, req.Path, req.Ref)
+			if err != nil {
+				writeSourceFileError(w, err)
+				return
+			}
+		}
+
+		preview, err := pipeline.PreviewPrompts(TranslateDocumentRequest{
+			ProjectID:      projectID,
+			SourceLanguage: req.SourceLanguage,
+			TargetLanguage: language,
+			Path:           req.Path,
+			Content:        req.Content,
+		})
+		if err != nil {
+			if errors.Is(err, project.ErrInvalidPromptTemplate) || errors.Is(err, ErrExcludedFile) {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			} else {
+				log.Println("Error previewing prompts:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(preview)
+	}
+}
+
+var (
+	// errSourceFileNotFound is returned when the repository has no such file or ref
+	errSourceFileNotFound = errors.New("source file not found")
+	// errSourceFileUnusable is returned for binary files and files too large to translate
+	errSourceFileUnusable = errors.New("source file cannot be translated")
+)
+
+// sourceFileClient reads source files from the repository service
+var sourceFileClient = &http.Client{Timeout: 30 * time.Second}
+
+// fetchSourceFile reads a file of the project's source checkout at ref, HEAD when
+// empty, from the repository service's raw file endpoint. authorization is the
+// caller's Authorization header, so the repository service checks the same user.
+func fetchSourceFile(cfg *config.Config, authorization string, projectID int, path, ref string) (string, error) {
+	query := url.Values{"path": {path}}
+	if ref != "" {
+		query.Set("ref", ref)
+	}
+	endpoint := fmt.Sprintf("%s/projects/%d/files/raw?%s", strings.TrimSuffix(cfg.RepositoryServiceURL, "/"), projectID, query.Encode())
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := sourceFileClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call repository service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("%w: %s", errSourceFileNotFound, path)
+	case http.StatusUnprocessableEntity:
+		return "", fmt.Errorf("%w: %s is too large", errSourceFileUnusable, path)
+	default:
+		return "", fmt.Errorf("repository service returned status %d", resp.StatusCode)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		return "", fmt.Errorf("%w: %s is a binary file", errSourceFileUnusable, path)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// writeSourceFileError maps fetchSourceFile errors to HTTP responses
+func writeSourceFileError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errSourceFileNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errSourceFileUnusable):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		log.Println("Error reading source file:", err)
+		http.Error(w, "Failed to read source file", http.StatusBadGateway)
+	}
+}
+
+// projectIDFromPath extracts {id} from /projects/{id}/...
+func projectIDFromPath(path string) (int, error) {
+	rest := strings.TrimPrefix(path, "/projects/")
+	if i := strings.Index(rest, "/"); i >= 0 {
+		rest = rest[:i]
+	}
+	return strconv.Atoi(rest)
+}
@@ -0,0 +1,165 @@
+package translation
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+)
+
+// Links provides the link rewrite rules of a project for a target language
+type Links interface {
+	Rules(projectID int, language string) (project.LinkRules, bool, error)
+}
+
+// ProjectLinks reads link rewrite rules from the project tables
+type ProjectLinks struct{}
+
+// NewProjectLinks creates a link rule source backed by the shared database
+func NewProjectLinks() *ProjectLinks {
+	return &ProjectLinks{}
+}
+
+func (l *ProjectLinks) Rules(projectID int, language string) (project.LinkRules, bool, error) {
+	proj, err := project.GetProjectByID(projectID)
+	if err != nil {
+		return project.LinkRules{}, false, err
+	}
+	rules, ok := proj.LinkRulesFor(language)
+	return rules, ok, nil
+}
+
+var (
+	refDefinitionTargetPattern = regexp.MustCompile(`^( {0,3}\[[^\]^][^\]]*\]:[ \t]*<?)([^\s>]+)`)
+	hrefAttributePattern       = regexp.MustCompile(`(\b(?:href|to)=["'])([^"']+)`)
+)
+
+// RewriteLinks localizes the links of a translated Markdown file: link and reference
+// destinations, autolinks, bare URLs and href or to attributes of tags. Images,
+// code and front matter are left intact. Other file types are returned unchanged.
+func RewriteLinks(path, content string, rules project.LinkRules) string {
+	if _, ok := documentParsers[strings.ToLower(filepath.Ext(path))]; !ok {
+		return content
+	}
+
+	var sb strings.Builder
+	lines := splitLines(content)
+	start := 0
+	if len(lines) > 0 && strings.TrimSpace(lines[0].text) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if t := strings.TrimSpace(lines[i].text); t == "---" || t == "..." {
+				start = i + 1
+				break
+			}
+		}
+	}
+	for _, l := range lines[:start] {
+		sb.WriteString(l.text + l.eol)
+	}
+
+	var fence string
+	for _, l := range lines[start:] {
+		text := l.text
+		switch {
+		case fence != "":
+			if strings.HasPrefix(strings.TrimLeft(text, " "), fence) && strings.Trim(strings.TrimSpace(text), fence[:1]) == "" {
+				fence = ""
+			}
+		case fencePattern.MatchString(text):
+			fence = fencePattern.FindStringSubmatch(text)[1]
+		case refDefinitionTargetPattern.MatchString(text):
+			text = refDefinitionTargetPattern.ReplaceAllStringFunc(text, func(match string) string {
+				m := refDefinitionTargetPattern.FindStringSubmatch(match)
+				return m[1] + rules.Rewrite(m[2])
+			})
+		default:
+			text = rewriteInlineLinks(text, rules)
+		}
+		sb.WriteString(text + l.eol)
+	}
+	return sb.String()
+}
+
+// rewriteInlineLinks rewrites the links of one line, skipping code spans and images
+func rewriteInlineLinks(s string, rules project.LinkRules) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		end := -1
+		replacement := ""
+
+		switch s[i] {
+		case '\\':
+			end = min(i+2, len(s))
+			replacement = s[i:end]
+		case '`':
+			if end = scanCodeSpan(s, i); end > 0 {
+				replacement = s[i:end]
+			}
+		case '!':
+			if _, end = scanLink(s, i); end > 0 {
+				replacement = s[i:end]
+			}
+		case '[':
+			if _, end = scanLink(s, i); end > 0 {
+				replacement = rewriteLinkDestination(s[i:end], rules)
+			}
+		case '<':
+			if m := autolinkPattern.FindString(s[i:]); m != "" {
+				end = i + len(m)
+				replacement = "<" + rules.Rewrite(m[1:len(m)-1]) + ">"
+			} else if end = scanTag(s, i); end > 0 {
+				replacement = hrefAttributePattern.ReplaceAllStringFunc(s[i:end], func(match string) string {
+					m := hrefAttributePattern.FindStringSubmatch(match)
+					return m[1] + rules.Rewrite(m[2])
+				})
+			}
+		case 'h':
+			if m := bareURLPattern.FindString(s[i:]); m != "" && (i == 0 || !isWordByte(s[i-1])) {
+				url := strings.TrimRight(m, `.,;:!?'"`)
+				end = i + len(url)
+				replacement = rules.Rewrite(url)
+			}
+		}
+
+		if end <= i {
+			sb.WriteByte(s[i])
+			i++
+			continue
+		}
+		sb.WriteString(replacement)
+		i = end
+	}
+	return sb.String()
+}
+
+// rewriteLinkDestination rewrites the destination of an inline link such as
+// [text](/docs/intro "title"), keeping the link text and title as they are
+func rewriteLinkDestination(link string, rules project.LinkRules) string {
+	if !strings.HasSuffix(link, ")") {
+		return link
+	}
+	open := strings.LastIndex(link, "](")
+	if open < 0 {
+		return link
+	}
+	dest := link[open+2 : len(link)-1]
+	lead := len(dest) - len(strings.TrimLeft(dest, " \t"))
+	target := dest[lead:]
+	angle := strings.HasPrefix(target, "<")
+	if angle {
+		target = target[1:]
+	}
+	stop := strings.IndexAny(target, " \t>")
+	if stop < 0 {
+		stop = len(target)
+	}
+	rest := target[stop:]
+	target = target[:stop]
+
+	prefix := link[:open+2] + dest[:lead]
+	if angle {
+		prefix += "<"
+	}
+	return prefix + rules.Rewrite(target) + rest + ")"
+}
@@ -0,0 +1,112 @@
+package translation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+)
+
+type staticLinks project.Project
+
+func (l *staticLinks) Rules(projectID int, language string) (project.LinkRules, bool, error) {
+	rules, ok := (*project.Project)(l).LinkRulesFor(language)
+	return rules, ok, nil
+}
+
+func linkRules(t *testing.T, docURL string, rewrite project.LinkRewrite, language string) project.LinkRules {
+	t.Helper()
+	rules, ok := (&project.Project{DocURL: docURL, LinkRewrite: rewrite}).LinkRulesFor(language)
+	require.True(t, ok)
+	return rules
+}
+
+func TestLinkRulesRewrite(t *testing.T) {
+	mkdocs := linkRules(t, "https://docs.example.com/guide/", project.LinkRewrite{Style: project.LinkStyleMkDocs}, "es")
+
+	require.Equal(t, "/guide/es/install/", mkdocs.Rewrite("/guide/install/"))
+	require.Equal(t, "https://docs.example.com/guide/es/install/#linux", mkdocs.Rewrite("https://docs.example.com/guide/install/#linux"))
+	require.Equal(t, "https://DOCS.example.com/guide/es", mkdocs.Rewrite("https://DOCS.example.com/guide"))
+	require.Equal(t, "/guide/es/api.html?v=2", mkdocs.Rewrite("/guide/api.html?v=2"))
+
+	for _, unchanged := range []string{
+		"../install/",                              // resolves inside the language copy
+		"#options",                                 // same page
+		"https://github.com/example/docs",          // other site
+		"/blog/post/",                              // outside the doc site
+		"/guide/es/install/",                       // already localized
+		"/guide/assets/logo.svg",                   // excluded
+		"/guide/files/report.pdf",                  // asset
+		"mailto:docs@example.com",                  // not a page
+		"https://docs.example.com.evil.com/guide/", // lookalike host
+	} {
+		require.Equal(t, unchanged, mkdocs.Rewrite(unchanged), unchanged)
+	}
+}
+
+func TestLinkRulesStyles(t *testing.T) {
+	// Docusaurus adds the locale to root-relative links itself
+	docusaurus := linkRules(t, "https://docs.example.com", project.LinkRewrite{Locales: map[string]string{"pt": "pt-BR"}}, "pt")
+	require.Equal(t, "/docs/intro", docusaurus.Rewrite("/docs/intro"))
+	require.Equal(t, "https://docs.example.com/pt-BR/docs/intro", docusaurus.Rewrite("https://docs.example.com/docs/intro"))
+
+	hugo := linkRules(t, "https://example.com/", project.LinkRewrite{Style: project.LinkStyleHugo}, "zh-TW")
+	require.Equal(t, "/zh-tw/posts/first/", hugo.Rewrite("/posts/first/"))
+
+	custom := linkRules(t, "https://example.com/", project.LinkRewrite{Style: project.LinkStyleHugo, PathPrefix: "/intl/{locale}", Exclude: []string{"/api/"}}, "fr")
+	require.Equal(t, "/intl/fr/posts/", custom.Rewrite("/posts/"))
+	require.Equal(t, "/api/v1/", custom.Rewrite("/api/v1/"))
+
+	_, ok := (&project.Project{DocURL: "https://example.com", LinkRewrite: project.LinkRewrite{Style: project.LinkStyleNone}}).LinkRulesFor("fr")
+	require.False(t, ok)
+}
+
+func TestLinkRewriteValidate(t *testing.T) {
+	languages := project.Languages{"en", "es"}
+	require.NoError(t, project.LinkRewrite{}.Validate(languages))
+	require.NoError(t, project.LinkRewrite{Style: project.LinkStyleHugo, PathPrefix: "/{locale}", Locales: map[string]string{"es": "es-419"}}.Validate(languages))
+	require.ErrorIs(t, project.LinkRewrite{Style: "jekyll"}.Validate(languages), project.ErrInvalidLinkRewrite)
+	require.ErrorIs(t, project.LinkRewrite{PathPrefix: "/lang"}.Validate(languages), project.ErrInvalidLinkRewrite)
+	require.ErrorIs(t, project.LinkRewrite{Locales: map[string]string{"de": "de"}}.Validate(languages), project.ErrInvalidLinkRewrite)
+}
+
+func TestRewriteLinks(t *testing.T) {
+	rules := linkRules(t, "https://docs.example.com", project.LinkRewrite{Style: project.LinkStyleMkDocs}, "es")
+	content := "---\n" +
+		"canonical: https://docs.example.com/setup/\n" +
+		"---\n\n" +
+		"See [setup](/setup/ \"Setup\") and [the API](<https://docs.example.com/api/>).\n" +
+		"![Diagram](/diagram/) and `[code](/setup/)` stay, as do [relative](../setup/) links.\n" +
+		"Visit https://docs.example.com/faq/. or <https://docs.example.com/help/>.\n" +
+		"<a href=\"/setup/\">Setup</a> [ref]\n\n" +
+		"```\ncurl https://docs.example.com/setup/\n```\n\n" +
+		"[ref]: /reference/\n"
+
+	expected := "---\n" +
+		"canonical: https://docs.example.com/setup/\n" +
+		"---\n\n" +
+		"See [setup](/es/setup/ \"Setup\") and [the API](<https://docs.example.com/es/api/>).\n" +
+		"![Diagram](/diagram/) and `[code](/setup/)` stay, as do [relative](../setup/) links.\n" +
+		"Visit https://docs.example.com/es/faq/. or <https://docs.example.com/es/help/>.\n" +
+		"<a href=\"/es/setup/\">Setup</a> [ref]\n\n" +
+		"```\ncurl https://docs.example.com/setup/\n```\n\n" +
+		"[ref]: /es/reference/\n"
+
+	require.Equal(t, expected, RewriteLinks("docs/intro.md", content, rules))
+	require.Equal(t, `{"link": "/setup/"}`, RewriteLinks("i18n/es.json", `{"link": "/setup/"}`, rules))
+}
+
+func TestPipelineRewritesLinksAfterQualityChecks(t *testing.T) {
+	pipeline := &Pipeline{
+		Translator: NewStubProvider(),
+		Links:      &staticLinks{DocURL: "https://docs.example.com", LinkRewrite: project.LinkRewrite{Style: project.LinkStyleMkDocs}},
+	}
+	result, err := pipeline.TranslateDocument(context.Background(), TranslateDocumentRequest{
+		ProjectID: 1, SourceLanguage: "en", TargetLanguage: "es", Path: "index.md",
+		Content: "Read the [installation guide](/install/) first.\n",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "[es] Read the [installation guide](/es/install/) first.\n", result.Content)
+	require.False(t, result.NeedsReview)
+}
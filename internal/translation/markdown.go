@@ -0,0 +1,551 @@
+package translation
+
+import (
+	"regexp"
+	"strings"
+)
+
+// translatableFrontMatterKeys lists front matter keys whose values are shown to readers
+var translatableFrontMatterKeys = map[string]bool{
+	"title":            true,
+	"description":      true,
+	"sidebar_label":    true,
+	"sidebar_title":    true,
+	"pagination_label": true,
+	"summary":          true,
+	"subtitle":         true,
+	"excerpt":          true,
+	"linkTitle":        true,
+}
+
+var (
+	fencePattern         = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	headingPattern       = regexp.MustCompile(`^( {0,3}#{1,6})([ \t]+)(.*?)([ \t]+#+)?[ \t]*$`)
+	thematicBreakPattern = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,}|=+[ \t]*)$`)
+	refDefinitionPattern = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:[ \t]*\S`)
+	listItemPattern      = regexp.MustCompile(`^[ \t]*(?:[-*+]|\d{1,9}[.)])(?:[ \t]+(?:\[[ xX]\][ \t]+)?|$)`)
+	blockquotePattern    = regexp.MustCompile(`^[ \t]*(?:>[ \t]?)+`)
+	admonitionPattern    = regexp.MustCompile(`^[ \t]*(?::{3,}[\w-]*|(?:!!!|\?\?\?\+?)[ \t]+[\w-]+)[ \t]*`)
+	esmPattern           = regexp.MustCompile(`^(?:import|export)[ \t{*]`)
+	htmlLinePattern      = regexp.MustCompile(`^ {0,3}</?[A-Za-z>]`)
+	rawHTMLBlockPattern  = regexp.MustCompile(`(?i)^ {0,3}<(script|style|pre|textarea)[\s>]`)
+	indentedCodePattern  = regexp.MustCompile(`^(?: {4}|\t)`)
+	tableDelimiterRow    = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	yamlKeyPattern       = regexp.MustCompile(`^([A-Za-z_][\w-]*)([ \t]*:[ \t]*)(.*?)([ \t]*)$`)
+	tomlKeyPattern       = regexp.MustCompile(`^([A-Za-z_][\w-]*)([ \t]*=[ \t]*)(.*?)([ \t]*)$`)
+	autolinkPattern      = regexp.MustCompile(`^<(?:[A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*|[^\s<>@]+@[^\s<>]+)>`)
+	bareURLPattern       = regexp.MustCompile(`^https?://[^\s<>()\[\]]+`)
+)
+
+// line is a line of a file without its terminator
+type line struct {
+	text string
+	eol  string
+}
+
+func splitLines(content string) []line {
+	var lines []line
+	for len(content) > 0 {
+		idx := strings.IndexByte(content, '\n')
+		if idx < 0 {
+			lines = append(lines, line{text: content})
+			break
+		}
+		text := content[:idx]
+		eol := "\n"
+		if strings.HasSuffix(text, "\r") {
+			text = text[:len(text)-1]
+			eol = "\r\n"
+		}
+		lines = append(lines, line{text: text, eol: eol})
+		content = content[idx+1:]
+	}
+	return lines
+}
+
+func isBlank(text string) bool {
+	return strings.TrimSpace(text) == ""
+}
+
+// ParseMarkdown splits a Markdown or MDX document into translatable segments and
+// protected spans: front matter keys, code, HTML/JSX tags, URLs and link targets.
+func ParseMarkdown(content string) *Document {
+	b := newDocBuilder()
+	lines := splitLines(content)
+
+	start := 0
+	if len(lines) > 0 {
+		switch strings.TrimRight(lines[0].text, " \t") {
+		case "---":
+			start = parseFrontMatter(b, lines, "---", yamlKeyPattern)
+		case "+++":
+			start = parseFrontMatter(b, lines, "+++", tomlKeyPattern)
+		}
+	}
+
+	parseMarkdownBlocks(b, lines[start:])
+	return b.doc
+}
+
+// parseFrontMatter handles the front matter block and returns the number of lines consumed
+func parseFrontMatter(b *docBuilder, lines []line, delimiter string, keyPattern *regexp.Regexp) int {
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		text := strings.TrimRight(lines[i].text, " \t")
+		if text == delimiter || (delimiter == "---" && text == "...") {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return 0
+	}
+
+	b.protect(lines[0].text + lines[0].eol)
+	for _, l := range lines[1:end] {
+		match := keyPattern.FindStringSubmatch(l.text)
+		if match == nil || !translatableFrontMatterKeys[match[1]] {
+			b.protect(l.text + l.eol)
+			continue
+		}
+		b.protect(match[1] + match[2])
+		frontMatterValue(b, match[3], "front_matter:"+match[1])
+		b.protect(match[4] + l.eol)
+	}
+	b.protect(lines[end].text + lines[end].eol)
+	return end + 1
+}
+
+// frontMatterValue translates a scalar value, re-quoting the translation when needed
+func frontMatterValue(b *docBuilder, value, context string) {
+	if value == "" {
+		return
+	}
+	switch {
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		b.protect(`"`)
+		b.translateScalar(value[1:len(value)-1], context, unescapeDoubleQuoted, escapeDoubleQuoted)
+		b.protect(`"`)
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		b.protect(`'`)
+		b.translateScalar(value[1:len(value)-1], context,
+			func(s string) string { return strings.ReplaceAll(s, "''", "'") },
+			func(s string) string { return strings.ReplaceAll(s, "'", "''") })
+		b.protect(`'`)
+	case strings.ContainsAny(value[:1], `|>[{&*!%@`+"`"):
+		// Block scalars, flow collections, anchors and tags are left untouched
+		b.protect(value)
+	default:
+		comment := ""
+		if idx := strings.Index(value, " #"); idx >= 0 {
+			value, comment = value[:idx], value[idx:]
+		}
+		b.translateScalar(value, context, func(s string) string { return s }, quotePlainScalar)
+		b.protect(comment)
+	}
+}
+
+func unescapeDoubleQuoted(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\"`, `"`).Replace(s)
+}
+
+func escapeDoubleQuoted(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// quotePlainScalar wraps a translated plain scalar in quotes when it would not parse as one
+func quotePlainScalar(s string) string {
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.ContainsAny(s[:1], `-?:,[]{}#&*!|>'"%@`+"`") {
+		return `"` + escapeDoubleQuoted(s) + `"`
+	}
+	return s
+}
+
+// parseMarkdownBlocks walks the body line by line, protecting code and markup blocks
+func parseMarkdownBlocks(b *docBuilder, lines []line) {
+	prevBlank := true
+	inList := false
+
+	for i := 0; i < len(lines); {
+		l := lines[i]
+		text := l.text
+
+		switch {
+		case isBlank(text):
+			b.protect(text + l.eol)
+			i++
+			prevBlank = true
+			continue
+
+		case fencePattern.MatchString(text):
+			fence := fencePattern.FindStringSubmatch(text)[1]
+			j := i + 1
+			for j < len(lines) && !closesFence(lines[j].text, fence) {
+				j++
+			}
+			if j < len(lines) {
+				j++
+			}
+			protectLines(b, lines[i:j])
+			i = j
+
+		case rawHTMLBlockPattern.MatchString(text):
+			tag := strings.ToLower(rawHTMLBlockPattern.FindStringSubmatch(text)[1])
+			j := i
+			for j < len(lines) && !strings.Contains(strings.ToLower(lines[j].text), "</"+tag+">") {
+				j++
+			}
+			if j < len(lines) {
+				j++
+			}
+			protectLines(b, lines[i:j])
+			i = j
+
+		case strings.HasPrefix(strings.TrimSpace(text), "<!--"):
+			j := i
+			for j < len(lines) && !strings.Contains(lines[j].text, "-->") {
+				j++
+			}
+			if j < len(lines) {
+				j++
+			}
+			protectLines(b, lines[i:j])
+			i = j
+
+		case esmPattern.MatchString(text):
+			j := i + 1
+			for j < len(lines) && !isBlank(lines[j].text) {
+				j++
+			}
+			protectLines(b, lines[i:j])
+			i = j
+
+		case prevBlank && !inList && indentedCodePattern.MatchString(text):
+			j := i + 1
+			for j < len(lines) && (indentedCodePattern.MatchString(lines[j].text) || isBlank(lines[j].text)) {
+				j++
+			}
+			protectLines(b, lines[i:j])
+			i = j
+
+		case thematicBreakPattern.MatchString(text), refDefinitionPattern.MatchString(text):
+			b.protect(text + l.eol)
+			i++
+			inList = false
+
+		case i+1 < len(lines) && strings.Contains(text, "|") && tableDelimiterRow.MatchString(lines[i+1].text) && strings.Contains(lines[i+1].text, "-"):
+			j := i
+			for j < len(lines) && !isBlank(lines[j].text) && strings.Contains(lines[j].text, "|") {
+				if j == i+1 {
+					b.protect(lines[j].text + lines[j].eol)
+				} else {
+					tableRow(b, lines[j])
+				}
+				j++
+			}
+			i = j
+			inList = false
+
+		case headingPattern.MatchString(text):
+			match := headingPattern.FindStringSubmatch(text)
+			b.protect(match[1] + match[2])
+			b.translateTokens(tokenizeInline(match[3]), "heading")
+			b.protect(match[4] + text[len(strings.TrimRight(text, " \t")):] + l.eol)
+			i++
+			inList = false
+
+		case admonitionPattern.MatchString(text):
+			prefix := admonitionPattern.FindString(text)
+			b.protect(prefix)
+			b.translateTokens(tokenizeInline(text[len(prefix):]), "")
+			b.protect(l.eol)
+			i++
+
+		default:
+			i = paragraph(b, lines, i)
+			inList = listItemPattern.MatchString(text) || (inList && indentedCodePattern.MatchString(text))
+		}
+		prevBlank = false
+	}
+}
+
+func protectLines(b *docBuilder, lines []line) {
+	for _, l := range lines {
+		b.protect(l.text + l.eol)
+	}
+}
+
+func closesFence(text, fence string) bool {
+	trimmed := strings.TrimSpace(text)
+	return strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
+
+// startsBlock reports whether a line interrupts the paragraph before it
+func startsBlock(text string) bool {
+	return isBlank(text) ||
+		fencePattern.MatchString(text) ||
+		headingPattern.MatchString(text) ||
+		thematicBreakPattern.MatchString(text) ||
+		refDefinitionPattern.MatchString(text) ||
+		listItemPattern.MatchString(text) ||
+		admonitionPattern.MatchString(text) ||
+		htmlLinePattern.MatchString(text) ||
+		strings.HasPrefix(strings.TrimSpace(text), "<!--") ||
+		esmPattern.MatchString(text)
+}
+
+// paragraph turns a line and its continuation lines into one segment and returns the next line index
+func paragraph(b *docBuilder, lines []line, start int) int {
+	first := lines[start].text
+	prefix := linePrefix(first)
+	quoted := strings.Contains(prefix, ">")
+
+	b.protect(prefix)
+	tokens := tokenizeInline(first[len(prefix):])
+
+	end := start + 1
+	for end < len(lines) {
+		next := lines[end].text
+		nextQuote := blockquotePattern.FindString(next)
+		if nextQuote != "" {
+			if !quoted || listItemPattern.MatchString(next[len(nextQuote):]) || startsBlock(next[len(nextQuote):]) {
+				break
+			}
+		} else if startsBlock(next) || htmlLinePattern.MatchString(first[len(prefix):]) {
+			break
+		}
+
+		continuation := nextQuote
+		if continuation == "" {
+			continuation = next[:len(next)-len(strings.TrimLeft(next, " \t"))]
+		}
+		tokens = append(tokens, inlineToken{text: lines[end-1].eol})
+		tokens = append(tokens, inlineToken{text: continuation, protected: true})
+		tokens = append(tokens, tokenizeInline(next[len(continuation):])...)
+		end++
+	}
+
+	b.translateTokens(tokens, "")
+	b.protect(lines[end-1].eol)
+	return end
+}
+
+// linePrefix returns the blockquote, list marker and indentation at the start of a line
+func linePrefix(text string) string {
+	prefix := blockquotePattern.FindString(text)
+	if item := listItemPattern.FindString(text[len(prefix):]); item != "" {
+		prefix += item
+	}
+	if prefix == "" {
+		prefix = text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+	}
+	return prefix
+}
+
+// tableRow translates each cell of a table row, keeping the pipes protected
+func tableRow(b *docBuilder, l line) {
+	text := l.text
+	cellStart := 0
+	inCode := false
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '`':
+			inCode = !inCode
+		case '|':
+			if inCode {
+				continue
+			}
+			b.translateTokens(tokenizeInline(text[cellStart:i]), "table")
+			b.protect("|")
+			cellStart = i + 1
+		}
+	}
+	b.translateTokens(tokenizeInline(text[cellStart:]), "table")
+	b.protect(l.eol)
+}
+
+// tokenizeInline splits inline Markdown into text and protected spans such as
+// code spans, HTML tags, autolinks, bare URLs, MDX expressions and link targets.
+func tokenizeInline(s string) []inlineToken {
+	var tokens []inlineToken
+	textStart := 0
+
+	flush := func(end int) {
+		if end > textStart {
+			tokens = append(tokens, inlineToken{text: s[textStart:end]})
+		}
+	}
+
+	for i := 0; i < len(s); {
+		var consumed []inlineToken
+		end := -1
+
+		switch s[i] {
+		case '\\':
+			i += 2
+			continue
+		case '`':
+			end = scanCodeSpan(s, i)
+		case '<':
+			if m := autolinkPattern.FindString(s[i:]); m != "" {
+				end = i + len(m)
+			} else if strings.HasPrefix(s[i:], "<!--") {
+				if idx := strings.Index(s[i:], "-->"); idx >= 0 {
+					end = i + idx + 3
+				}
+			} else {
+				end = scanTag(s, i)
+			}
+		case '{':
+			end = scanBalanced(s, i, '{', '}')
+		case '!', '[':
+			consumed, end = scanLink(s, i)
+		case 'h':
+			if m := bareURLPattern.FindString(s[i:]); m != "" && (i == 0 || !isWordByte(s[i-1])) {
+				end = i + len(strings.TrimRight(m, `.,;:!?'"`))
+			}
+		}
+
+		if end < 0 {
+			i++
+			continue
+		}
+		flush(i)
+		if consumed != nil {
+			tokens = append(tokens, consumed...)
+		} else {
+			tokens = append(tokens, inlineToken{text: s[i:end], protected: true})
+		}
+		i = end
+		textStart = end
+	}
+	flush(len(s))
+	return tokens
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// scanCodeSpan returns the end of the code span starting at i, or -1
+func scanCodeSpan(s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == '`' {
+		n++
+	}
+	fence := s[i : i+n]
+	for j := i + n; j < len(s); {
+		idx := strings.Index(s[j:], fence)
+		if idx < 0 {
+			return -1
+		}
+		k := j + idx
+		if k+n >= len(s) || s[k+n] != '`' {
+			return k + n
+		}
+		for k < len(s) && s[k] == '`' {
+			k++
+		}
+		j = k
+	}
+	return -1
+}
+
+// scanTag returns the end of an HTML or JSX tag starting at i, or -1
+func scanTag(s string, i int) int {
+	j := i + 1
+	if j < len(s) && s[j] == '/' {
+		j++
+	}
+	if j < len(s) && s[j] == '>' {
+		return j + 1
+	}
+	if j >= len(s) || !(s[j] >= 'a' && s[j] <= 'z' || s[j] >= 'A' && s[j] <= 'Z') {
+		return -1
+	}
+
+	var quote byte
+	depth := 0
+	for ; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case c == '>' && depth <= 0:
+			return j + 1
+		case c == '<' && depth <= 0:
+			return -1
+		}
+	}
+	return -1
+}
+
+// scanBalanced returns the end of a bracketed run starting at i, or -1
+func scanBalanced(s string, i int, open, close byte) int {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return -1
+}
+
+// scanLink tokenizes links, images, reference links and footnotes starting at i.
+// The link text stays translatable while the brackets and destination are protected.
+func scanLink(s string, i int) ([]inlineToken, int) {
+	open := i
+	if s[i] == '!' {
+		if i+1 >= len(s) || s[i+1] != '[' {
+			return nil, -1
+		}
+		open = i + 1
+	}
+	if strings.HasPrefix(s[open:], "[^") {
+		if end := scanBalanced(s, open, '[', ']'); end > 0 {
+			return nil, end
+		}
+		return nil, -1
+	}
+
+	closeBracket := scanBalanced(s, open, '[', ']')
+	if closeBracket < 0 || closeBracket >= len(s) {
+		return nil, -1
+	}
+
+	var end int
+	switch s[closeBracket] {
+	case '(':
+		end = scanBalanced(s, closeBracket, '(', ')')
+	case '[':
+		end = scanBalanced(s, closeBracket, '[', ']')
+	default:
+		return nil, -1
+	}
+	if end < 0 {
+		return nil, -1
+	}
+
+	tokens := []inlineToken{{text: s[i : open+1], protected: true}}
+	tokens = append(tokens, tokenizeInline(s[open+1:closeBracket-1])...)
+	tokens = append(tokens, inlineToken{text: s[closeBracket-1 : end], protected: true})
+	return tokens, end
+}
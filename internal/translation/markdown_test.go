@@ -0,0 +1,129 @@
+package translation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const sampleMarkdown = `---
+title: Getting started
+description: "Install the \"xeo\" CLI"
+slug: /getting-started
+sidebar_position: 2
+---
+
+import Tabs from '@theme/Tabs';
+
+# Install the CLI {#install}
+
+Run ` + "`npm install xeo`" + ` to install the tool.
+See [the guide](https://example.com/guide "Guide") or <https://example.com>.
+![Architecture diagram](./img/arch.png)
+
+- First item with **bold** text
+- [x] Done item
+  continued on the next line
+
+> A quoted note
+> spanning two lines
+
+| Option | Description |
+|--------|-------------|
+| ` + "`--force`" + ` | Overwrite files |
+
+` + "```bash" + `
+echo "do not translate"
+` + "```" + `
+
+<Tabs groupId="os">
+<TabItem value="mac" label="macOS">
+
+Use Homebrew on macOS.
+
+</TabItem>
+</Tabs>
+
+<!-- a comment
+that spans lines -->
+
+:::tip Pro tip
+Keep it short.
+:::
+
+    indented code block
+
+[guide]: https://example.com/guide
+`
+
+func TestParseMarkdownRoundTrip(t *testing.T) {
+	doc := ParseMarkdown(sampleMarkdown)
+	require.Equal(t, sampleMarkdown, doc.Render(nil))
+	require.Equal(t, sampleMarkdown, doc.Render(doc.Texts()))
+}
+
+func TestParseMarkdownSegments(t *testing.T) {
+	doc := ParseMarkdown(sampleMarkdown)
+
+	require.Equal(t, []string{
+		"Getting started",
+		`Install the "xeo" CLI`,
+		"Install the CLI",
+		`Run <ph id="0"/> to install the tool.` + "\n" + `See <ph id="1"/>the guide<ph id="2"/> or <ph id="3"/>.` + "\n" + `<ph id="4"/>Architecture diagram`,
+		"First item with **bold** text",
+		"Done item\n<ph id=\"0\"/>continued on the next line",
+		"A quoted note\n<ph id=\"0\"/>spanning two lines",
+		"Option",
+		"Description",
+		"Overwrite files",
+		"Use Homebrew on macOS.",
+		"Pro tip",
+		"Keep it short.",
+	}, doc.Texts())
+
+	require.Equal(t, "front_matter:title", doc.Segments[0].Context)
+	require.Equal(t, "heading", doc.Segments[2].Context)
+	require.Equal(t, []string{"`npm install xeo`", "[", `](https://example.com/guide "Guide")`, "<https://example.com>", "!["}, doc.Segments[3].Placeholders)
+}
+
+func TestTranslateDocumentOnlyChangesSegments(t *testing.T) {
+	pipeline := &Pipeline{Translator: NewStubProvider()}
+	result, err := pipeline.TranslateDocument(context.Background(), TranslateDocumentRequest{
+		SourceLanguage: "en",
+		TargetLanguage: "es",
+		Path:           "docs/intro.mdx",
+		Content:        sampleMarkdown,
+	})
+	require.NoError(t, err)
+	require.Empty(t, result.Warnings)
+
+	content := result.Content
+	require.Contains(t, content, "title: \"[es] Getting started\"\n")
+	require.Contains(t, content, `description: "[es] Install the \"xeo\" CLI"`)
+	require.Contains(t, content, "slug: /getting-started\n")
+	require.Contains(t, content, "# [es] Install the CLI {#install}\n")
+	require.Contains(t, content, "[es] Run `npm install xeo` to install the tool.")
+	require.Contains(t, content, "echo \"do not translate\"\n")
+	require.Contains(t, content, "    indented code block\n")
+	require.Contains(t, content, "| `--force` | [es] Overwrite files |\n")
+	require.Contains(t, content, "![Architecture diagram](./img/arch.png)")
+	require.Contains(t, content, "<TabItem value=\"mac\" label=\"macOS\">\n")
+
+	// Removing the translated text must give back the original file byte for byte
+	require.Equal(t, sampleMarkdown, strings.ReplaceAll(strings.ReplaceAll(content, "[es] ", ""), `"Getting started"`, "Getting started"))
+}
+
+func TestTranslateDocumentKeepsSourceOnBrokenPlaceholders(t *testing.T) {
+	doc := ParseMarkdown("Run `make` now.\n")
+	require.Equal(t, []string{`Run <ph id="0"/> now.`}, doc.Texts())
+	require.Error(t, ValidatePlaceholders(doc.Segments[0], "Ejecuta ahora."))
+	require.NoError(t, ValidatePlaceholders(doc.Segments[0], `Ejecuta <ph id="0"/> ahora.`))
+	require.Equal(t, "Ejecuta `make` ahora.\n", doc.Render([]string{`Ejecuta <ph id="0"/> ahora.`}))
+}
+
+func TestParseDocumentRejectsUnknownFormats(t *testing.T) {
+	_, err := ParseDocument("logo.png", "")
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+}
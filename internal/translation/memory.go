@@ -0,0 +1,177 @@
+package translation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+)
+
+// Translation memory origins. Human entries are never overwritten by machine output.
+const (
+	OriginMachine = "machine"
+	OriginHuman   = "human"
+)
+
+// MemoryEntry is a stored source/target segment pair
+type MemoryEntry struct {
+	SourceHash string  `json:"sourceHash"`
+	SourceText string  `json:"sourceText"`
+	TargetText string  `json:"targetText"`
+	Origin     string  `json:"origin"`
+	Score      float64 `json:"score,omitempty"`
+}
+
+// Memory looks up and stores previously translated segments
+type Memory interface {
+	Exact(projectID int, language string, hashes []string) (map[string]MemoryEntry, error)
+	Fuzzy(projectID int, language, text string, threshold float64, limit int) ([]MemoryEntry, error)
+	Store(projectID int, language string, entries []MemoryEntry) error
+	Forget(projectID int, language string, hashes []string) error
+}
+
+// NormalizeSegment collapses whitespace so reflowed text still matches
+func NormalizeSegment(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// SegmentHash is the translation memory key of a segment
+func SegmentHash(text string) string {
+	sum := sha256.Sum256([]byte(NormalizeSegment(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// PostgresMemory stores translation memory in the translation_memory table
+type PostgresMemory struct{}
+
+// NewPostgresMemory creates a translation memory backed by the shared database
+func NewPostgresMemory() *PostgresMemory {
+	return &PostgresMemory{}
+}
+
+// Exact returns stored entries keyed by source hash
+func (m *PostgresMemory) Exact(projectID int, language string, hashes []string) (map[string]MemoryEntry, error) {
+	entries := make(map[string]MemoryEntry)
+	if len(hashes) == 0 {
+		return entries, nil
+	}
+
+	query := `SELECT source_hash, source_text, target_text, origin FROM translation_memory WHERE project_id = $1 AND language = $2 AND source_hash = ANY($3)`
+	rows, err := db.DB.Query(query, projectID, language, pq.Array(hashes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry MemoryEntry
+		if err := rows.Scan(&entry.SourceHash, &entry.SourceText, &entry.TargetText, &entry.Origin); err != nil {
+			return nil, err
+		}
+		entry.Score = 1
+		entries[entry.SourceHash] = entry
+	}
+	return entries, rows.Err()
+}
+
+// Fuzzy returns the closest stored segments with a trigram similarity of at least threshold
+func (m *PostgresMemory) Fuzzy(projectID int, language, text string, threshold float64, limit int) ([]MemoryEntry, error) {
+	query := `SELECT source_hash, source_text, target_text, origin, similarity(source_text, $3) AS score
+		FROM translation_memory
+		WHERE project_id = $1 AND language = $2 AND source_text % $3 AND similarity(source_text, $3) >= $4
+		ORDER BY score DESC LIMIT $5`
+	rows, err := db.DB.Query(query, projectID, language, text, threshold, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []MemoryEntry
+	for rows.Next() {
+		var entry MemoryEntry
+		if err := rows.Scan(&entry.SourceHash, &entry.SourceText, &entry.TargetText, &entry.Origin, &entry.Score); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Store upserts entries. Machine translations never replace human ones.
+func (m *PostgresMemory) Store(projectID int, language string, entries []MemoryEntry) error {
+	query := `INSERT INTO translation_memory (project_id, language, source_hash, source_text, target_text, origin, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (project_id, language, source_hash) DO UPDATE
+		SET source_text = EXCLUDED.source_text, target_text = EXCLUDED.target_text, origin = EXCLUDED.origin, updated_at = EXCLUDED.updated_at
+		WHERE translation_memory.origin = $8 OR EXCLUDED.origin = $9`
+	now := time.Now()
+	for _, entry := range entries {
+		if _, err := db.DB.Exec(query, projectID, language, entry.SourceHash, entry.SourceText, entry.TargetText, entry.Origin, now, OriginMachine, OriginHuman); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Forget removes machine translations of the given segments. Human entries are kept.
+func (m *PostgresMemory) Forget(projectID int, language string, hashes []string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	query := `DELETE FROM translation_memory WHERE project_id = $1 AND language = $2 AND source_hash = ANY($3) AND origin = $4`
+	_, err := db.DB.Exec(query, projectID, language, pq.Array(hashes), OriginMachine)
+	return err
+}
+
+// MemoryStats counts how segments of a document were resolved
+type MemoryStats struct {
+	Segments  int `json:"segments"`
+	ExactHits int `json:"exactHits"`
+	FuzzyHits int `json:"fuzzyHits"`
+	Misses    int `json:"misses"`
+}
+
+// TranslationJob aggregates the translation memory hit counts of one translate_files task
+type TranslationJob struct {
+	ID          string    `json:"id"`
+	ProjectID   int       `json:"projectId"`
+	Language    string    `json:"language"`
+	Files       int       `json:"files"`
+	Segments    int       `json:"segments"`
+	TMExactHits int       `json:"tmExactHits"`
+	TMFuzzyHits int       `json:"tmFuzzyHits"`
+	HitRate     float64   `json:"hitRate"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// RecordTranslationJob adds one translated file to the job's counters
+func RecordTranslationJob(jobID string, projectID int, language string, stats MemoryStats) error {
+	query := `INSERT INTO translation_jobs (id, project_id, language, files, segments, tm_exact_hits, tm_fuzzy_hits, created_at, updated_at)
+		VALUES ($1, $2, $3, 1, $4, $5, $6, $7, $7)
+		ON CONFLICT (id) DO UPDATE SET
+			files = translation_jobs.files + 1,
+			segments = translation_jobs.segments + EXCLUDED.segments,
+			tm_exact_hits = translation_jobs.tm_exact_hits + EXCLUDED.tm_exact_hits,
+			tm_fuzzy_hits = translation_jobs.tm_fuzzy_hits + EXCLUDED.tm_fuzzy_hits,
+			updated_at = EXCLUDED.updated_at`
+	_, err := db.DB.Exec(query, jobID, projectID, language, stats.Segments, stats.ExactHits, stats.FuzzyHits, time.Now())
+	return err
+}
+
+// GetTranslationJob returns a job with its translation memory hit rate
+func GetTranslationJob(jobID string) (*TranslationJob, error) {
+	job := &TranslationJob{}
+	query := `SELECT id, project_id, language, files, segments, tm_exact_hits, tm_fuzzy_hits, created_at, updated_at FROM translation_jobs WHERE id = $1`
+	err := db.DB.QueryRow(query, jobID).Scan(&job.ID, &job.ProjectID, &job.Language, &job.Files, &job.Segments, &job.TMExactHits, &job.TMFuzzyHits, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if job.Segments > 0 {
+		job.HitRate = float64(job.TMExactHits) / float64(job.Segments)
+	}
+	return job, nil
+}
@@ -0,0 +1,106 @@
+package translation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMemory keeps entries in a map and treats a shared prefix as a fuzzy match
+type fakeMemory struct {
+	entries map[string]MemoryEntry
+}
+
+func newFakeMemory() *fakeMemory {
+	return &fakeMemory{entries: make(map[string]MemoryEntry)}
+}
+
+func (m *fakeMemory) Exact(projectID int, language string, hashes []string) (map[string]MemoryEntry, error) {
+	hits := make(map[string]MemoryEntry)
+	for _, hash := range hashes {
+		if entry, ok := m.entries[language+hash]; ok {
+			hits[hash] = entry
+		}
+	}
+	return hits, nil
+}
+
+func (m *fakeMemory) Fuzzy(projectID int, language, text string, threshold float64, limit int) ([]MemoryEntry, error) {
+	var matches []MemoryEntry
+	for _, entry := range m.entries {
+		if len(matches) < limit && strings.HasPrefix(text, strings.Fields(entry.SourceText)[0]) {
+			matches = append(matches, entry)
+		}
+	}
+	return matches, nil
+}
+
+func (m *fakeMemory) Store(projectID int, language string, entries []MemoryEntry) error {
+	for _, entry := range entries {
+		if existing, ok := m.entries[language+entry.SourceHash]; ok && existing.Origin == OriginHuman && entry.Origin != OriginHuman {
+			continue
+		}
+		m.entries[language+entry.SourceHash] = entry
+	}
+	return nil
+}
+
+func (m *fakeMemory) Forget(projectID int, language string, hashes []string) error {
+	for _, hash := range hashes {
+		if m.entries[language+hash].Origin == OriginMachine {
+			delete(m.entries, language+hash)
+		}
+	}
+	return nil
+}
+
+// recordingTranslator wraps the stub provider and remembers the requests it received
+type recordingTranslator struct {
+	StubProvider
+	requests []TranslateRequest
+}
+
+func (t *recordingTranslator) Translate(ctx context.Context, req TranslateRequest) (*TranslateResult, error) {
+	t.requests = append(t.requests, req)
+	return t.StubProvider.Translate(ctx, req)
+}
+
+func TestSegmentHashIgnoresWhitespace(t *testing.T) {
+	require.Equal(t, SegmentHash("Install the\n  CLI"), SegmentHash("Install the CLI"))
+	require.NotEqual(t, SegmentHash("Install the CLI"), SegmentHash("Install the API"))
+}
+
+func TestPipelineReusesTranslationMemory(t *testing.T) {
+	memory := newFakeMemory()
+	translator := &recordingTranslator{}
+	pipeline := &Pipeline{Translator: translator, Memory: memory, FuzzyThreshold: 0.5}
+	req := TranslateDocumentRequest{ProjectID: 1, SourceLanguage: "en", TargetLanguage: "es", Path: "a.md"}
+
+	req.Content = "# Install the CLI\n\nRun it.\n"
+	first, err := pipeline.TranslateDocument(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, MemoryStats{Segments: 2, Misses: 2}, first.Memory)
+	require.Len(t, memory.entries, 2)
+
+	// A human correction must survive later machine translations of the same text
+	require.NoError(t, memory.Store(1, "es", []MemoryEntry{{SourceHash: SegmentHash("Run it."), SourceText: "Run it.", TargetText: "Ejecútalo.", Origin: OriginHuman}}))
+
+	req.Content = "# Install the CLI\n\nRun it.\n\nRun it again.\n"
+	second, err := pipeline.TranslateDocument(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, MemoryStats{Segments: 3, ExactHits: 2, FuzzyHits: 1, Misses: 1}, second.Memory)
+	require.Equal(t, "# [es] Install the CLI\n\nEjecútalo.\n\n[es] Run it again.\n", second.Content)
+
+	var origins []string
+	for _, segment := range second.Translations {
+		origins = append(origins, segment.Origin)
+	}
+	require.Equal(t, []string{OriginMachine, OriginHuman, OriginMachine}, origins)
+
+	last := translator.requests[len(translator.requests)-1]
+	require.Equal(t, []string{"Run it again."}, last.Segments)
+	require.Equal(t, []Reference{{Source: "Run it.", Target: "Ejecútalo."}}, last.References)
+	require.Equal(t, OriginHuman, memory.entries["es"+SegmentHash("Run it.")].Origin)
+}
@@ -0,0 +1,125 @@
+package translation
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	printfPattern      = regexp.MustCompile(`^%(?:\d+\$)?[-+ 0#]*\d*(?:\.\d+)?[sdifuxXeEgGcp@]|^%\([A-Za-z_]\w*\)[sdf]`)
+	numberedTagPattern = regexp.MustCompile(`^</?\d+/?>`)
+)
+
+// icuSelectorTypes are the ICU argument types whose branches contain translatable messages
+var icuSelectorTypes = map[string]bool{
+	"plural":        true,
+	"select":        true,
+	"selectordinal": true,
+}
+
+// tokenizeMessage splits a UI string from an i18n file into text and protected spans:
+// ICU arguments such as {count}, the syntax of plural and select arguments (their
+// branch messages stay translatable), {{template}} expressions, printf verbs,
+// HTML or numbered tags and URLs. Inside plural branches "#" is protected too.
+func tokenizeMessage(s string, plural bool) []inlineToken {
+	var tokens []inlineToken
+	textStart := 0
+
+	flush := func(end int) {
+		if end > textStart {
+			tokens = append(tokens, inlineToken{text: s[textStart:end]})
+		}
+	}
+
+	for i := 0; i < len(s); {
+		var consumed []inlineToken
+		end := -1
+
+		switch s[i] {
+		case '{':
+			if strings.HasPrefix(s[i:], "{{") {
+				if idx := strings.Index(s[i+2:], "}}"); idx >= 0 {
+					end = i + 2 + idx + 2
+				}
+			} else {
+				consumed, end = scanICUArgument(s, i)
+			}
+		case '%':
+			if m := printfPattern.FindString(s[i:]); m != "" {
+				end = i + len(m)
+			}
+		case '<':
+			if m := numberedTagPattern.FindString(s[i:]); m != "" {
+				end = i + len(m)
+			} else {
+				end = scanTag(s, i)
+			}
+		case '#':
+			if plural {
+				end = i + 1
+			}
+		case '$':
+			// i18next nesting: $t(key)
+			if strings.HasPrefix(s[i:], "$t(") {
+				end = scanBalanced(s, i+2, '(', ')')
+			}
+		case 'h':
+			if m := bareURLPattern.FindString(s[i:]); m != "" && (i == 0 || !isWordByte(s[i-1])) {
+				end = i + len(strings.TrimRight(m, `.,;:!?'"`))
+			}
+		}
+
+		if end < 0 {
+			i++
+			continue
+		}
+		flush(i)
+		if consumed != nil {
+			tokens = append(tokens, consumed...)
+		} else {
+			tokens = append(tokens, inlineToken{text: s[i:end], protected: true})
+		}
+		i = end
+		textStart = end
+	}
+	flush(len(s))
+	return tokens
+}
+
+// scanICUArgument tokenizes the ICU argument starting at s[i] == '{' and returns
+// its tokens and end. Simple arguments are protected as a whole; plural and select
+// arguments expose the message of each branch. It returns -1 for unbalanced braces.
+func scanICUArgument(s string, i int) ([]inlineToken, int) {
+	end := scanBalanced(s, i, '{', '}')
+	if end < 0 {
+		return nil, -1
+	}
+
+	parts := strings.SplitN(s[i+1:end-1], ",", 3)
+	if len(parts) < 3 || !icuSelectorTypes[strings.TrimSpace(parts[1])] {
+		return []inlineToken{{text: s[i:end], protected: true}}, end
+	}
+	plural := strings.TrimSpace(parts[1]) != "select"
+
+	// Skip "{name, type," and walk the "selector {message}" branches
+	var tokens []inlineToken
+	syntaxStart := i
+	j := i + 1 + len(parts[0]) + 1 + len(parts[1]) + 1
+	for j < end-1 {
+		open := strings.IndexByte(s[j:end-1], '{')
+		if open < 0 {
+			break
+		}
+		open += j
+		close := scanBalanced(s, open, '{', '}')
+		if close < 0 || close > end-1 {
+			return []inlineToken{{text: s[i:end], protected: true}}, end
+		}
+		tokens = append(tokens, inlineToken{text: s[syntaxStart : open+1], protected: true})
+		tokens = append(tokens, tokenizeMessage(s[open+1:close-1], plural)...)
+		syntaxStart = close - 1
+		j = close
+	}
+	tokens = append(tokens, inlineToken{text: s[syntaxStart:end], protected: true})
+	return tokens, end
+}
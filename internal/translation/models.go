@@ -0,0 +1,67 @@
+package translation
+
+// TranslateSegmentsRequest is the body of POST /internal/translate
+type TranslateSegmentsRequest struct {
+	ProjectID      int      `json:"projectId"`
+	SourceLanguage string   `json:"sourceLanguage"`
+	TargetLanguage string   `json:"targetLanguage"`
+	Segments       []string `json:"segments"`
+}
+
+// TranslateSegmentsResponse is returned by POST /internal/translate
+type TranslateSegmentsResponse struct {
+	Translations []string `json:"translations"`
+	Provider     string   `json:"provider"`
+	Usage        Usage    `json:"usage"`
+}
+
+// TranslateDocumentRequest is the body of POST /internal/translate-document
+type TranslateDocumentRequest struct {
+	ProjectID      int    `json:"projectId"`
+	JobID          string `json:"jobId,omitempty"`
+	SourceLanguage string `json:"sourceLanguage"`
+	TargetLanguage string `json:"targetLanguage"`
+	Path           string `json:"path"`
+	Content        string `json:"content"`
+}
+
+// TranslateDocumentResponse is returned by POST /internal/translate-document
+type TranslateDocumentResponse struct {
+	Content            string               `json:"content"`
+	Segments           int                  `json:"segments"`
+	Warnings           []string             `json:"warnings,omitempty"`
+	Provider           string               `json:"provider"`
+	Usage              Usage                `json:"usage"`
+	Requests           int                  `json:"requests"`
+	Cost               float64              `json:"cost"`
+	Memory             MemoryStats          `json:"memory"`
+	GlossaryViolations []GlossaryViolation  `json:"glossaryViolations,omitempty"`
+	Translations       []SegmentTranslation `json:"translations,omitempty"`
+	QAFindings         []QAFinding          `json:"qaFindings,omitempty"`
+	// NeedsReview is set when quality checks failed and the file must not be published
+	NeedsReview bool `json:"needsReview"`
+}
+
+// SegmentTranslation is the source and translation of one segment, as shown side-by-side to reviewers.
+// Translation is empty when the segment kept its source text.
+type SegmentTranslation struct {
+	Index        int      `json:"index"`
+	Context      string   `json:"context,omitempty"`
+	SourceHash   string   `json:"source_hash"`
+	Source       string   `json:"source"`
+	Translation  string   `json:"translation"`
+	Origin       string   `json:"origin,omitempty"`
+	Placeholders []string `json:"placeholders,omitempty"`
+	// StaleSince is the source commit that changed the segment after its file was reviewed
+	StaleSince     string `json:"stale_since,omitempty"`
+	PreviousSource string `json:"previous_source,omitempty"`
+}
+
+// CommitFailedRequest is the body of POST /internal/commit-failed, sent by the
+// worker when translated files could not be committed to their language branch
+type CommitFailedRequest struct {
+	ProjectID int      `json:"projectId"`
+	Language  string   `json:"language"`
+	Files     []string `json:"files"`
+	Error     string   `json:"error"`
+}
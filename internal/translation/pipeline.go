@@ -0,0 +1,412 @@
+package translation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+)
+
+// fuzzyMatchLimit caps the number of reference translations fetched per segment
+const fuzzyMatchLimit = 2
+
+// Pipeline translates documents with a provider, reusing translation memory and
+// enforcing the project glossary when available
+type Pipeline struct {
+	Translator     Translator
+	Memory         Memory
+	Glossary       Glossary
+	Prompts        Prompts
+	Links          Links
+	Files          Files
+	FuzzyThreshold float64
+	// TokenBudget is the estimated size of each provider request; DefaultTokenBudget when zero
+	TokenBudget int
+}
+
+// preparedDocument is a parsed document with the translations found in memory
+// and what is needed to translate the remaining segments
+type preparedDocument struct {
+	doc          *Document
+	hashes       []string
+	terms        []project.GlossaryTerm
+	translations []string
+	origins      []string
+	missing      []int
+	references   map[int][]Reference
+	memory       MemoryStats
+}
+
+// plannedRequest is one provider request and the units it translates
+type plannedRequest struct {
+	units   []batchUnit
+	request TranslateRequest
+}
+
+// TranslateDocument parses a file, translates its segments and renders the result.
+// Exact translation memory hits skip the provider, fuzzy hits are passed as references,
+// and segments whose translation breaks a placeholder keep their source text.
+// Machine translations that miss a glossary term are flagged and not stored in memory.
+// The result goes through the quality checks, and NeedsReview is set when any fails.
+// When translating fails after the provider was called, the response is returned
+// with the error and carries the usage and cost of the requests made so far.
+func (p *Pipeline) TranslateDocument(ctx context.Context, req TranslateDocumentRequest) (*TranslateDocumentResponse, error) {
+	prep, err := p.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+	doc, translations, origins := prep.doc, prep.translations, prep.origins
+
+	response := &TranslateDocumentResponse{
+		Segments: len(doc.Segments),
+		Provider: p.Translator.Name(),
+		Memory:   prep.memory,
+	}
+	if len(doc.Segments) == 0 {
+		response.Content = req.Content
+		return response, nil
+	}
+
+	var untranslated []int
+	if len(prep.missing) > 0 {
+		results, err := p.translateSegments(ctx, req, prep, response)
+		if err != nil {
+			return response, err
+		}
+
+		var learned []MemoryEntry
+		for _, i := range prep.missing {
+			segment := doc.Segments[i]
+			if err := ValidatePlaceholders(segment, results[i]); err != nil {
+				response.Warnings = append(response.Warnings, fmt.Sprintf("segment %d kept in source language: %v", i, err))
+				untranslated = append(untranslated, i)
+				continue
+			}
+			translations[i] = results[i]
+			origins[i] = OriginMachine
+
+			if violations := CheckGlossary(i, segment.Text, results[i], prep.terms); len(violations) > 0 {
+				for _, v := range violations {
+					response.Warnings = append(response.Warnings, fmt.Sprintf("segment %d does not use glossary term %q as %q", i, v.Term, v.Expected))
+				}
+				response.GlossaryViolations = append(response.GlossaryViolations, violations...)
+				continue
+			}
+			learned = append(learned, MemoryEntry{
+				SourceHash: prep.hashes[i],
+				SourceText: segment.Text,
+				TargetText: results[i],
+				Origin:     OriginMachine,
+			})
+		}
+
+		if p.useMemory(req) && len(learned) > 0 {
+			if err := p.Memory.Store(req.ProjectID, req.TargetLanguage, learned); err != nil {
+				return response, fmt.Errorf("failed to store translation memory: %w", err)
+			}
+		}
+	}
+
+	response.Translations = make([]SegmentTranslation, len(doc.Segments))
+	for i, segment := range doc.Segments {
+		response.Translations[i] = SegmentTranslation{
+			Index:        segment.Index,
+			Context:      segment.Context,
+			SourceHash:   prep.hashes[i],
+			Source:       segment.Text,
+			Translation:  translations[i],
+			Origin:       origins[i],
+			Placeholders: segment.Placeholders,
+		}
+	}
+
+	response.Content = doc.Render(translations)
+	response.QAFindings = CheckQuality(QAInput{
+		Path:           req.Path,
+		TargetLanguage: req.TargetLanguage,
+		Source:         req.Content,
+		Target:         response.Content,
+		Document:       doc,
+		Translations:   translations,
+		Untranslated:   untranslated,
+	})
+	response.NeedsReview = !QAPassed(response.QAFindings)
+
+	// Links are localized after the checks, which compare link targets with the source
+	if p.Links != nil && req.ProjectID != 0 {
+		rules, ok, err := p.Links.Rules(req.ProjectID, req.TargetLanguage)
+		if err != nil {
+			return response, fmt.Errorf("failed to load link rules: %w", err)
+		}
+		if ok {
+			response.Content = RewriteLinks(req.Path, response.Content, rules)
+		}
+	}
+	return response, nil
+}
+
+// PreviewPrompts renders the provider requests that translating the document would
+// send, without calling the provider or changing translation memory
+func (p *Pipeline) PreviewPrompts(req TranslateDocumentRequest) (*PromptPreview, error) {
+	prep, err := p.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &PromptPreview{
+		Path:           req.Path,
+		SourceLanguage: req.SourceLanguage,
+		TargetLanguage: req.TargetLanguage,
+		Segments:       len(prep.doc.Segments),
+		ExactHits:      prep.memory.ExactHits,
+		FuzzyHits:      prep.memory.FuzzyHits,
+		Requests:       []PromptRequestPreview{},
+	}
+	if len(prep.missing) == 0 {
+		return preview, nil
+	}
+
+	planned, _, err := p.planRequests(req, prep)
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range planned {
+		system := systemPrompt(pr.request)
+		user, err := segmentsPayload(pr.request.Segments)
+		if err != nil {
+			return nil, err
+		}
+		preview.Requests = append(preview.Requests, PromptRequestPreview{
+			System:          system,
+			User:            user,
+			EstimatedTokens: estimateTokens(system) + 2*estimateTokens(user),
+		})
+	}
+	return preview, nil
+}
+
+func (p *Pipeline) useMemory(req TranslateDocumentRequest) bool {
+	return p.Memory != nil && req.ProjectID != 0
+}
+
+// prepare parses the document, resolves exact translation memory hits and collects
+// glossary terms and fuzzy references for the segments left to translate
+func (p *Pipeline) prepare(req TranslateDocumentRequest) (*preparedDocument, error) {
+	if p.Files != nil && req.ProjectID != 0 {
+		rules, err := p.Files.Rules(req.ProjectID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get file rules: %w", err)
+		}
+		if pattern, ok := rules.Match(req.Path); !ok {
+			if pattern != "" {
+				return nil, fmt.Errorf("%w: %s matches %q", ErrExcludedFile, req.Path, pattern)
+			}
+			return nil, fmt.Errorf("%w: %s matches no include pattern", ErrExcludedFile, req.Path)
+		}
+	}
+
+	doc, err := ParseDocument(req.Path, req.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	prep := &preparedDocument{
+		doc:          doc,
+		hashes:       make([]string, len(doc.Segments)),
+		translations: make([]string, len(doc.Segments)),
+		origins:      make([]string, len(doc.Segments)),
+		references:   make(map[int][]Reference),
+		memory:       MemoryStats{Segments: len(doc.Segments)},
+	}
+	if len(doc.Segments) == 0 {
+		return prep, nil
+	}
+	useMemory := p.useMemory(req)
+
+	for i, segment := range doc.Segments {
+		prep.hashes[i] = SegmentHash(segment.Text)
+	}
+
+	if p.Glossary != nil && req.ProjectID != 0 {
+		all, err := p.Glossary.Terms(req.ProjectID, req.TargetLanguage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load glossary: %w", err)
+		}
+		prep.terms = termsInSegments(all, doc.Texts())
+	}
+
+	if useMemory {
+		hits, err := p.Memory.Exact(req.ProjectID, req.TargetLanguage, prep.hashes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up translation memory: %w", err)
+		}
+		for i, segment := range doc.Segments {
+			hit, ok := hits[prep.hashes[i]]
+			if !ok || ValidatePlaceholders(segment, hit.TargetText) != nil {
+				continue
+			}
+			// Machine translations stored before a glossary change are translated again
+			if hit.Origin != OriginHuman && len(CheckGlossary(i, segment.Text, hit.TargetText, prep.terms)) > 0 {
+				continue
+			}
+			prep.translations[i] = hit.TargetText
+			prep.origins[i] = hit.Origin
+			prep.memory.ExactHits++
+		}
+	}
+
+	for i, segment := range doc.Segments {
+		if prep.translations[i] != "" {
+			continue
+		}
+		prep.missing = append(prep.missing, i)
+
+		if !useMemory {
+			continue
+		}
+		matches, err := p.Memory.Fuzzy(req.ProjectID, req.TargetLanguage, segment.Text, p.FuzzyThreshold, fuzzyMatchLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up translation memory: %w", err)
+		}
+		if len(matches) > 0 {
+			prep.memory.FuzzyHits++
+		}
+		for _, match := range matches {
+			prep.references[i] = append(prep.references[i], Reference{Source: match.SourceText, Target: match.TargetText})
+		}
+	}
+	prep.memory.Misses = len(prep.missing)
+	return prep, nil
+}
+
+// planRequests groups the missing segments into provider requests sized to the token
+// budget, each with its prompt rendered from the project's template. Segments too
+// large for one request are split at safe boundaries; the returned separators
+// join their translated parts again.
+func (p *Pipeline) planRequests(req TranslateDocumentRequest, prep *preparedDocument) ([]plannedRequest, map[int][]string, error) {
+	budget := p.TokenBudget
+	if budget <= 0 {
+		budget = DefaultTokenBudget
+	}
+
+	tmpl := project.DefaultPromptTemplate
+	if p.Prompts != nil && req.ProjectID != 0 {
+		var err error
+		if tmpl, err = p.Prompts.Template(req.ProjectID, req.TargetLanguage); err != nil {
+			return nil, nil, fmt.Errorf("failed to load prompt template: %w", err)
+		}
+	}
+
+	var texts []string
+	for _, i := range prep.missing {
+		texts = append(texts, prep.doc.Segments[i].Text)
+	}
+	full, err := project.RenderPrompt(tmpl, promptVariables(TranslateRequest{
+		SourceLanguage: req.SourceLanguage,
+		TargetLanguage: req.TargetLanguage,
+		Glossary:       termsInSegments(prep.terms, texts),
+	}, req.Path, promptContext(prep.doc, prep.missing)))
+	if err != nil {
+		return nil, nil, err
+	}
+	overhead := estimateTokens(full + outputInstructions)
+	maxSegment := (budget - overhead) / 2
+	if maxSegment < minSegmentTokens {
+		maxSegment = minSegmentTokens
+	}
+
+	var units []batchUnit
+	separators := make(map[int][]string)
+	for _, i := range prep.missing {
+		pieces, seps := splitText(prep.doc.Segments[i].Text, maxSegment)
+		separators[i] = seps
+		for k, piece := range pieces {
+			unit := batchUnit{segment: i, text: piece}
+			if k == 0 {
+				unit.references = prep.references[i]
+			}
+			units = append(units, unit)
+		}
+	}
+
+	var planned []plannedRequest
+	for _, batch := range planBatches(units, budget, overhead) {
+		batchTexts := make([]string, len(batch))
+		var batchSegments []int
+		var batchReferences []Reference
+		seen := make(map[Reference]bool)
+		for j, unit := range batch {
+			batchTexts[j] = unit.text
+			if len(batchSegments) == 0 || batchSegments[len(batchSegments)-1] != unit.segment {
+				batchSegments = append(batchSegments, unit.segment)
+			}
+			for _, ref := range unit.references {
+				if !seen[ref] {
+					seen[ref] = true
+					batchReferences = append(batchReferences, ref)
+				}
+			}
+		}
+
+		request := TranslateRequest{
+			SourceLanguage: req.SourceLanguage,
+			TargetLanguage: req.TargetLanguage,
+			Segments:       batchTexts,
+			References:     batchReferences,
+			Glossary:       termsInSegments(prep.terms, batchTexts),
+		}
+		request.Instructions, err = project.RenderPrompt(tmpl, promptVariables(request, req.Path, promptContext(prep.doc, batchSegments)))
+		if err != nil {
+			return nil, nil, err
+		}
+		planned = append(planned, plannedRequest{units: batch, request: request})
+	}
+	return planned, separators, nil
+}
+
+// translateSegments sends the planned requests to the provider, adding their usage
+// and cost to response as they are answered, and returns the translation of every
+// missing segment
+func (p *Pipeline) translateSegments(ctx context.Context, req TranslateDocumentRequest, prep *preparedDocument, response *TranslateDocumentResponse) (map[int]string, error) {
+	planned, separators, err := p.planRequests(req, prep)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make(map[int][]string)
+	for _, pr := range planned {
+		result, err := p.Translator.Translate(ctx, pr.request)
+		if err != nil {
+			return nil, err
+		}
+		// The request is billed even when its answer turns out to be unusable
+		response.Requests++
+		if result.Provider != "" {
+			response.Provider = result.Provider
+		}
+		response.Usage.PromptTokens += result.Usage.PromptTokens
+		response.Usage.CompletionTokens += result.Usage.CompletionTokens
+		response.Cost += result.Cost
+		if len(result.Translations) != len(pr.units) {
+			return nil, fmt.Errorf("provider returned %d translations for %d segments", len(result.Translations), len(pr.units))
+		}
+
+		for j, unit := range pr.units {
+			parts[unit.segment] = append(parts[unit.segment], result.Translations[j])
+		}
+	}
+
+	results := make(map[int]string, len(prep.missing))
+	for _, i := range prep.missing {
+		var sb strings.Builder
+		for k, part := range parts[i] {
+			if k > 0 {
+				sb.WriteString(separators[i][k-1])
+			}
+			sb.WriteString(part)
+		}
+		results[i] = sb.String()
+	}
+	return results, nil
+}
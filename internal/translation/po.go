@@ -0,0 +1,180 @@
+package translation
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EncodePO writes units as a gettext PO file. msgctxt holds the path#index unit ID,
+// and machine translations are flagged fuzzy so translators review them.
+func EncodePO(file ExchangeFile) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("msgid \"\"\nmsgstr \"\"\n")
+	buf.WriteString(poString("Content-Type: text/plain; charset=UTF-8\n"))
+	buf.WriteString(poString("Language: " + file.TargetLanguage + "\n"))
+	buf.WriteString(poString("X-Source-Language: " + file.SourceLanguage + "\n"))
+
+	for _, unit := range file.Units {
+		buf.WriteString("\n")
+		fmt.Fprintf(&buf, "#. source-hash: %s\n", unit.SourceHash)
+		if unit.Context != "" {
+			fmt.Fprintf(&buf, "#. context: %s\n", unit.Context)
+		}
+		for i, p := range unit.Placeholders {
+			fmt.Fprintf(&buf, "#. ph %d: %s\n", i, strings.ReplaceAll(p, "\n", `\n`))
+		}
+		fmt.Fprintf(&buf, "#: %s\n", unit.Path)
+		if unit.Target != "" && unit.Origin != OriginHuman {
+			buf.WriteString("#, fuzzy\n")
+		}
+		buf.WriteString("msgctxt " + poString(unit.UnitID()))
+		buf.WriteString("msgid " + poString(unit.Source))
+		buf.WriteString("msgstr " + poString(unit.Target))
+	}
+	return buf.Bytes()
+}
+
+// poString quotes a value, splitting multi-line values after each newline
+func poString(s string) string {
+	if !strings.Contains(strings.TrimSuffix(s, "\n"), "\n") {
+		return poQuote(s) + "\n"
+	}
+	var sb strings.Builder
+	sb.WriteString("\"\"\n")
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if line != "" {
+			sb.WriteString(poQuote(line) + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func poQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// DecodePO reads a gettext PO file. Entries flagged fuzzy are imported without a
+// translation, and obsolete entries are ignored.
+func DecodePO(data []byte) (*ExchangeFile, error) {
+	file := &ExchangeFile{}
+
+	type entry struct {
+		ctxt, id, str, hash string
+		fuzzy, done         bool
+	}
+	var current entry
+	var field *string
+
+	// flush ends the current entry; an entry is complete once its msgstr was read
+	flush := func() error {
+		if !current.done {
+			return nil
+		}
+		defer func() { current = entry{} }()
+		if current.ctxt == "" && current.id == "" {
+			// Header entry
+			for _, line := range strings.Split(current.str, "\n") {
+				if v, ok := strings.CutPrefix(line, "Language: "); ok {
+					file.TargetLanguage = strings.TrimSpace(v)
+				}
+				if v, ok := strings.CutPrefix(line, "X-Source-Language: "); ok {
+					file.SourceLanguage = strings.TrimSpace(v)
+				}
+			}
+			return nil
+		}
+		path, index, err := parseUnitID(current.ctxt)
+		if err != nil {
+			return err
+		}
+		unit := ExchangeUnit{Path: path, Index: index, SourceHash: current.hash, Source: current.id}
+		if !current.fuzzy {
+			unit.Target = current.str
+		}
+		file.Units = append(file.Units, unit)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#~"):
+			field = nil
+		case strings.HasPrefix(line, "#"):
+			field = nil
+			if err := flush(); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			if v, ok := strings.CutPrefix(line, "#. source-hash:"); ok {
+				current.hash = strings.TrimSpace(v)
+			} else if v, ok := strings.CutPrefix(line, "#,"); ok {
+				for _, flag := range strings.Split(v, ",") {
+					if strings.TrimSpace(flag) == "fuzzy" {
+						current.fuzzy = true
+					}
+				}
+			}
+		case strings.HasPrefix(line, `"`):
+			if field == nil {
+				return nil, fmt.Errorf("line %d: unexpected string", lineNumber)
+			}
+			value, err := poUnquote(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			*field += value
+		default:
+			keyword, rest, _ := strings.Cut(line, " ")
+			switch keyword {
+			case "msgctxt", "msgid":
+				if err := flush(); err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+				}
+				if keyword == "msgctxt" {
+					field = &current.ctxt
+				} else {
+					field = &current.id
+				}
+			case "msgstr":
+				field = &current.str
+				current.done = true
+			case "msgid_plural", "msgstr[0]":
+				return nil, fmt.Errorf("line %d: plural entries are not supported", lineNumber)
+			default:
+				return nil, fmt.Errorf("line %d: unknown keyword %q", lineNumber, keyword)
+			}
+			value, err := poUnquote(strings.TrimSpace(rest))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			*field = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func poUnquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	value, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	return value, nil
+}
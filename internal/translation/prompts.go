@@ -0,0 +1,98 @@
+package translation
+
+import (
+	"strings"
+
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+)
+
+// maxContextKeys caps the message keys listed in a prompt's context
+const maxContextKeys = 20
+
+// Prompts provides the prompt template that applies to a project and target language
+type Prompts interface {
+	Template(projectID int, language string) (string, error)
+}
+
+// ProjectPrompts reads prompt templates from the project tables
+type ProjectPrompts struct{}
+
+// NewProjectPrompts creates a prompt source backed by the shared database
+func NewProjectPrompts() *ProjectPrompts {
+	return &ProjectPrompts{}
+}
+
+func (p *ProjectPrompts) Template(projectID int, language string) (string, error) {
+	proj, err := project.GetProjectByID(projectID)
+	if err != nil {
+		return "", err
+	}
+	return proj.PromptTemplateFor(language), nil
+}
+
+// PromptPreview is the response of the prompt dry-run endpoint
+type PromptPreview struct {
+	Path           string                 `json:"path"`
+	SourceLanguage string                 `json:"source_language"`
+	TargetLanguage string                 `json:"target_language"`
+	Segments       int                    `json:"segments"`
+	ExactHits      int                    `json:"exact_hits"`
+	FuzzyHits      int                    `json:"fuzzy_hits"`
+	Requests       []PromptRequestPreview `json:"requests"`
+}
+
+// PromptRequestPreview is one provider request as it would be sent
+type PromptRequestPreview struct {
+	System          string `json:"system"`
+	User            string `json:"user"`
+	EstimatedTokens int    `json:"estimated_tokens"`
+}
+
+// promptContext describes where the given segments sit in the document: its title,
+// the headings they appear under and, for message catalogs, their keys
+func promptContext(doc *Document, segments []int) string {
+	title := ""
+	for _, segment := range doc.Segments {
+		if segment.Context == "front_matter:title" {
+			title = fillPlaceholders(segment.Text, segment.Placeholders)
+			break
+		}
+		if title == "" && segment.Context == "heading" {
+			title = fillPlaceholders(segment.Text, segment.Placeholders)
+		}
+	}
+
+	var sections, keys []string
+	seenSection := make(map[int]bool)
+	for _, i := range segments {
+		segment := doc.Segments[i]
+		if strings.HasPrefix(segment.Context, "key:") {
+			if len(keys) < maxContextKeys {
+				keys = append(keys, strings.TrimPrefix(segment.Context, "key:"))
+			}
+			continue
+		}
+		for h := i; h >= 0; h-- {
+			if doc.Segments[h].Context != "heading" {
+				continue
+			}
+			if !seenSection[h] {
+				seenSection[h] = true
+				sections = append(sections, fillPlaceholders(doc.Segments[h].Text, doc.Segments[h].Placeholders))
+			}
+			break
+		}
+	}
+
+	var lines []string
+	if title != "" {
+		lines = append(lines, "Document title: "+title)
+	}
+	if len(sections) > 0 {
+		lines = append(lines, "Sections: "+strings.Join(sections, "; "))
+	}
+	if len(keys) > 0 {
+		lines = append(lines, "Message keys: "+strings.Join(keys, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,112 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xeodocs/xeodocs-backend/internal/project"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+)
+
+type staticPrompts map[string]string
+
+func (p staticPrompts) Template(projectID int, language string) (string, error) {
+	proj := &project.Project{PromptTemplate: p[""], LanguagePrompts: project.PromptTemplates{}}
+	for lang, tmpl := range p {
+		if lang != "" {
+			proj.LanguagePrompts[lang] = tmpl
+		}
+	}
+	return proj.PromptTemplateFor(language), nil
+}
+
+func TestPipelineRendersProjectPromptTemplates(t *testing.T) {
+	translator := &recordingTranslator{}
+	pipeline := &Pipeline{
+		Translator: translator,
+		Glossary:   staticGlossary{{Term: "CLI", DoNotTranslate: true}},
+		Prompts: staticPrompts{
+			"":   "Translate {{.SourceLanguage}} to {{.TargetLanguage}}.",
+			"ja": "Use polite Japanese for {{.FilePath}}.\n{{.Context}}{{.Glossary}}",
+		},
+	}
+	req := TranslateDocumentRequest{ProjectID: 1, SourceLanguage: "en", TargetLanguage: "es", Path: "docs/setup.md",
+		Content: "---\ntitle: Setup\n---\n\n# Install\n\nInstall the CLI.\n"}
+
+	_, err := pipeline.TranslateDocument(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, "Translate en to es.", translator.requests[0].Instructions)
+
+	req.TargetLanguage = "ja"
+	_, err = pipeline.TranslateDocument(context.Background(), req)
+	require.NoError(t, err)
+	instructions := translator.requests[1].Instructions
+	require.Contains(t, instructions, "Use polite Japanese for docs/setup.md.")
+	require.Contains(t, instructions, "Document title: Setup")
+	require.Contains(t, instructions, "Sections: Install")
+	require.Contains(t, instructions, "CLI")
+}
+
+func TestPipelineRejectsInvalidPromptTemplate(t *testing.T) {
+	pipeline := &Pipeline{Translator: &recordingTranslator{}, Prompts: staticPrompts{"": "{{.Unknown}}"}}
+	_, err := pipeline.TranslateDocument(context.Background(), TranslateDocumentRequest{ProjectID: 1, SourceLanguage: "en", TargetLanguage: "es", Path: "a.md", Content: "Hello.\n"})
+	require.ErrorIs(t, err, project.ErrInvalidPromptTemplate)
+}
+
+func TestPreviewPromptsDoesNotCallProvider(t *testing.T) {
+	translator := &recordingTranslator{}
+	memory := newFakeMemory()
+	require.NoError(t, memory.Store(1, "es", []MemoryEntry{{SourceHash: SegmentHash("Run it."), SourceText: "Run it.", TargetText: "Ejecútalo.", Origin: OriginHuman}}))
+	pipeline := &Pipeline{Translator: translator, Memory: memory}
+
+	preview, err := pipeline.PreviewPrompts(TranslateDocumentRequest{ProjectID: 1, SourceLanguage: "en", TargetLanguage: "es", Path: "a.md",
+		Content: "# Install the CLI\n\nRun it.\n\nRun it again.\n"})
+	require.NoError(t, err)
+	require.Empty(t, translator.requests)
+	require.Equal(t, 3, preview.Segments)
+	require.Equal(t, 1, preview.ExactHits)
+	require.Len(t, preview.Requests, 1)
+	require.Equal(t, `["Install the CLI","Run it again."]`, preview.Requests[0].User)
+	require.Contains(t, preview.Requests[0].System, "from English to Spanish")
+	require.Contains(t, preview.Requests[0].System, "The strings come from the file a.md.")
+	require.Contains(t, preview.Requests[0].System, "Reply with a JSON array")
+	require.Positive(t, preview.Requests[0].EstimatedTokens)
+}
+
+func TestPromptContextListsMessageKeys(t *testing.T) {
+	doc, err := ParseDocument("locales/en.json", `{"nav": {"home": "Home", "docs": "Docs"}}`)
+	require.NoError(t, err)
+	require.Equal(t, "Message keys: nav.home, nav.docs", promptContext(doc, []int{0, 1}))
+}
+
+func TestFetchSourceFileReadsRepository(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		require.Equal(t, "/projects/3/files/raw", r.URL.Path)
+		switch r.URL.Query().Get("path") {
+		case "docs/intro.md":
+			require.Equal(t, "v1.0", r.URL.Query().Get("ref"))
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte("# Intro\n"))
+		case "docs/logo.png":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte{0x89, 'P', 'N', 'G'})
+		default:
+			http.Error(w, "file not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	cfg := &config.Config{RepositoryServiceURL: server.URL}
+
+	content, err := fetchSourceFile(cfg, "Bearer token", 3, "docs/intro.md", "v1.0")
+	require.NoError(t, err)
+	require.Equal(t, "# Intro\n", content)
+
+	_, err = fetchSourceFile(cfg, "Bearer token", 3, "docs/missing.md", "")
+	require.ErrorIs(t, err, errSourceFileNotFound)
+	_, err = fetchSourceFile(cfg, "Bearer token", 3, "docs/logo.png", "")
+	require.ErrorIs(t, err, errSourceFileUnusable)
+}
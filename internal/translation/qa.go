@@ -0,0 +1,457 @@
+package translation
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+)
+
+// Quality checks run on every translated file
+const (
+	QACheckLinks        = "links"
+	QACheckCode         = "code"
+	QACheckNumbers      = "numbers"
+	QACheckHeadings     = "headings"
+	QACheckTables       = "tables"
+	QACheckPlaceholders = "placeholders"
+	QACheckLanguage     = "language"
+)
+
+// Finding severities. Files with errors are held for review instead of being published.
+const (
+	QASeverityError   = "error"
+	QASeverityWarning = "warning"
+)
+
+// minLanguageSample is the number of letters needed before the output language is checked
+const minLanguageSample = 60
+
+var (
+	linkDestinationPattern = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)`)
+	linkAttributePattern   = regexp.MustCompile(`\b(?:href|src)=["']([^"']+)["']`)
+	urlPattern             = regexp.MustCompile(`https?://[^\s<>()\[\]"'` + "`" + `]+`)
+	numberPattern          = regexp.MustCompile(`\d+(?:[.,\x{00a0} ]\d+)*`)
+)
+
+// QAFinding is a problem found by comparing a source file with its translation
+type QAFinding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Segment  *int   `json:"segment,omitempty"`
+	Message  string `json:"message"`
+}
+
+// QAInput is what the quality checks compare
+type QAInput struct {
+	Path           string
+	TargetLanguage string
+	Source         string
+	Target         string
+	Document       *Document
+	Translations   []string
+	// Untranslated lists segments kept in the source language because their
+	// translation broke a placeholder
+	Untranslated []int
+}
+
+// QAPassed reports whether findings contain no errors
+func QAPassed(findings []QAFinding) bool {
+	for _, f := range findings {
+		if f.Severity == QASeverityError {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveQAFindings replaces the stored findings of a file with those of its latest translation
+func SaveQAFindings(projectID int, language, sourcePath string, findings []QAFinding) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM translation_qa_findings WHERE project_id = $1 AND language = $2 AND source_path = $3`, projectID, language, sourcePath); err != nil {
+		return err
+	}
+	query := `INSERT INTO translation_qa_findings (project_id, language, source_path, check_name, severity, segment_index, message, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	now := time.Now()
+	for _, f := range findings {
+		if _, err := tx.Exec(query, projectID, language, sourcePath, f.Check, f.Severity, f.Segment, f.Message, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetQAFindings returns the findings of the latest translation of a file
+func GetQAFindings(projectID int, language, sourcePath string) ([]QAFinding, error) {
+	query := `SELECT check_name, severity, segment_index, message FROM translation_qa_findings
+		WHERE project_id = $1 AND language = $2 AND source_path = $3 ORDER BY id`
+	rows, err := db.DB.Query(query, projectID, language, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	findings := []QAFinding{}
+	for rows.Next() {
+		var f QAFinding
+		if err := rows.Scan(&f.Check, &f.Severity, &f.Segment, &f.Message); err != nil {
+			return nil, err
+		}
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
+
+// CheckQuality compares a translated file with its source: link targets, code,
+// numbers, heading and table structure, placeholders and the output language
+func CheckQuality(in QAInput) []QAFinding {
+	var findings []QAFinding
+	add := func(check, severity string, segment *int, format string, args ...interface{}) {
+		findings = append(findings, QAFinding{Check: check, Severity: severity, Segment: segment, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if missing, extra := compareMultisets(extractLinks(in.Source), extractLinks(in.Target)); len(missing)+len(extra) > 0 {
+		add(QACheckLinks, QASeverityError, nil, "link targets differ: %s", describeDiff(missing, extra))
+	}
+
+	markdown := false
+	if _, ok := documentParsers[strings.ToLower(filepath.Ext(in.Path))]; ok {
+		markdown = true
+	}
+	if markdown {
+		source, target := markdownOutline(in.Source), markdownOutline(in.Target)
+		if missing, extra := compareMultisets(source.code, target.code); len(missing)+len(extra) > 0 {
+			add(QACheckCode, QASeverityError, nil, "%d code spans or blocks were changed, dropped or added", len(missing)+len(extra))
+		}
+		if strings.Join(source.headings, " ") != strings.Join(target.headings, " ") {
+			add(QACheckHeadings, QASeverityError, nil, "heading structure differs: %q in source, %q in translation",
+				strings.Join(source.headings, " "), strings.Join(target.headings, " "))
+		}
+		if fmt.Sprint(source.tables) != fmt.Sprint(target.tables) {
+			add(QACheckTables, QASeverityError, nil, "table rows or columns differ")
+		}
+	}
+
+	var sample strings.Builder
+	for _, i := range in.Untranslated {
+		i := i
+		add(QACheckPlaceholders, QASeverityError, &i, "segment kept in the source language because its translation broke a placeholder")
+	}
+	if in.Document != nil {
+		for i, segment := range in.Document.Segments {
+			if i >= len(in.Translations) || in.Translations[i] == "" {
+				continue
+			}
+			i := i
+			translation := in.Translations[i]
+			if err := ValidatePlaceholders(segment, translation); err != nil {
+				add(QACheckPlaceholders, QASeverityError, &i, "%v", err)
+			}
+			if missing, extra := compareMultisets(segmentNumbers(segment.Text), segmentNumbers(translation)); len(missing)+len(extra) > 0 {
+				add(QACheckNumbers, QASeverityError, &i, "numbers differ: %s", describeDiff(missing, extra))
+			}
+			if translation != segment.Text {
+				sample.WriteString(placeholderPattern.ReplaceAllString(translation, " "))
+				sample.WriteByte('\n')
+			}
+		}
+	}
+
+	if !canDetectLanguage(in.TargetLanguage) {
+		return findings
+	}
+	if detected, ok := detectLanguage(sample.String()); ok && !matchesLanguage(detected, in.TargetLanguage) {
+		add(QACheckLanguage, QASeverityError, nil, "translation appears to be in %s instead of %s", languageName(detected), languageName(in.TargetLanguage))
+	}
+	return findings
+}
+
+// extractLinks returns every link destination and URL of a file
+func extractLinks(content string) []string {
+	var links []string
+	for _, m := range linkDestinationPattern.FindAllStringSubmatch(content, -1) {
+		links = append(links, m[1])
+	}
+	for _, m := range linkAttributePattern.FindAllStringSubmatch(content, -1) {
+		links = append(links, m[1])
+	}
+	// Bare URLs, also counting those already found as destinations so that a
+	// dropped link is noticed even when the same URL appears elsewhere
+	for _, url := range urlPattern.FindAllString(content, -1) {
+		links = append(links, strings.TrimRight(url, `.,;:!?`))
+	}
+	return links
+}
+
+// outline is the structure of a Markdown file that a translation must keep
+type outline struct {
+	headings []string
+	tables   []int
+	code     []string
+}
+
+func markdownOutline(content string) outline {
+	var o outline
+	var fence string
+	var block strings.Builder
+	for _, l := range splitLines(content) {
+		text := l.text
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimLeft(text, " "), fence) && strings.Trim(strings.TrimSpace(text), fence[:1]) == "" {
+				o.code = append(o.code, block.String())
+				block.Reset()
+				fence = ""
+				continue
+			}
+			block.WriteString(text + "\n")
+			continue
+		}
+		if m := fencePattern.FindStringSubmatch(text); m != nil {
+			fence = m[1]
+			continue
+		}
+		if m := headingPattern.FindStringSubmatch(text); m != nil {
+			o.headings = append(o.headings, strings.TrimSpace(m[1]))
+		}
+		if trimmed := strings.TrimSpace(text); strings.HasPrefix(trimmed, "|") {
+			o.tables = append(o.tables, strings.Count(strings.ReplaceAll(trimmed, `\|`, ""), "|"))
+		}
+		for i := 0; i < len(text); i++ {
+			if text[i] == '\\' {
+				i++
+				continue
+			}
+			if text[i] != '`' {
+				continue
+			}
+			end := scanCodeSpan(text, i)
+			if end < 0 {
+				for i+1 < len(text) && text[i+1] == '`' {
+					i++
+				}
+				continue
+			}
+			o.code = append(o.code, text[i:end])
+			i = end - 1
+		}
+	}
+	return o
+}
+
+// segmentNumbers returns the digits of every number in a segment, ignoring
+// placeholders and the thousands and decimal separators that vary by locale
+func segmentNumbers(text string) []string {
+	text = placeholderPattern.ReplaceAllString(text, " ")
+	var numbers []string
+	for _, m := range numberPattern.FindAllString(text, -1) {
+		numbers = append(numbers, strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, m))
+	}
+	return numbers
+}
+
+// compareMultisets returns the items of a missing from b and the items of b not in a
+func compareMultisets(a, b []string) (missing, extra []string) {
+	counts := make(map[string]int)
+	for _, item := range a {
+		counts[item]++
+	}
+	for _, item := range b {
+		counts[item]--
+	}
+	for item, n := range counts {
+		for ; n > 0; n-- {
+			missing = append(missing, item)
+		}
+		for ; n < 0; n++ {
+			extra = append(extra, item)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
+
+func describeDiff(missing, extra []string) string {
+	var parts []string
+	if len(missing) > 0 {
+		parts = append(parts, fmt.Sprintf("missing %s", strings.Join(missing, ", ")))
+	}
+	if len(extra) > 0 {
+		parts = append(parts, fmt.Sprintf("unexpected %s", strings.Join(extra, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// scriptLanguages is the language reported for text written in each non-Latin script
+var scriptLanguages = []struct {
+	script *unicode.RangeTable
+	code   string
+}{
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Han, "zh"},
+}
+
+// languageScripts maps languages written outside the Latin script to their script.
+// Languages sharing a script, such as Russian and Ukrainian, are only told apart by script.
+var languageScripts = map[string]*unicode.RangeTable{
+	"ru": unicode.Cyrillic,
+	"uk": unicode.Cyrillic,
+	"bg": unicode.Cyrillic,
+	"sr": unicode.Cyrillic,
+	"be": unicode.Cyrillic,
+	"el": unicode.Greek,
+	"ar": unicode.Arabic,
+	"fa": unicode.Arabic,
+	"ur": unicode.Arabic,
+	"he": unicode.Hebrew,
+	"hi": unicode.Devanagari,
+	"mr": unicode.Devanagari,
+	"th": unicode.Thai,
+	"ko": unicode.Hangul,
+	"ja": unicode.Hiragana,
+	"zh": unicode.Han,
+}
+
+// languageStopwords are frequent short words used to tell Latin-script languages apart
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "to", "of", "in", "you", "your", "with", "for", "this", "that", "it", "be", "can", "on", "by", "an", "not"},
+	"es": {"el", "la", "los", "las", "de", "que", "y", "en", "un", "una", "es", "para", "con", "por", "su", "se", "del", "al", "como", "puede"},
+	"fr": {"le", "la", "les", "de", "des", "et", "est", "un", "une", "pour", "dans", "que", "vous", "avec", "sur", "du", "au", "pas", "ce", "votre"},
+	"de": {"der", "die", "das", "und", "ist", "ein", "eine", "zu", "den", "mit", "für", "von", "sie", "auf", "nicht", "im", "dem", "des", "werden", "wird"},
+	"it": {"il", "la", "di", "che", "e", "un", "una", "per", "con", "del", "della", "è", "sono", "non", "gli", "le", "al", "si", "puoi", "questo"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "em", "um", "uma", "para", "com", "não", "do", "da", "no", "na", "por", "você", "é"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "op", "te", "in", "voor", "met", "niet", "zijn", "je", "u", "ook", "aan", "deze", "wordt"},
+}
+
+// detectLanguage guesses the language of text from its script, or for Latin-script
+// text from its most frequent words. ok is false when the sample is too small or
+// no language clearly stands out.
+func detectLanguage(text string) (string, bool) {
+	letters, latin, sample := 0, 0, 0
+	scripts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		sample++
+		// Ideographic and syllabic scripts pack more text into each character
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			sample += 2
+		}
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, sl := range scriptLanguages {
+			if unicode.Is(sl.script, r) {
+				scripts[sl.code]++
+			}
+		}
+	}
+	if sample < minLanguageSample {
+		return "", false
+	}
+
+	// Japanese mixes kana with Han, so any kana means Japanese
+	if scripts["ja"]+countRunes(text, unicode.Katakana) > letters/10 {
+		return "ja", true
+	}
+	best, bestCount := "", 0
+	for code, n := range scripts {
+		if n > bestCount || n == bestCount && code < best {
+			best, bestCount = code, n
+		}
+	}
+	if bestCount > latin {
+		return best, true
+	}
+
+	counts := make(map[string]int)
+	words := 0
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		words++
+		for code, stopwords := range languageStopwords {
+			for _, stopword := range stopwords {
+				if word == stopword {
+					counts[code]++
+					break
+				}
+			}
+		}
+	}
+	best, bestCount, second := "", 0, 0
+	for code, n := range counts {
+		switch {
+		case n > bestCount || n == bestCount && code < best:
+			best, bestCount, second = code, n, bestCount
+		case n > second:
+			second = n
+		}
+	}
+	// Require a clear lead, since technical text mixes in English terms
+	if words == 0 || bestCount*10 < words || bestCount < second*2 {
+		return "", false
+	}
+	return best, true
+}
+
+func countRunes(text string, table *unicode.RangeTable) int {
+	n := 0
+	for _, r := range text {
+		if unicode.Is(table, r) {
+			n++
+		}
+	}
+	return n
+}
+
+// canDetectLanguage reports whether detectLanguage knows the language
+func canDetectLanguage(code string) bool {
+	code = baseLanguage(code)
+	_, script := languageScripts[code]
+	_, words := languageStopwords[code]
+	return script || words
+}
+
+// matchesLanguage compares a detected language with the target ignoring regions,
+// so "pt" matches "pt-BR", and by script for languages written in the same one
+func matchesLanguage(detected, target string) bool {
+	target = baseLanguage(target)
+	if detected == target {
+		return true
+	}
+	script, ok := languageScripts[target]
+	return ok && languageScripts[detected] == script
+}
+
+func baseLanguage(code string) string {
+	code = strings.ToLower(code)
+	if idx := strings.IndexAny(code, "-_"); idx >= 0 {
+		code = code[:idx]
+	}
+	return code
+}
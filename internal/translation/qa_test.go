@@ -0,0 +1,134 @@
+package translation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const qaSource = `# Install
+
+Run the server on port 8080 and open [the dashboard](https://example.com/dash).
+
+| Option | Description |
+|--------|-------------|
+| ` + "`--port`" + ` | The port to listen on |
+
+## Configure
+
+Set the timeout to 30 seconds before you start the service with your own settings.
+`
+
+const qaTarget = `# Instalación
+
+Ejecuta el servidor en el puerto 8080 y abre [el panel](https://example.com/dash).
+
+| Opción | Descripción |
+|--------|-------------|
+| ` + "`--port`" + ` | El puerto en el que escuchar |
+
+## Configuración
+
+Establece el tiempo de espera en 30 segundos antes de iniciar el servicio con tu propia configuración.
+`
+
+func qaChecks(findings []QAFinding) []string {
+	var checks []string
+	for _, f := range findings {
+		checks = append(checks, f.Check)
+	}
+	return checks
+}
+
+func TestCheckQualityPassesFaithfulTranslation(t *testing.T) {
+	doc := ParseMarkdown(qaSource)
+	target := ParseMarkdown(qaTarget)
+	require.Len(t, target.Segments, len(doc.Segments))
+
+	findings := CheckQuality(QAInput{
+		Path:           "docs/install.md",
+		TargetLanguage: "es",
+		Source:         qaSource,
+		Target:         qaTarget,
+		Document:       doc,
+		Translations:   target.Texts(),
+	})
+	require.Empty(t, findings)
+	require.True(t, QAPassed(findings))
+}
+
+func TestCheckQualityFindsStructuralChanges(t *testing.T) {
+	broken := `## Instalación
+
+Ejecuta el servidor en el puerto 8081 y abre el panel.
+
+| Opción | Descripción |
+|--------|-------------|
+| ` + "`--port`" + ` El puerto en el que escuchar |
+
+## Configuración
+
+Establece el tiempo de espera en 30 segundos antes de iniciar el servicio con tu propia configuración.
+`
+	doc := ParseMarkdown(qaSource)
+	translations := doc.Texts()
+	translations[1] = "Ejecuta el servidor en el puerto 8081 y abre el panel."
+
+	findings := CheckQuality(QAInput{
+		Path:           "docs/install.md",
+		TargetLanguage: "es",
+		Source:         qaSource,
+		Target:         broken,
+		Document:       doc,
+		Translations:   translations,
+		Untranslated:   []int{2},
+	})
+	require.False(t, QAPassed(findings))
+	require.ElementsMatch(t, []string{QACheckLinks, QACheckHeadings, QACheckTables, QACheckPlaceholders, QACheckPlaceholders, QACheckNumbers}, qaChecks(findings))
+}
+
+func TestCheckQualityFindsWrongLanguage(t *testing.T) {
+	doc := ParseMarkdown(qaSource)
+	translations := doc.Texts()
+	translations[len(translations)-1] = "Set the timeout to 30 seconds before you start the service with your own settings, and keep it in the config file."
+
+	findings := CheckQuality(QAInput{
+		Path:           "docs/install.md",
+		TargetLanguage: "es",
+		Source:         qaSource,
+		Target:         qaSource,
+		Document:       doc,
+		Translations:   translations,
+	})
+	require.Equal(t, []string{QACheckLanguage}, qaChecks(findings))
+	require.Contains(t, findings[0].Message, "English instead of Spanish")
+
+	// Languages that cannot be detected are not checked
+	findings = CheckQuality(QAInput{Path: "docs/install.md", TargetLanguage: "sw", Source: qaSource, Target: qaSource, Document: doc, Translations: translations})
+	require.Empty(t, findings)
+}
+
+func TestDetectLanguage(t *testing.T) {
+	for _, tc := range []struct {
+		text     string
+		expected string
+	}{
+		{"Ejecuta el servidor y abre el panel de control para ver los registros de la aplicación en tiempo real.", "es"},
+		{"Lancez le serveur et ouvrez le tableau de bord pour voir les journaux de votre application en temps réel.", "fr"},
+		{"Starten Sie den Server und öffnen Sie das Dashboard, um die Protokolle der Anwendung in Echtzeit zu sehen.", "de"},
+		{"サーバーを起動して、ダッシュボードを開き、アプリケーションのログをリアルタイムで確認します。設定ファイルも確認してください。", "ja"},
+		{"Запустите сервер и откройте панель управления, чтобы увидеть журналы приложения в реальном времени.", "ru"},
+	} {
+		detected, ok := detectLanguage(tc.text)
+		require.True(t, ok, tc.text)
+		require.Equal(t, tc.expected, detected, tc.text)
+	}
+
+	_, ok := detectLanguage("Run npm install")
+	require.False(t, ok)
+}
+
+func TestSegmentNumbersIgnoreSeparators(t *testing.T) {
+	require.Equal(t, []string{"10005", "3"}, segmentNumbers(`Costs 1,000.5 for <ph id="0"/> 3 users`))
+	require.Equal(t, []string{"10005", "3"}, segmentNumbers("Cuesta 1.000,5 para 3 usuarios"))
+}
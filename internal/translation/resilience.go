@@ -0,0 +1,317 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrProviderUnavailable is returned when a provider keeps failing or its circuit breaker is open
+var ErrProviderUnavailable = errors.New("AI provider unavailable")
+
+// ProviderError is a failed provider call. StatusCode is zero when the request did not get a response.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("failed to call AI provider %s: %s", e.Provider, e.Message)
+	}
+	return fmt.Sprintf("AI provider %s returned status %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the call may succeed when retried: rate limits,
+// server errors and requests that got no response
+func (e *ProviderError) Temporary() bool {
+	return e.StatusCode == 0 || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// isTemporary reports whether err is a provider failure worth retrying
+func isTemporary(err error) bool {
+	var providerErr *ProviderError
+	return errors.As(err, &providerErr) && providerErr.Temporary()
+}
+
+// retryAfter returns how long the provider asked callers to wait, or zero
+func retryAfter(err error) time.Duration {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.RetryAfter
+	}
+	return 0
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// RetryPolicy controls how temporary provider failures are retried
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	// MaxDelay caps the backoff. A Retry-After longer than MaxDelay is not waited
+	// for, so the request can move on to a fallback provider instead.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy retries three times, starting at one second
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+
+// delay returns the wait before retry number attempt (starting at 0), or false
+// when the provider asked for a longer wait than the policy allows
+func (p RetryPolicy) delay(attempt int, retryAfter time.Duration, jitter func() float64) (time.Duration, bool) {
+	if retryAfter > 0 {
+		if p.MaxDelay > 0 && retryAfter > p.MaxDelay {
+			return 0, false
+		}
+		return retryAfter, true
+	}
+	d := p.BaseDelay << attempt
+	if p.MaxDelay > 0 && (d > p.MaxDelay || d <= 0) {
+		d = p.MaxDelay
+	}
+	// Waits between half and all of the backoff so that concurrent callers spread out
+	return d/2 + time.Duration(jitter()*float64(d/2)), true
+}
+
+// circuitBreaker stops calls to a provider after consecutive failures and lets a
+// single trial call through once the cooldown has passed
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	trial     bool
+	now       func() time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow reports whether a call may go ahead, and otherwise how long the breaker stays open
+func (b *circuitBreaker) allow() (bool, time.Duration) {
+	if b == nil || b.threshold <= 0 {
+		return true, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true, 0
+	}
+	now := b.now()
+	if now.Before(b.openUntil) {
+		return false, b.openUntil.Sub(now)
+	}
+	if b.trial {
+		return false, b.cooldown
+	}
+	b.trial = true
+	return true, 0
+}
+
+func (b *circuitBreaker) success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+}
+
+// release ends a trial call that was cancelled, without counting it either way
+func (b *circuitBreaker) release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *circuitBreaker) failure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// ResilientProvider wraps a provider with a concurrency limit, retries with
+// backoff for temporary failures and a circuit breaker
+type ResilientProvider struct {
+	provider Translator
+	policy   RetryPolicy
+	limiter  chan struct{}
+	breaker  *circuitBreaker
+	jitter   func() float64
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+// NewResilientProvider wraps provider. At most maxConcurrency calls run at once
+// (unlimited when zero), and after breakerThreshold consecutive failures calls
+// fail fast for breakerCooldown (never when the threshold is zero).
+func NewResilientProvider(provider Translator, policy RetryPolicy, maxConcurrency, breakerThreshold int, breakerCooldown time.Duration) *ResilientProvider {
+	r := &ResilientProvider{
+		provider: provider,
+		policy:   policy,
+		breaker:  newCircuitBreaker(breakerThreshold, breakerCooldown),
+		jitter:   rand.Float64,
+		sleep:    sleepContext,
+	}
+	if maxConcurrency > 0 {
+		r.limiter = make(chan struct{}, maxConcurrency)
+	}
+	return r
+}
+
+func (r *ResilientProvider) Name() string {
+	return r.provider.Name()
+}
+
+func (r *ResilientProvider) Translate(ctx context.Context, req TranslateRequest) (*TranslateResult, error) {
+	for attempt := 0; ; attempt++ {
+		if ok, wait := r.breaker.allow(); !ok {
+			return nil, &unavailableError{provider: r.Name(), retryAfter: wait}
+		}
+
+		result, err := r.call(ctx, req)
+		if err == nil {
+			r.breaker.success()
+			if result.Provider == "" {
+				result.Provider = r.Name()
+			}
+			return result, nil
+		}
+		// Every call settles the breaker, so a trial call never leaves it stuck open
+		if ctx.Err() != nil {
+			r.breaker.release()
+			return nil, err
+		}
+		if !isTemporary(err) {
+			// The provider answered, so it is up even though it rejected the request
+			r.breaker.success()
+			return nil, err
+		}
+		r.breaker.failure()
+
+		if attempt >= r.policy.MaxRetries {
+			return nil, fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+		}
+		delay, ok := r.policy.delay(attempt, retryAfter(err), r.jitter)
+		if !ok {
+			return nil, fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+		}
+		if err := r.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// call runs one provider request within the concurrency limit
+func (r *ResilientProvider) call(ctx context.Context, req TranslateRequest) (*TranslateResult, error) {
+	if r.limiter != nil {
+		select {
+		case r.limiter <- struct{}{}:
+			defer func() { <-r.limiter }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return r.provider.Translate(ctx, req)
+}
+
+// unavailableError is returned without calling a provider whose circuit breaker is open
+type unavailableError struct {
+	provider   string
+	retryAfter time.Duration
+}
+
+func (e *unavailableError) Error() string {
+	return fmt.Sprintf("%v: circuit breaker for %s is open", ErrProviderUnavailable, e.provider)
+}
+
+func (e *unavailableError) Unwrap() error {
+	return ErrProviderUnavailable
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FallbackTranslator sends each request to the first provider and moves on to the
+// next one when it is unavailable
+type FallbackTranslator struct {
+	providers []Translator
+}
+
+// NewFallbackTranslator tries providers in order
+func NewFallbackTranslator(providers ...Translator) *FallbackTranslator {
+	return &FallbackTranslator{providers: providers}
+}
+
+func (f *FallbackTranslator) Name() string {
+	return f.providers[0].Name()
+}
+
+func (f *FallbackTranslator) Translate(ctx context.Context, req TranslateRequest) (*TranslateResult, error) {
+	var lastErr error
+	for _, provider := range f.providers {
+		result, err := provider.Translate(ctx, req)
+		if err == nil {
+			if result.Provider == "" {
+				result.Provider = provider.Name()
+			}
+			return result, nil
+		}
+		if ctx.Err() != nil || !(errors.Is(err, ErrProviderUnavailable) || isTemporary(err)) {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// ProviderRetryAfter returns how long callers should wait before an unavailable
+// provider may accept requests again, or zero when unknown
+func ProviderRetryAfter(err error) time.Duration {
+	var open *unavailableError
+	if errors.As(err, &open) {
+		return open.retryAfter
+	}
+	return retryAfter(err)
+}
@@ -0,0 +1,197 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedTranslator fails with the queued errors before answering like the stub provider
+type scriptedTranslator struct {
+	StubProvider
+	name  string
+	errs  []error
+	calls int
+}
+
+func (t *scriptedTranslator) Name() string {
+	return t.name
+}
+
+func (t *scriptedTranslator) Translate(ctx context.Context, req TranslateRequest) (*TranslateResult, error) {
+	t.calls++
+	if len(t.errs) > 0 {
+		err := t.errs[0]
+		t.errs = t.errs[1:]
+		return nil, err
+	}
+	return t.StubProvider.Translate(ctx, req)
+}
+
+// recordSleeps replaces the provider's sleep so tests do not wait
+func recordSleeps(r *ResilientProvider) *[]time.Duration {
+	var sleeps []time.Duration
+	r.jitter = func() float64 { return 1 }
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return &sleeps
+}
+
+var helloRequest = TranslateRequest{SourceLanguage: "en", TargetLanguage: "es", Segments: []string{"Hello"}}
+
+func TestResilientProviderRetriesTemporaryErrors(t *testing.T) {
+	inner := &scriptedTranslator{name: "primary", errs: []error{
+		&ProviderError{Provider: "primary", StatusCode: http.StatusTooManyRequests, RetryAfter: 7 * time.Second},
+		&ProviderError{Provider: "primary", StatusCode: http.StatusBadGateway},
+	}}
+	provider := NewResilientProvider(inner, RetryPolicy{MaxRetries: 3, BaseDelay: time.Second, MaxDelay: time.Minute}, 1, 0, 0)
+	sleeps := recordSleeps(provider)
+
+	result, err := provider.Translate(context.Background(), helloRequest)
+	require.NoError(t, err)
+	require.Equal(t, []string{"[es] Hello"}, result.Translations)
+	require.Equal(t, "primary", result.Provider)
+	require.Equal(t, 3, inner.calls)
+	// Retry-After is honored, then the exponential backoff applies
+	require.Equal(t, []time.Duration{7 * time.Second, 2 * time.Second}, *sleeps)
+}
+
+func TestResilientProviderDoesNotRetryClientErrors(t *testing.T) {
+	inner := &scriptedTranslator{name: "primary", errs: []error{&ProviderError{Provider: "primary", StatusCode: http.StatusBadRequest}}}
+	provider := NewResilientProvider(inner, DefaultRetryPolicy, 0, 0, 0)
+	recordSleeps(provider)
+
+	_, err := provider.Translate(context.Background(), helloRequest)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrProviderUnavailable)
+	require.Equal(t, 1, inner.calls)
+}
+
+func TestResilientProviderGivesUpOnLongRetryAfter(t *testing.T) {
+	inner := &scriptedTranslator{name: "primary", errs: []error{&ProviderError{Provider: "primary", StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}}}
+	provider := NewResilientProvider(inner, DefaultRetryPolicy, 0, 0, 0)
+	sleeps := recordSleeps(provider)
+
+	_, err := provider.Translate(context.Background(), helloRequest)
+	require.ErrorIs(t, err, ErrProviderUnavailable)
+	require.Equal(t, time.Hour, ProviderRetryAfter(err))
+	require.Empty(t, *sleeps)
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	failure := &ProviderError{Provider: "primary", StatusCode: http.StatusInternalServerError}
+	inner := &scriptedTranslator{name: "primary", errs: []error{failure, failure}}
+	provider := NewResilientProvider(inner, RetryPolicy{MaxRetries: 0}, 0, 2, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	provider.breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := provider.Translate(context.Background(), helloRequest)
+		require.ErrorIs(t, err, ErrProviderUnavailable)
+	}
+
+	// Open: fails fast without calling the provider
+	_, err := provider.Translate(context.Background(), helloRequest)
+	require.ErrorIs(t, err, ErrProviderUnavailable)
+	require.Equal(t, time.Minute, ProviderRetryAfter(err))
+	require.Equal(t, 2, inner.calls)
+
+	// After the cooldown a trial call goes through and closes the breaker
+	now = now.Add(time.Minute)
+	_, err = provider.Translate(context.Background(), helloRequest)
+	require.NoError(t, err)
+	_, err = provider.Translate(context.Background(), helloRequest)
+	require.NoError(t, err)
+	require.Equal(t, 4, inner.calls)
+}
+
+func TestCircuitBreakerSettlesRejectedAndCancelledTrials(t *testing.T) {
+	failure := &ProviderError{Provider: "primary", StatusCode: http.StatusInternalServerError}
+	inner := &scriptedTranslator{name: "primary", errs: []error{failure, failure, context.Canceled}}
+	provider := NewResilientProvider(inner, RetryPolicy{MaxRetries: 0}, 0, 2, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	provider.breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := provider.Translate(context.Background(), helloRequest)
+		require.ErrorIs(t, err, ErrProviderUnavailable)
+	}
+
+	// A trial cancelled by the caller does not keep the breaker closed to later trials
+	now = now.Add(time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := provider.Translate(ctx, helloRequest)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 3, inner.calls)
+
+	// A trial rejected by the provider shows that it is up again
+	inner.errs = []error{&ProviderError{Provider: "primary", StatusCode: http.StatusUnauthorized}}
+	_, err = provider.Translate(context.Background(), helloRequest)
+	require.NotErrorIs(t, err, ErrProviderUnavailable)
+	_, err = provider.Translate(context.Background(), helloRequest)
+	require.NoError(t, err)
+	require.Equal(t, 5, inner.calls)
+}
+
+func TestFallbackTranslatorUsesSecondaryWhenPrimaryIsUnavailable(t *testing.T) {
+	primary := &scriptedTranslator{name: "primary", errs: []error{&ProviderError{Provider: "primary", StatusCode: http.StatusServiceUnavailable}}}
+	secondary := &scriptedTranslator{name: "secondary"}
+	translator := NewFallbackTranslator(primary, secondary)
+
+	result, err := translator.Translate(context.Background(), helloRequest)
+	require.NoError(t, err)
+	require.Equal(t, "secondary", result.Provider)
+	require.Equal(t, "primary", translator.Name())
+
+	// Errors in the request itself are not retried elsewhere
+	primary.errs = []error{errors.New("provider returned 2 translations for 1 segments")}
+	_, err = translator.Translate(context.Background(), helloRequest)
+	require.Error(t, err)
+	require.Equal(t, 1, secondary.calls)
+}
+
+func TestFallbackTranslatorPricesEachProvider(t *testing.T) {
+	primary := &scriptedTranslator{name: "primary", errs: []error{&ProviderError{Provider: "primary", StatusCode: http.StatusServiceUnavailable}}}
+	secondary := &scriptedTranslator{name: "secondary"}
+	translator := NewFallbackTranslator(
+		NewPricedTranslator(primary, Pricing{PromptPerMillion: 1, CompletionPerMillion: 1}),
+		NewPricedTranslator(secondary, Pricing{PromptPerMillion: 10, CompletionPerMillion: 20}),
+	)
+
+	result, err := translator.Translate(context.Background(), helloRequest)
+	require.NoError(t, err)
+	require.Equal(t, "secondary", result.Provider)
+	expected := (10*float64(result.Usage.PromptTokens) + 20*float64(result.Usage.CompletionTokens)) / 1e6
+	require.InDelta(t, expected, result.Cost, 1e-12)
+}
+
+func TestOpenAIProviderReportsRateLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "12")
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	_, err := NewOpenAIProvider(server.URL, "test-key", "test-model").Translate(context.Background(), helloRequest)
+	var providerErr *ProviderError
+	require.ErrorAs(t, err, &providerErr)
+	require.Equal(t, http.StatusTooManyRequests, providerErr.StatusCode)
+	require.Equal(t, 12*time.Second, providerErr.RetryAfter)
+	require.True(t, providerErr.Temporary())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, 30*time.Second, parseRetryAfter("30", now))
+	require.Equal(t, 90*time.Second, parseRetryAfter("Mon, 01 Jan 2024 12:01:30 GMT", now))
+	require.Zero(t, parseRetryAfter("", now))
+	require.Zero(t, parseRetryAfter("soon", now))
+}
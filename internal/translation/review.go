@@ -0,0 +1,232 @@
+package translation
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/queue"
+)
+
+var (
+	// ErrInvalidCorrection is returned when a submitted correction does not fit its segment
+	ErrInvalidCorrection = errors.New("invalid correction")
+	// ErrNotReviewable is returned when a file is not in a state that can be approved or rejected
+	ErrNotReviewable = errors.New("file cannot be reviewed in its current status")
+)
+
+// SegmentCorrection is an editor's translation for one segment
+type SegmentCorrection struct {
+	Index       int    `json:"index"`
+	Translation string `json:"translation"`
+}
+
+// ReviewRequest is the body of the correction, approve and reject endpoints
+type ReviewRequest struct {
+	Segments []SegmentCorrection `json:"segments,omitempty"`
+	Comment  string              `json:"comment,omitempty"`
+}
+
+// SaveFileSegments replaces the stored segments of a translated file with the
+// segments it was rendered from. Approved corrections come back from translation
+// memory as human segments until their source segment changes; corrections that
+// were never approved are not in memory and are discarded by a retranslation.
+func SaveFileSegments(projectID int, language, sourcePath string, segments []SegmentTranslation) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO translation_segments (project_id, language, source_path, segment_index, context, source_hash, source_text, target_text, origin, placeholders, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $11)
+		ON CONFLICT (project_id, language, source_path, segment_index) DO UPDATE SET
+			context = EXCLUDED.context, source_hash = EXCLUDED.source_hash, source_text = EXCLUDED.source_text,
+			target_text = EXCLUDED.target_text, origin = EXCLUDED.origin, placeholders = EXCLUDED.placeholders,
+			updated_by = NULL, stale_since = NULL, previous_source = NULL, updated_at = EXCLUDED.updated_at`
+	now := time.Now()
+	for _, segment := range segments {
+		placeholders, err := json.Marshal(segment.Placeholders)
+		if err != nil {
+			return err
+		}
+		origin := segment.Origin
+		if origin == "" {
+			origin = OriginMachine
+		}
+		if _, err := tx.Exec(query, projectID, language, sourcePath, segment.Index, segment.Context, segment.SourceHash, segment.Source, segment.Translation, origin, placeholders, now); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM translation_segments WHERE project_id = $1 AND language = $2 AND source_path = $3 AND segment_index >= $4`, projectID, language, sourcePath, len(segments)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetFileSegments returns the stored segments of a file, in document order
+func GetFileSegments(projectID int, language, sourcePath string) ([]SegmentTranslation, error) {
+	query := `SELECT segment_index, context, source_hash, source_text, target_text, origin, placeholders, stale_since, previous_source FROM translation_segments
+		WHERE project_id = $1 AND language = $2 AND source_path = $3 ORDER BY segment_index`
+	rows, err := db.DB.Query(query, projectID, language, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	segments := []SegmentTranslation{}
+	for rows.Next() {
+		var s SegmentTranslation
+		var placeholders []byte
+		var staleSince, previousSource sql.NullString
+		if err := rows.Scan(&s.Index, &s.Context, &s.SourceHash, &s.Source, &s.Translation, &s.Origin, &placeholders, &staleSince, &previousSource); err != nil {
+			return nil, err
+		}
+		s.StaleSince, s.PreviousSource = staleSince.String, previousSource.String
+		if len(placeholders) > 0 {
+			if err := json.Unmarshal(placeholders, &s.Placeholders); err != nil {
+				return nil, err
+			}
+		}
+		segments = append(segments, s)
+	}
+	return segments, rows.Err()
+}
+
+// ApplyCorrections stores an editor's corrections after checking that each one
+// targets an existing segment and keeps its placeholders. Corrected segments are
+// no longer stale.
+func ApplyCorrections(projectID int, language, sourcePath string, corrections []SegmentCorrection, editorID *int) error {
+	segments, err := GetFileSegments(projectID, language, sourcePath)
+	if err != nil {
+		return err
+	}
+	for _, c := range corrections {
+		if c.Index < 0 || c.Index >= len(segments) {
+			return fmt.Errorf("%w: unknown segment %d", ErrInvalidCorrection, c.Index)
+		}
+		if c.Translation == "" {
+			return fmt.Errorf("%w: segment %d has an empty translation", ErrInvalidCorrection, c.Index)
+		}
+		segment := Segment{Index: c.Index, Text: segments[c.Index].Source, Placeholders: segments[c.Index].Placeholders}
+		if err := ValidatePlaceholders(segment, c.Translation); err != nil {
+			return fmt.Errorf("%w: segment %d: %v", ErrInvalidCorrection, c.Index, err)
+		}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE translation_segments SET target_text = $1, origin = $2, updated_by = $3, stale_since = NULL, previous_source = NULL, updated_at = $4
+		WHERE project_id = $5 AND language = $6 AND source_path = $7 AND segment_index = $8`
+	now := time.Now()
+	for _, c := range corrections {
+		if _, err := tx.Exec(query, c.Translation, OriginHuman, editorID, now, projectID, language, sourcePath, c.Index); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// reviewableFile returns the file if it has a translation that can be reviewed
+func reviewableFile(projectID int, language, sourcePath string) (*TranslationFile, error) {
+	file, err := GetTranslationFile(projectID, language, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if !editable(file) {
+		return nil, fmt.Errorf("%w: %s", ErrNotReviewable, file.Status)
+	}
+	return file, nil
+}
+
+// ApproveFile marks a file as reviewed and stores all of its translated segments
+// as human translation memory, so later retranslations reuse them until the
+// source segment changes. It reports whether the file must be rendered again,
+// either because it contains corrections or because it was held back by the
+// quality checks and was never published. Files with stale segments are approved
+// once every stale segment was corrected, and are then rendered from the new source.
+func ApproveFile(memory Memory, projectID int, language, sourcePath string, reviewerID *int, comment string) (bool, error) {
+	file, err := reviewableFile(projectID, language, sourcePath)
+	if err != nil {
+		return false, err
+	}
+
+	segments, err := GetFileSegments(projectID, language, sourcePath)
+	if err != nil {
+		return false, err
+	}
+
+	var entries []MemoryEntry
+	render := file.Status == StatusNeedsReview || file.Status == StatusStale
+	for _, segment := range segments {
+		if segment.StaleSince != "" {
+			return false, fmt.Errorf("%w: segment %d", ErrStaleSegments, segment.Index)
+		}
+		if segment.Translation == "" {
+			continue
+		}
+		if segment.Origin == OriginHuman {
+			render = true
+		}
+		entries = append(entries, MemoryEntry{
+			SourceHash: segment.SourceHash,
+			SourceText: segment.Source,
+			TargetText: segment.Translation,
+			Origin:     OriginHuman,
+		})
+	}
+	if err := memory.Store(projectID, language, entries); err != nil {
+		return false, fmt.Errorf("failed to store translation memory: %w", err)
+	}
+
+	if err := MarkFileReviewed(projectID, language, sourcePath, reviewerID, comment); err != nil {
+		return false, err
+	}
+	return render, nil
+}
+
+// RejectFile sends a file back for translation. The machine translations of its
+// segments are dropped from translation memory so they are not reused.
+func RejectFile(memory Memory, projectID int, language, sourcePath string, reviewerID *int, comment string) error {
+	if _, err := reviewableFile(projectID, language, sourcePath); err != nil {
+		return err
+	}
+
+	segments, err := GetFileSegments(projectID, language, sourcePath)
+	if err != nil {
+		return err
+	}
+
+	var hashes []string
+	for _, segment := range segments {
+		if segment.Origin != OriginHuman {
+			hashes = append(hashes, segment.SourceHash)
+		}
+	}
+	if err := memory.Forget(projectID, language, hashes); err != nil {
+		return fmt.Errorf("failed to update translation memory: %w", err)
+	}
+
+	return MarkFileRejected(projectID, language, sourcePath, reviewerID, comment)
+}
+
+// EnqueueFileTranslation publishes a translate_files task for a single file
+func EnqueueFileTranslation(cfg *config.Config, projectID int, language, sourcePath string) error {
+	payload := map[string]interface{}{
+		"projectId": projectID,
+		"language":  language,
+		"files":     []string{sourcePath},
+	}
+	id := fmt.Sprintf("review-%d-%s-%d", projectID, language, time.Now().UnixNano())
+	return queue.PublishTask(cfg, "translate_files", "translate_files", id, payload)
+}
@@ -0,0 +1,174 @@
+package translation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReviewPath(t *testing.T) {
+	projectID, language, action, err := parseReviewPath("/projects/4/translations/pt-BR/approve")
+	require.NoError(t, err)
+	require.Equal(t, 4, projectID)
+	require.Equal(t, "pt-BR", language)
+	require.Equal(t, "approve", action)
+
+	_, _, _, err = parseReviewPath("/projects/4/translations")
+	require.Error(t, err)
+	_, _, _, err = parseReviewPath("/projects/x/translations/es/segments")
+	require.Error(t, err)
+}
+
+// translatedTestFile translates content into a tracked file of a new project,
+// the way the translation service and a successful commit record it
+func translatedTestFile(t *testing.T, pipeline *Pipeline, content string) (int, *TranslateDocumentResponse) {
+	projectID := createTestProject(t)
+	require.NoError(t, MarkFilePending(projectID, "es", "docs/intro.md", GitBlobHash([]byte(content))))
+	return projectID, retranslateTestFile(t, pipeline, projectID, content)
+}
+
+// retranslateTestFile translates docs/intro.md again and stores its segments
+func retranslateTestFile(t *testing.T, pipeline *Pipeline, projectID int, content string) *TranslateDocumentResponse {
+	require.NoError(t, MarkFileTranslating(projectID, "es", "docs/intro.md", GitBlobHash([]byte(content))))
+	result, err := pipeline.TranslateDocument(context.Background(), TranslateDocumentRequest{
+		ProjectID: projectID, SourceLanguage: "en", TargetLanguage: "es", Path: "docs/intro.md", Content: content,
+	})
+	require.NoError(t, err)
+	_, err = RecordFileTranslation(projectID, "es", "docs/intro.md", GitBlobHash([]byte(result.Content)), result.Provider, true)
+	require.NoError(t, err)
+	require.NoError(t, MarkFileTranslated(projectID, "es", "docs/intro.md", ""))
+	require.NoError(t, SaveFileSegments(projectID, "es", "docs/intro.md", result.Translations))
+	return result
+}
+
+func TestApplyCorrections(t *testing.T) {
+	useTestDatabase(t)
+	pipeline := &Pipeline{Translator: NewStubProvider(), Memory: NewPostgresMemory()}
+	projectID, _ := translatedTestFile(t, pipeline, "Run `make` to install it.\n\nThen start it.\n")
+
+	segments, err := GetFileSegments(projectID, "es", "docs/intro.md")
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+	require.Len(t, segments[0].Placeholders, 1)
+
+	err = ApplyCorrections(projectID, "es", "docs/intro.md", []SegmentCorrection{{Index: 2, Translation: "Hola."}}, nil)
+	require.ErrorIs(t, err, ErrInvalidCorrection)
+	err = ApplyCorrections(projectID, "es", "docs/intro.md", []SegmentCorrection{{Index: 0, Translation: "Ejecuta make para instalarlo."}}, nil)
+	require.ErrorIs(t, err, ErrInvalidCorrection)
+
+	// A failed correction leaves every segment unchanged
+	err = ApplyCorrections(projectID, "es", "docs/intro.md", []SegmentCorrection{
+		{Index: 1, Translation: "Luego inícialo."},
+		{Index: 1, Translation: ""},
+	}, nil)
+	require.ErrorIs(t, err, ErrInvalidCorrection)
+	unchanged, err := GetFileSegments(projectID, "es", "docs/intro.md")
+	require.NoError(t, err)
+	require.Equal(t, segments, unchanged)
+
+	editor := 1
+	require.NoError(t, ApplyCorrections(projectID, "es", "docs/intro.md", []SegmentCorrection{
+		{Index: 0, Translation: `Ejecuta <ph id="0"/> para instalarlo.`},
+	}, &editor))
+	corrected, err := GetFileSegments(projectID, "es", "docs/intro.md")
+	require.NoError(t, err)
+	require.Equal(t, `Ejecuta <ph id="0"/> para instalarlo.`, corrected[0].Translation)
+	require.Equal(t, OriginHuman, corrected[0].Origin)
+	require.Equal(t, segments[1], corrected[1])
+}
+
+func TestApproveFileStoresHumanMemory(t *testing.T) {
+	useTestDatabase(t)
+	memory := NewPostgresMemory()
+	pipeline := &Pipeline{Translator: NewStubProvider(), Memory: memory}
+	projectID, _ := translatedTestFile(t, pipeline, "Hello.\n\nGoodbye.\n")
+
+	require.NoError(t, ApplyCorrections(projectID, "es", "docs/intro.md", []SegmentCorrection{{Index: 0, Translation: "Hola."}}, nil))
+	reviewer := 1
+	render, err := ApproveFile(memory, projectID, "es", "docs/intro.md", &reviewer, "Looks good")
+	require.NoError(t, err)
+	require.True(t, render)
+
+	// Every translated segment is stored as a human translation
+	segments, err := GetFileSegments(projectID, "es", "docs/intro.md")
+	require.NoError(t, err)
+	entries, err := memory.Exact(projectID, "es", []string{segments[0].SourceHash, segments[1].SourceHash})
+	require.NoError(t, err)
+	require.Equal(t, "Hola.", entries[segments[0].SourceHash].TargetText)
+	require.Equal(t, OriginHuman, entries[segments[0].SourceHash].Origin)
+	require.Equal(t, "[es] Goodbye.", entries[segments[1].SourceHash].TargetText)
+	require.Equal(t, OriginHuman, entries[segments[1].SourceHash].Origin)
+
+	file, err := GetTranslationFile(projectID, "es", "docs/intro.md")
+	require.NoError(t, err)
+	require.Equal(t, StatusReviewed, file.Status)
+	require.Equal(t, &reviewer, file.ReviewedBy)
+
+	// A file that is not translated cannot be approved
+	require.NoError(t, MarkFilePending(projectID, "es", "docs/other.md", "other"))
+	_, err = ApproveFile(memory, projectID, "es", "docs/other.md", &reviewer, "")
+	require.ErrorIs(t, err, ErrNotReviewable)
+}
+
+func TestRejectFileForgetsMachineMemory(t *testing.T) {
+	useTestDatabase(t)
+	memory := NewPostgresMemory()
+	pipeline := &Pipeline{Translator: NewStubProvider(), Memory: memory}
+	projectID, _ := translatedTestFile(t, pipeline, "Hello.\n\nGoodbye.\n")
+
+	// The corrected segment was approved earlier and is a human translation
+	segments, err := GetFileSegments(projectID, "es", "docs/intro.md")
+	require.NoError(t, err)
+	require.NoError(t, memory.Store(projectID, "es", []MemoryEntry{{SourceHash: segments[0].SourceHash, SourceText: "Hello.", TargetText: "Hola.", Origin: OriginHuman}}))
+	require.NoError(t, ApplyCorrections(projectID, "es", "docs/intro.md", []SegmentCorrection{{Index: 0, Translation: "Hola."}}, nil))
+
+	reviewer := 1
+	require.NoError(t, RejectFile(memory, projectID, "es", "docs/intro.md", &reviewer, "Too literal"))
+
+	entries, err := memory.Exact(projectID, "es", []string{segments[0].SourceHash, segments[1].SourceHash})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "Hola.", entries[segments[0].SourceHash].TargetText)
+
+	file, err := GetTranslationFile(projectID, "es", "docs/intro.md")
+	require.NoError(t, err)
+	require.Equal(t, StatusPending, file.Status)
+	require.Equal(t, "Too literal", *file.ReviewComment)
+}
+
+func TestRetranslationKeepsOnlyApprovedCorrections(t *testing.T) {
+	useTestDatabase(t)
+	memory := NewPostgresMemory()
+	pipeline := &Pipeline{Translator: NewStubProvider(), Memory: memory}
+	content := "Hello.\n\nGoodbye.\n"
+	projectID, _ := translatedTestFile(t, pipeline, content)
+
+	// A correction that was never approved is discarded, so the stored segments
+	// still match the rendered file
+	require.NoError(t, ApplyCorrections(projectID, "es", "docs/intro.md", []SegmentCorrection{{Index: 0, Translation: "Hola."}}, nil))
+	result := retranslateTestFile(t, pipeline, projectID, content)
+	require.NotContains(t, result.Content, "Hola.")
+	segments, err := GetFileSegments(projectID, "es", "docs/intro.md")
+	require.NoError(t, err)
+	require.Equal(t, "[es] Hello.", segments[0].Translation)
+	require.Equal(t, OriginMachine, segments[0].Origin)
+
+	// An approved correction survives retranslation while its source is unchanged
+	require.NoError(t, ApplyCorrections(projectID, "es", "docs/intro.md", []SegmentCorrection{{Index: 0, Translation: "Hola."}}, nil))
+	_, err = ApproveFile(memory, projectID, "es", "docs/intro.md", nil, "")
+	require.NoError(t, err)
+	result = retranslateTestFile(t, pipeline, projectID, content)
+	require.Contains(t, result.Content, "Hola.")
+	segments, err = GetFileSegments(projectID, "es", "docs/intro.md")
+	require.NoError(t, err)
+	require.Equal(t, "Hola.", segments[0].Translation)
+	require.Equal(t, OriginHuman, segments[0].Origin)
+
+	// A changed source segment is translated again
+	result = retranslateTestFile(t, pipeline, projectID, "Hello there.\n\nGoodbye.\n")
+	require.NotContains(t, result.Content, "Hola.")
+	segments, err = GetFileSegments(projectID, "es", "docs/intro.md")
+	require.NoError(t, err)
+	require.Equal(t, "[es] Hello there.", segments[0].Translation)
+}
@@ -0,0 +1,240 @@
+package translation
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+)
+
+// ErrStaleSegments is returned when a file is approved while some of its segments are still stale
+var ErrStaleSegments = errors.New("file has stale segments that must be corrected first")
+
+// segmentMatch pairs a segment of a changed source with the stored segment it replaces
+type segmentMatch struct {
+	previous int  // index of the stored segment, or -1 for a new segment
+	changed  bool // the source text of the segment differs from the stored one
+}
+
+// alignSegments matches the segment hashes of a changed source against those of
+// the stored segments. Unchanged segments are found with a longest common
+// subsequence, so inserted and removed paragraphs do not shift them. Between two
+// unchanged segments the remaining segments are paired in order as edits and
+// any extra new segments are additions.
+func alignSegments(previous, current []string) []segmentMatch {
+	n, m := len(previous), len(current)
+	width := m + 1
+	lcs := make([]int32, (n+1)*width)
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if previous[i] == current[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i+1)*width+j], lcs[i*width+j+1])
+			}
+		}
+	}
+
+	matches := make([]segmentMatch, 0, m)
+	gapPrevious, gapCurrent := 0, 0
+	flush := func(i, j int) {
+		for k := 0; gapCurrent+k < j; k++ {
+			if gapPrevious+k < i {
+				matches = append(matches, segmentMatch{previous: gapPrevious + k, changed: true})
+			} else {
+				matches = append(matches, segmentMatch{previous: -1, changed: true})
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case previous[i] == current[j] && lcs[i*width+j] == lcs[(i+1)*width+j+1]+1:
+			flush(i, j)
+			matches = append(matches, segmentMatch{previous: i})
+			i++
+			j++
+			gapPrevious, gapCurrent = i, j
+		case lcs[(i+1)*width+j] >= lcs[i*width+j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	flush(n, m)
+	return matches
+}
+
+// segmentsStale reports whether a stale file kept its reviewed translation with
+// only the changed segments marked, instead of waiting for a full retranslation
+func (f *TranslationFile) segmentsStale() bool {
+	return f.Status == StatusStale && f.StaleSince != nil
+}
+
+// editable reports whether the segments of a file can be corrected and reviewed
+func editable(f *TranslationFile) bool {
+	return hasTranslation(f.Status) || f.segmentsStale()
+}
+
+// MarkSegmentsStale carries the reviewed translation of a file over to a new
+// version of its source. Unchanged segments keep their approved translation,
+// while edited and new segments are marked stale since commit so an editor can
+// update them. tracked is false when the file has no reviewed translation to
+// compare with, and the caller should send it for translation as usual. stale
+// is false when no segment needs an update and the file only has to be rendered
+// again. Reverting the changes upstream makes a stale file reviewed again.
+func MarkSegmentsStale(projectID int, language, sourcePath, sourceHash, commit string, doc *Document) (tracked, stale bool, err error) {
+	file, err := GetTranslationFile(projectID, language, sourcePath)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	if file.Status != StatusReviewed && !file.segmentsStale() {
+		return false, false, nil
+	}
+	if file.SourceHash != nil && *file.SourceHash == sourceHash {
+		return true, file.segmentsStale(), nil
+	}
+
+	stored, err := GetFileSegments(projectID, language, sourcePath)
+	if err != nil {
+		return false, false, err
+	}
+	if len(stored) == 0 {
+		return false, false, nil
+	}
+
+	previous := make([]string, len(stored))
+	for i, segment := range stored {
+		previous[i] = segment.SourceHash
+	}
+	current := make([]string, len(doc.Segments))
+	for i, segment := range doc.Segments {
+		current[i] = SegmentHash(segment.Text)
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM translation_segments WHERE project_id = $1 AND language = $2 AND source_path = $3`, projectID, language, sourcePath); err != nil {
+		return false, false, err
+	}
+
+	query := `INSERT INTO translation_segments (project_id, language, source_path, segment_index, context, source_hash, source_text, target_text, origin, placeholders, stale_since, previous_source, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $13)`
+	now := time.Now()
+	for i, match := range alignSegments(previous, current) {
+		segment := doc.Segments[i]
+		placeholders, err := json.Marshal(segment.Placeholders)
+		if err != nil {
+			return false, false, err
+		}
+
+		// New segments have no translation yet; edited ones keep the old translation
+		// and the source it was reviewed against, until an editor updates them
+		target, origin := "", OriginMachine
+		var staleSince, previousSource *string
+		if match.previous >= 0 {
+			old := stored[match.previous]
+			target, origin = old.Translation, old.Origin
+			if old.StaleSince != "" {
+				staleSince = &old.StaleSince
+				if old.PreviousSource != "" {
+					previousSource = &old.PreviousSource
+				}
+			}
+			if match.changed && staleSince == nil {
+				staleSince, previousSource = &commit, &old.Source
+			}
+		} else {
+			staleSince = &commit
+		}
+		if staleSince != nil {
+			stale = true
+		}
+
+		if _, err := tx.Exec(query, projectID, language, sourcePath, i, segment.Context, current[i], segment.Text, target, origin, placeholders, staleSince, previousSource, now); err != nil {
+			return false, false, err
+		}
+	}
+
+	query = `UPDATE translation_files SET status = $1, source_hash = $2, stale_since = COALESCE(stale_since, $3), updated_at = $4
+		WHERE project_id = $5 AND language = $6 AND source_path = $7`
+	args := []interface{}{StatusStale, sourceHash, commit, now, projectID, language, sourcePath}
+	if !stale {
+		query = `UPDATE translation_files SET status = $1, source_hash = $2, stale_since = NULL, updated_at = $3
+			WHERE project_id = $4 AND language = $5 AND source_path = $6`
+		args = []interface{}{StatusReviewed, sourceHash, now, projectID, language, sourcePath}
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return false, false, err
+	}
+
+	return true, stale, tx.Commit()
+}
+
+// StaleSegment is a segment of a reviewed file whose source changed upstream
+type StaleSegment struct {
+	Index int `json:"index"`
+	// PreviousSource is the source text the translation was reviewed against, empty for new segments
+	PreviousSource string `json:"previous_source,omitempty"`
+	Source         string `json:"source"`
+	Translation    string `json:"translation"`
+	StaleSince     string `json:"stale_since"`
+}
+
+// StaleFile lists the stale segments of one reviewed file
+type StaleFile struct {
+	SourcePath string         `json:"source_path"`
+	StaleSince string         `json:"stale_since"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	Segments   []StaleSegment `json:"segments"`
+}
+
+// StaleReport lists the reviewed files of a language whose source changed since they were approved
+type StaleReport struct {
+	ProjectID int         `json:"project_id"`
+	Language  string      `json:"language"`
+	Files     []StaleFile `json:"files"`
+	Segments  int         `json:"segments"`
+}
+
+// GetStaleReport returns the reviewed files of a language that have stale segments, by path
+func GetStaleReport(projectID int, language string) (*StaleReport, error) {
+	files, err := queryTranslationFiles(`WHERE project_id = $1 AND language = $2 AND status = $3 AND stale_since IS NOT NULL ORDER BY source_path`, projectID, language, StatusStale)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &StaleReport{ProjectID: projectID, Language: language, Files: []StaleFile{}}
+	for _, file := range files {
+		segments, err := GetFileSegments(projectID, language, file.SourcePath)
+		if err != nil {
+			return nil, err
+		}
+		entry := StaleFile{SourcePath: file.SourcePath, StaleSince: *file.StaleSince, UpdatedAt: file.UpdatedAt, Segments: []StaleSegment{}}
+		for _, segment := range segments {
+			if segment.StaleSince == "" {
+				continue
+			}
+			entry.Segments = append(entry.Segments, StaleSegment{
+				Index:          segment.Index,
+				PreviousSource: segment.PreviousSource,
+				Source:         segment.Source,
+				Translation:    segment.Translation,
+				StaleSince:     segment.StaleSince,
+			})
+		}
+		report.Segments += len(entry.Segments)
+		report.Files = append(report.Files, entry)
+	}
+	return report, nil
+}
@@ -0,0 +1,47 @@
+package translation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlignSegmentsUnchanged(t *testing.T) {
+	matches := alignSegments([]string{"a", "b", "c"}, []string{"a", "b", "c"})
+	require.Equal(t, []segmentMatch{{previous: 0}, {previous: 1}, {previous: 2}}, matches)
+}
+
+func TestAlignSegmentsEdited(t *testing.T) {
+	matches := alignSegments([]string{"a", "b", "c"}, []string{"a", "x", "c"})
+	require.Equal(t, []segmentMatch{{previous: 0}, {previous: 1, changed: true}, {previous: 2}}, matches)
+}
+
+func TestAlignSegmentsInsertedAndRemoved(t *testing.T) {
+	// An inserted paragraph does not shift the segments after it
+	matches := alignSegments([]string{"a", "b"}, []string{"a", "new", "b"})
+	require.Equal(t, []segmentMatch{{previous: 0}, {previous: -1, changed: true}, {previous: 1}}, matches)
+
+	matches = alignSegments([]string{"a", "b", "c"}, []string{"a", "c"})
+	require.Equal(t, []segmentMatch{{previous: 0}, {previous: 2}}, matches)
+}
+
+func TestAlignSegmentsEditsAndAdditions(t *testing.T) {
+	matches := alignSegments([]string{"a", "b", "c"}, []string{"x", "y", "c", "z"})
+	require.Equal(t, []segmentMatch{
+		{previous: 0, changed: true},
+		{previous: 1, changed: true},
+		{previous: 2},
+		{previous: -1, changed: true},
+	}, matches)
+
+	require.Empty(t, alignSegments([]string{"a"}, nil))
+	require.Equal(t, []segmentMatch{{previous: -1, changed: true}}, alignSegments(nil, []string{"a"}))
+}
+
+func TestEditable(t *testing.T) {
+	commit := "abc123"
+	require.True(t, editable(&TranslationFile{Status: StatusReviewed}))
+	require.True(t, editable(&TranslationFile{Status: StatusStale, StaleSince: &commit}))
+	require.False(t, editable(&TranslationFile{Status: StatusStale}))
+	require.False(t, editable(&TranslationFile{Status: StatusPending}))
+}
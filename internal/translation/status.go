@@ -0,0 +1,268 @@
+package translation
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+)
+
+// Translation file statuses
+const (
+	StatusPending     = "pending"
+	StatusTranslating = "translating"
+	StatusTranslated  = "translated"
+	StatusStale       = "stale"
+	StatusFailed      = "failed"
+	StatusReviewed    = "reviewed"
+	// StatusNeedsReview marks a translation that failed quality checks and is not published
+	StatusNeedsReview = "needs_review"
+)
+
+// FileStatuses lists every translation file status, in workflow order
+var FileStatuses = []string{StatusPending, StatusTranslating, StatusTranslated, StatusNeedsReview, StatusStale, StatusFailed, StatusReviewed}
+
+// TranslationFile records the translation state of one source file in one language
+type TranslationFile struct {
+	ID             int        `json:"id"`
+	ProjectID      int        `json:"project_id"`
+	Language       string     `json:"language"`
+	SourcePath     string     `json:"source_path"`
+	SourceHash     *string    `json:"source_hash"`
+	TranslatedHash *string    `json:"translated_hash"`
+	Status         string     `json:"status"`
+	Provider       *string    `json:"provider"`
+	Error          *string    `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	TranslatedAt   *time.Time `json:"translated_at"`
+	ReviewedBy     *int       `json:"reviewed_by"`
+	ReviewedAt     *time.Time `json:"reviewed_at"`
+	ReviewComment  *string    `json:"review_comment,omitempty"`
+	// StaleSince is the source commit since which a reviewed file has stale segments
+	StaleSince *string `json:"stale_since,omitempty"`
+}
+
+// TranslationFileFilter narrows GetTranslationFiles; empty fields match everything
+type TranslationFileFilter struct {
+	Language   string
+	Status     string
+	PathPrefix string
+}
+
+// GitBlobHash returns the git object ID of content, so hashes match the repository
+func GitBlobHash(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// IsFileStatus reports whether status is a known translation file status
+func IsFileStatus(status string) bool {
+	for _, s := range FileStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTranslation reports whether a file in this status has a finished translation
+func hasTranslation(status string) bool {
+	return status == StatusTranslated || status == StatusNeedsReview || status == StatusReviewed
+}
+
+// MarkFilePending records that a source file needs translation. Files that were
+// translated or reviewed from a different source version become stale instead,
+// and files held for review keep that status while their source is unchanged.
+func MarkFilePending(projectID int, language, sourcePath, sourceHash string) error {
+	query := `INSERT INTO translation_files (project_id, language, source_path, source_hash, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (project_id, language, source_path) DO UPDATE SET
+			status = CASE
+				WHEN translation_files.source_hash IS NOT DISTINCT FROM EXCLUDED.source_hash AND translation_files.status IN ($7, $8, $10) THEN translation_files.status
+				WHEN translation_files.status IN ($7, $8) THEN $9
+				ELSE EXCLUDED.status
+			END,
+			source_hash = EXCLUDED.source_hash,
+			updated_at = EXCLUDED.updated_at`
+	_, err := db.DB.Exec(query, projectID, language, sourcePath, sourceHash, StatusPending, time.Now(), StatusTranslated, StatusReviewed, StatusStale, StatusNeedsReview)
+	return err
+}
+
+// MarkFileTranslating records that a translation of the file has started.
+// Reviewed files whose source did not change stay reviewed, since they are
+// rendered again from the approved translations.
+func MarkFileTranslating(projectID int, language, sourcePath, sourceHash string) error {
+	query := `INSERT INTO translation_files (project_id, language, source_path, source_hash, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (project_id, language, source_path) DO UPDATE SET
+			status = CASE
+				WHEN translation_files.status = $7 AND translation_files.source_hash IS NOT DISTINCT FROM EXCLUDED.source_hash THEN translation_files.status
+				ELSE EXCLUDED.status
+			END,
+			source_hash = EXCLUDED.source_hash, error = NULL, updated_at = EXCLUDED.updated_at`
+	_, err := db.DB.Exec(query, projectID, language, sourcePath, sourceHash, StatusTranslating, time.Now(), StatusReviewed)
+	return err
+}
+
+// RecordFileTranslation records a finished translation and returns the new
+// status. Files that failed quality checks are held for review, unless already
+// reviewed. Other files stay translating until the translation is committed to
+// the language branch and MarkFileTranslated is called.
+func RecordFileTranslation(projectID int, language, sourcePath, translatedHash, provider string, passedQA bool) (string, error) {
+	status := StatusTranslating
+	if !passedQA {
+		status = StatusNeedsReview
+	}
+	now := time.Now()
+	query := `UPDATE translation_files SET status = CASE WHEN status = $8 THEN status ELSE $1 END,
+			translated_hash = $2, provider = $3, error = NULL, stale_since = NULL, translated_at = $4, updated_at = $4
+		WHERE project_id = $5 AND language = $6 AND source_path = $7
+		RETURNING status`
+	err := db.DB.QueryRow(query, status, translatedHash, provider, now, projectID, language, sourcePath, StatusReviewed).Scan(&status)
+	return status, err
+}
+
+// MarkFileTranslated records that the translation with translatedHash was
+// committed to the language branch. Files translated again since, and reviewed
+// files, keep their status. An empty translatedHash matches any translation.
+func MarkFileTranslated(projectID int, language, sourcePath, translatedHash string) error {
+	query := `UPDATE translation_files SET status = $1, updated_at = $2
+		WHERE project_id = $3 AND language = $4 AND source_path = $5 AND status = $6 AND ($7 = '' OR translated_hash = $7)`
+	_, err := db.DB.Exec(query, StatusTranslated, time.Now(), projectID, language, sourcePath, StatusTranslating, translatedHash)
+	return err
+}
+
+// MarkFileCommitFailed records that a translation could not be committed to the
+// language branch. Only files still waiting for their commit are marked failed.
+func MarkFileCommitFailed(projectID int, language, sourcePath, message string) error {
+	query := `UPDATE translation_files SET status = $1, error = $2, updated_at = $3
+		WHERE project_id = $4 AND language = $5 AND source_path = $6 AND status = $7`
+	_, err := db.DB.Exec(query, StatusFailed, message, time.Now(), projectID, language, sourcePath, StatusTranslating)
+	return err
+}
+
+// MarkFileReviewed records an editor's approval of a file
+func MarkFileReviewed(projectID int, language, sourcePath string, reviewerID *int, comment string) error {
+	now := time.Now()
+	query := `UPDATE translation_files SET status = $1, reviewed_by = $2, reviewed_at = $3, review_comment = $4, stale_since = NULL, updated_at = $3
+		WHERE project_id = $5 AND language = $6 AND source_path = $7`
+	_, err := db.DB.Exec(query, StatusReviewed, reviewerID, now, comment, projectID, language, sourcePath)
+	return err
+}
+
+// MarkFileRejected sends a file back to pending with the reviewer's comment
+func MarkFileRejected(projectID int, language, sourcePath string, reviewerID *int, comment string) error {
+	now := time.Now()
+	query := `UPDATE translation_files SET status = $1, reviewed_by = $2, reviewed_at = $3, review_comment = $4, stale_since = NULL, updated_at = $3
+		WHERE project_id = $5 AND language = $6 AND source_path = $7`
+	_, err := db.DB.Exec(query, StatusPending, reviewerID, now, comment, projectID, language, sourcePath)
+	return err
+}
+
+// MarkFileFailed records a failed translation with its error
+func MarkFileFailed(projectID int, language, sourcePath, message string) error {
+	query := `UPDATE translation_files SET status = $1, error = $2, updated_at = $3
+		WHERE project_id = $4 AND language = $5 AND source_path = $6`
+	_, err := db.DB.Exec(query, StatusFailed, message, time.Now(), projectID, language, sourcePath)
+	return err
+}
+
+// RenameTranslationFiles moves the records, segments and findings of a renamed source file in every language
+func RenameTranslationFiles(projectID int, from, to string) error {
+	now := time.Now()
+	if _, err := db.DB.Exec(`UPDATE translation_files SET source_path = $1, updated_at = $2 WHERE project_id = $3 AND source_path = $4`, to, now, projectID, from); err != nil {
+		return err
+	}
+	if _, err := db.DB.Exec(`UPDATE translation_qa_findings SET source_path = $1 WHERE project_id = $2 AND source_path = $3`, to, projectID, from); err != nil {
+		return err
+	}
+	_, err := db.DB.Exec(`UPDATE translation_segments SET source_path = $1, updated_at = $2 WHERE project_id = $3 AND source_path = $4`, to, now, projectID, from)
+	return err
+}
+
+// DeleteTranslationFiles removes the records, segments and findings of a deleted source file in every language
+func DeleteTranslationFiles(projectID int, sourcePath string) error {
+	if _, err := db.DB.Exec(`DELETE FROM translation_qa_findings WHERE project_id = $1 AND source_path = $2`, projectID, sourcePath); err != nil {
+		return err
+	}
+	if _, err := db.DB.Exec(`DELETE FROM translation_segments WHERE project_id = $1 AND source_path = $2`, projectID, sourcePath); err != nil {
+		return err
+	}
+	_, err := db.DB.Exec(`DELETE FROM translation_files WHERE project_id = $1 AND source_path = $2`, projectID, sourcePath)
+	return err
+}
+
+// GetTranslationFile returns the record of one file and language
+func GetTranslationFile(projectID int, language, sourcePath string) (*TranslationFile, error) {
+	files, err := queryTranslationFiles(`WHERE project_id = $1 AND language = $2 AND source_path = $3`, projectID, language, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &files[0], nil
+}
+
+// GetTranslationFiles lists the translation records of a project
+func GetTranslationFiles(projectID int, filter TranslationFileFilter) ([]TranslationFile, error) {
+	conditions := []string{"project_id = $1"}
+	args := []interface{}{projectID}
+	if filter.Language != "" {
+		args = append(args, filter.Language)
+		conditions = append(conditions, fmt.Sprintf("language = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.PathPrefix != "" {
+		args = append(args, escapeLike(filter.PathPrefix)+"%")
+		conditions = append(conditions, fmt.Sprintf("source_path LIKE $%d", len(args)))
+	}
+	return queryTranslationFiles("WHERE "+strings.Join(conditions, " AND ")+" ORDER BY source_path, language", args...)
+}
+
+func queryTranslationFiles(where string, args ...interface{}) ([]TranslationFile, error) {
+	query := `SELECT id, project_id, language, source_path, source_hash, translated_hash, status, provider, error, created_at, updated_at, translated_at, reviewed_by, reviewed_at, review_comment, stale_since FROM translation_files ` + where
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []TranslationFile{}
+	for rows.Next() {
+		var f TranslationFile
+		err := rows.Scan(&f.ID, &f.ProjectID, &f.Language, &f.SourcePath, &f.SourceHash, &f.TranslatedHash, &f.Status, &f.Provider, &f.Error, &f.CreatedAt, &f.UpdatedAt, &f.TranslatedAt, &f.ReviewedBy, &f.ReviewedAt, &f.ReviewComment, &f.StaleSince)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// escapeLike escapes LIKE wildcards so a path prefix matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// CoverageSummary counts files per language and status
+func CoverageSummary(files []TranslationFile) map[string]map[string]int {
+	summary := make(map[string]map[string]int)
+	for _, f := range files {
+		if summary[f.Language] == nil {
+			summary[f.Language] = make(map[string]int)
+		}
+		summary[f.Language][f.Status]++
+	}
+	return summary
+}
@@ -0,0 +1,28 @@
+package translation
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitBlobHashMatchesGit(t *testing.T) {
+	content := []byte("# Hello\n\nWorld\n")
+	require.Equal(t, plumbing.ComputeHash(plumbing.BlobObject, content).String(), GitBlobHash(content))
+	require.Equal(t, "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391", GitBlobHash(nil))
+}
+
+func TestCoverageSummary(t *testing.T) {
+	files := []TranslationFile{
+		{Language: "es", Status: StatusTranslated},
+		{Language: "es", Status: StatusPending},
+		{Language: "fr", Status: StatusTranslated},
+		{Language: "es", Status: StatusTranslated},
+	}
+	require.Equal(t, map[string]map[string]int{
+		"es": {StatusTranslated: 2, StatusPending: 1},
+		"fr": {StatusTranslated: 1},
+	}, CoverageSummary(files))
+	require.False(t, IsFileStatus("done"))
+}
@@ -0,0 +1,746 @@
+package translation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// localeDirectories are path segments that mark a directory of UI string catalogs.
+// JSON, YAML and TOML files are only translated below one of them, so configuration
+// files such as package.json or mkdocs.yml are never touched.
+var localeDirectories = map[string]bool{
+	"i18n":         true,
+	"locales":      true,
+	"locale":       true,
+	"lang":         true,
+	"langs":        true,
+	"translations": true,
+	"messages":     true,
+}
+
+// structuredParsers maps the extensions of i18n catalogs to their parser
+var structuredParsers = map[string]func(content string) (*Document, error){
+	".json": ParseJSONCatalog,
+	".yml":  ParseYAMLCatalog,
+	".yaml": ParseYAMLCatalog,
+	".toml": ParseTOMLCatalog,
+}
+
+// maxContextLength is the size of the segment context column
+const maxContextLength = 100
+
+// isLocalePath reports whether a file lives inside a locale directory
+func isLocalePath(p string) bool {
+	dirs := strings.Split(path.Dir(strings.ReplaceAll(p, "\\", "/")), "/")
+	for _, dir := range dirs {
+		if localeDirectories[strings.ToLower(dir)] {
+			return true
+		}
+	}
+	return false
+}
+
+// keyContext builds the segment context of a catalog value from its key path
+func keyContext(keys []string) string {
+	context := "key:" + strings.Join(keys, ".")
+	if len(context) <= maxContextLength {
+		return context
+	}
+	context = context[:maxContextLength]
+	for !utf8.ValidString(context) {
+		context = context[:len(context)-1]
+	}
+	return context
+}
+
+func identity(s string) string {
+	return s
+}
+
+// ParseJSONCatalog extracts the string values of a JSON message catalog. Keys, numbers
+// and formatting are protected, and // or /* */ comments are tolerated and kept.
+// In objects that have a "message" string, as in Docusaurus and Chrome catalogs,
+// only the message is translated and siblings such as "description" are kept.
+func ParseJSONCatalog(content string) (*Document, error) {
+	s := &jsonScanner{src: content}
+	s.skipSpace()
+	if err := s.value(nil); err != nil {
+		return nil, err
+	}
+	s.skipSpace()
+	if s.pos != len(s.src) {
+		return nil, s.errorf("unexpected data after the top-level value")
+	}
+
+	b := newDocBuilder()
+	last := 0
+	for _, str := range s.strings {
+		b.protect(content[last : str.start+1])
+		b.translateMessage(content[str.start+1:str.end-1], keyContext(str.keys), decodeJSONString, encodeJSONString)
+		last = str.end - 1
+	}
+	b.protect(content[last:])
+	return b.doc, nil
+}
+
+// jsonString is the position of a translatable string literal, quotes included
+type jsonString struct {
+	start, end int
+	keys       []string
+}
+
+type jsonScanner struct {
+	src     string
+	pos     int
+	strings []jsonString
+}
+
+func (s *jsonScanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid JSON at offset %d: %s", s.pos, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips whitespace and comments
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.src) {
+		switch c := s.src[s.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			s.pos++
+		case strings.HasPrefix(s.src[s.pos:], "//"):
+			idx := strings.IndexByte(s.src[s.pos:], '\n')
+			if idx < 0 {
+				s.pos = len(s.src)
+			} else {
+				s.pos += idx
+			}
+		case strings.HasPrefix(s.src[s.pos:], "/*"):
+			idx := strings.Index(s.src[s.pos+2:], "*/")
+			if idx < 0 {
+				s.pos = len(s.src)
+			} else {
+				s.pos += idx + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+// literal scans a string literal and returns its bounds
+func (s *jsonScanner) literal() (int, int, error) {
+	start := s.pos
+	for i := start + 1; i < len(s.src); i++ {
+		switch s.src[i] {
+		case '\\':
+			i++
+		case '"':
+			s.pos = i + 1
+			return start, s.pos, nil
+		case '\n':
+			return 0, 0, s.errorf("unterminated string")
+		}
+	}
+	return 0, 0, s.errorf("unterminated string")
+}
+
+func (s *jsonScanner) value(keys []string) error {
+	if s.pos >= len(s.src) {
+		return s.errorf("unexpected end of input")
+	}
+	switch c := s.src[s.pos]; {
+	case c == '{':
+		return s.object(keys)
+	case c == '[':
+		return s.array(keys)
+	case c == '"':
+		start, end, err := s.literal()
+		if err != nil {
+			return err
+		}
+		s.strings = append(s.strings, jsonString{start: start, end: end, keys: keys})
+		return nil
+	default:
+		// Numbers, booleans and null
+		start := s.pos
+		for s.pos < len(s.src) && !strings.ContainsRune(" \t\r\n,]}/", rune(s.src[s.pos])) {
+			s.pos++
+		}
+		if s.pos == start {
+			return s.errorf("unexpected %q", s.src[s.pos])
+		}
+		return nil
+	}
+}
+
+func (s *jsonScanner) object(keys []string) error {
+	type member struct {
+		key      string
+		isString bool
+		from     int
+	}
+	var members []member
+
+	s.pos++
+	for {
+		s.skipSpace()
+		if s.pos < len(s.src) && s.src[s.pos] == '}' {
+			s.pos++
+			break
+		}
+		if s.pos >= len(s.src) || s.src[s.pos] != '"' {
+			return s.errorf("expected an object key")
+		}
+		start, end, err := s.literal()
+		if err != nil {
+			return err
+		}
+		key := decodeJSONString(s.src[start+1 : end-1])
+
+		s.skipSpace()
+		if s.pos >= len(s.src) || s.src[s.pos] != ':' {
+			return s.errorf("expected ':' after object key")
+		}
+		s.pos++
+		s.skipSpace()
+
+		from := len(s.strings)
+		isString := s.pos < len(s.src) && s.src[s.pos] == '"'
+		if err := s.value(append(keys[:len(keys):len(keys)], key)); err != nil {
+			return err
+		}
+		members = append(members, member{key: key, isString: isString, from: from})
+
+		s.skipSpace()
+		if s.pos < len(s.src) && s.src[s.pos] == ',' {
+			s.pos++
+			continue
+		}
+		if s.pos < len(s.src) && s.src[s.pos] == '}' {
+			s.pos++
+			break
+		}
+		return s.errorf("expected ',' or '}'")
+	}
+
+	// Keep only "message" among the string members of a message descriptor
+	descriptor := false
+	for _, m := range members {
+		if m.key == "message" && m.isString {
+			descriptor = true
+		}
+	}
+	if descriptor {
+		var kept []jsonString
+		for i, m := range members {
+			to := len(s.strings)
+			if i+1 < len(members) {
+				to = members[i+1].from
+			}
+			if !m.isString || m.key == "message" {
+				kept = append(kept, s.strings[m.from:to]...)
+			}
+		}
+		s.strings = append(s.strings[:members[0].from], kept...)
+	}
+	return nil
+}
+
+func (s *jsonScanner) array(keys []string) error {
+	s.pos++
+	for i := 0; ; i++ {
+		s.skipSpace()
+		if s.pos < len(s.src) && s.src[s.pos] == ']' {
+			s.pos++
+			return nil
+		}
+		if err := s.value(append(keys[:len(keys):len(keys)], strconv.Itoa(i))); err != nil {
+			return err
+		}
+		s.skipSpace()
+		if s.pos < len(s.src) && s.src[s.pos] == ',' {
+			s.pos++
+			continue
+		}
+		if s.pos < len(s.src) && s.src[s.pos] == ']' {
+			s.pos++
+			return nil
+		}
+		return s.errorf("expected ',' or ']'")
+	}
+}
+
+func decodeJSONString(s string) string {
+	var value string
+	if err := json.Unmarshal([]byte(`"`+s+`"`), &value); err != nil {
+		return s
+	}
+	return value
+}
+
+func encodeJSONString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return s
+	}
+	encoded := strings.TrimSuffix(buf.String(), "\n")
+	return encoded[1 : len(encoded)-1]
+}
+
+var (
+	yamlEntryPattern = regexp.MustCompile(`^("(?:[^"\\]|\\.)*"|'(?:[^']|'')*'|[^\s#'"\[\]{},:&*!|>%@` + "`" + `-][^#:]*?|-[^\s#:][^#:]*?)[ \t]*:(?:[ \t]+|$)`)
+	yamlListPattern  = regexp.MustCompile(`^-(?:[ \t]+|$)`)
+	yamlNonString    = regexp.MustCompile(`^(?:~|null|Null|NULL|true|True|TRUE|false|False|FALSE|yes|Yes|YES|no|No|NO|on|On|ON|off|Off|OFF|[-+]?(?:\d[\d_]*(?:\.\d*)?(?:[eE][-+]?\d+)?|\.\d+|\.inf|\.Inf|\.INF|\.nan|\.NaN|\.NAN|0x[0-9a-fA-F]+|0o[0-7]+)|\d{4}-\d\d-\d\d(?:[Tt ][\d:.]+(?:Z|[-+]\d\d(?::\d\d)?)?)?)$`)
+)
+
+// yamlKey is an open mapping key and the column of its first character
+type yamlKey struct {
+	indent int
+	name   string
+}
+
+// ParseYAMLCatalog extracts the string values of a YAML message catalog such as
+// those of Rails, Hugo or vue-i18n. Keys, comments, anchors, flow collections and
+// non-string scalars are protected; block scalars are translated as a whole.
+func ParseYAMLCatalog(content string) (*Document, error) {
+	b := newDocBuilder()
+	lines := splitLines(content)
+	var stack []yamlKey
+
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		trimmed := strings.TrimLeft(l.text, " ")
+		indent := len(l.text) - len(trimmed)
+		if isBlank(trimmed) || trimmed[0] == '#' || trimmed[0] == '%' || strings.HasPrefix(trimmed, "---") || strings.HasPrefix(trimmed, "...") {
+			b.protect(l.text + l.eol)
+			continue
+		}
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		// Nested list items: "- - value" or "- key: value". parent is the column of
+		// the node that owns the value; continuation lines are indented past it.
+		column, parent := indent, indent
+		rest := trimmed
+		for {
+			m := yamlListPattern.FindString(rest)
+			if m == "" {
+				break
+			}
+			stack = append(stack, yamlKey{indent: column, name: "-"})
+			parent = column
+			column += len(m)
+			rest = rest[len(m):]
+		}
+
+		if m := yamlEntryPattern.FindStringSubmatch(rest); m != nil {
+			stack = append(stack, yamlKey{indent: column, name: yamlKeyName(m[1])})
+			parent = column
+			column += len(m[0])
+			rest = rest[len(m[0]):]
+		}
+
+		keys := make([]string, 0, len(stack))
+		for _, k := range stack {
+			if k.name != "-" {
+				keys = append(keys, k.name)
+			}
+		}
+
+		b.protect(l.text[:column])
+		i = yamlValue(b, lines, i, parent, rest, keyContext(keys))
+	}
+	return b.doc, nil
+}
+
+func yamlKeyName(raw string) string {
+	switch raw[0] {
+	case '"':
+		if key, err := strconv.Unquote(raw); err == nil {
+			return key
+		}
+	case '\'':
+		return strings.ReplaceAll(raw[1:len(raw)-1], "''", "'")
+	}
+	return raw
+}
+
+// yamlValue handles the value at the end of lines[i], protecting what it cannot
+// translate, and returns the index of the last line it consumed
+func yamlValue(b *docBuilder, lines []line, i, indent int, value, context string) int {
+	l := lines[i]
+	switch {
+	case value == "" || value[0] == '#':
+		b.protect(value + l.eol)
+		return i
+
+	case value[0] == '"' || value[0] == '\'':
+		end := closingQuote(value)
+		if end < 0 {
+			// Multi-line quoted scalars are kept as they are
+			b.protect(value + l.eol)
+			for i+1 < len(lines) && closingQuote(string(value[0])+lines[i+1].text) < 0 {
+				i++
+				b.protect(lines[i].text + lines[i].eol)
+			}
+			if i+1 < len(lines) {
+				i++
+				b.protect(lines[i].text + lines[i].eol)
+			}
+			return i
+		}
+		b.protect(value[:1])
+		if value[0] == '"' {
+			b.translateMessage(value[1:end-1], context, unescapeYAMLDoubleQuoted, escapeYAMLDoubleQuoted)
+		} else {
+			b.translateMessage(value[1:end-1], context,
+				func(s string) string { return strings.ReplaceAll(s, "''", "'") },
+				func(s string) string { return strings.ReplaceAll(s, "'", "''") })
+		}
+		b.protect(value[end-1:] + l.eol)
+		return i
+
+	case value[0] == '|' || value[0] == '>':
+		b.protect(value + l.eol)
+		return yamlBlockScalar(b, lines, i, indent, context)
+
+	case strings.ContainsAny(value[:1], `[{&*!%@`+"`"):
+		b.protect(value + l.eol)
+		return i
+	}
+
+	text, comment := value, ""
+	if idx := strings.Index(value, " #"); idx >= 0 {
+		text, comment = value[:idx], value[idx:]
+	}
+	trimmed := strings.TrimRight(text, " \t")
+	comment = text[len(trimmed):] + comment
+
+	// Plain scalars continued on the next lines are kept as they are
+	if i+1 < len(lines) && !isBlank(lines[i+1].text) {
+		next := lines[i+1].text
+		if nextIndent := len(next) - len(strings.TrimLeft(next, " ")); nextIndent > indent && !strings.HasPrefix(strings.TrimLeft(next, " "), "#") {
+			b.protect(value + l.eol)
+			for i+1 < len(lines) && (isBlank(lines[i+1].text) || len(lines[i+1].text)-len(strings.TrimLeft(lines[i+1].text, " ")) > indent) {
+				i++
+				b.protect(lines[i].text + lines[i].eol)
+			}
+			return i
+		}
+	}
+
+	if yamlNonString.MatchString(trimmed) {
+		b.protect(value + l.eol)
+		return i
+	}
+	b.translateMessage(trimmed, context, identity, quotePlainScalar)
+	b.protect(comment + l.eol)
+	return i
+}
+
+// yamlBlockScalar translates the body of a | or > block scalar as one segment.
+// The translation is re-indented to the column of the original body.
+func yamlBlockScalar(b *docBuilder, lines []line, i, indent int, context string) int {
+	first, last := -1, -1
+	bodyIndent := 0
+	for j := i + 1; j < len(lines); j++ {
+		text := lines[j].text
+		if isBlank(text) {
+			continue
+		}
+		lineIndent := len(text) - len(strings.TrimLeft(text, " "))
+		if lineIndent <= indent {
+			break
+		}
+		if first < 0 {
+			first = j
+			bodyIndent = lineIndent
+		}
+		last = j
+	}
+	if first < 0 {
+		return i
+	}
+
+	for j := i + 1; j < first; j++ {
+		b.protect(lines[j].text + lines[j].eol)
+	}
+
+	eol := lines[first].eol
+	pad := strings.Repeat(" ", bodyIndent)
+	var raw strings.Builder
+	for j := first; j <= last; j++ {
+		if j > first {
+			raw.WriteString(lines[j-1].eol)
+		}
+		raw.WriteString(lines[j].text)
+	}
+	b.protect(pad)
+	b.translateMessage(raw.String()[bodyIndent:], context,
+		func(s string) string {
+			out := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+			for k, text := range out {
+				if len(text) >= bodyIndent && strings.TrimLeft(text[:bodyIndent], " ") == "" {
+					out[k] = text[bodyIndent:]
+				} else {
+					out[k] = strings.TrimLeft(text, " ")
+				}
+			}
+			return strings.Join(out, "\n")
+		},
+		func(s string) string {
+			out := strings.Split(s, "\n")
+			for k := 1; k < len(out); k++ {
+				if out[k] != "" {
+					out[k] = pad + out[k]
+				}
+			}
+			return strings.Join(out, eol)
+		})
+	b.protect(lines[last].eol)
+	return last
+}
+
+// closingQuote returns the end of the quoted scalar that starts s, or -1
+func closingQuote(s string) int {
+	quote := s[0]
+	for j := 1; j < len(s); j++ {
+		switch {
+		case quote == '"' && s[j] == '\\':
+			j++
+		case s[j] == quote:
+			if quote == '\'' && j+1 < len(s) && s[j+1] == '\'' {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return -1
+}
+
+func unescapeYAMLDoubleQuoted(s string) string {
+	if value, err := strconv.Unquote(`"` + s + `"`); err == nil {
+		return value
+	}
+	return unescapeDoubleQuoted(s)
+}
+
+// escapeYAMLDoubleQuoted uses Go escapes, which are all valid in YAML double-quoted scalars
+func escapeYAMLDoubleQuoted(s string) string {
+	quoted := strconv.Quote(s)
+	return quoted[1 : len(quoted)-1]
+}
+
+var (
+	tomlTablePattern = regexp.MustCompile(`^[ \t]*\[\[?[ \t]*([^\]]+?)[ \t]*\]\]?[ \t]*(?:#.*)?$`)
+	tomlEntryPattern = regexp.MustCompile(`^[ \t]*((?:[A-Za-z0-9_-]+|"(?:[^"\\]|\\.)*"|'[^']*')(?:[ \t]*\.[ \t]*(?:[A-Za-z0-9_-]+|"(?:[^"\\]|\\.)*"|'[^']*'))*)[ \t]*=[ \t]*`)
+	tomlKeyPart      = regexp.MustCompile(`[A-Za-z0-9_-]+|"(?:[^"\\]|\\.)*"|'[^']*'`)
+)
+
+// ParseTOMLCatalog extracts the string values of a TOML message catalog such as
+// Hugo's i18n files, where plural forms are tables with one, other, ... keys.
+// Tables, keys, comments and non-string values are protected.
+func ParseTOMLCatalog(content string) (*Document, error) {
+	b := newDocBuilder()
+	lines := splitLines(content)
+	var table []string
+
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		trimmed := strings.TrimSpace(l.text)
+		if trimmed == "" || trimmed[0] == '#' {
+			b.protect(l.text + l.eol)
+			continue
+		}
+		if m := tomlTablePattern.FindStringSubmatch(l.text); m != nil {
+			table = tomlKeyParts(m[1])
+			b.protect(l.text + l.eol)
+			continue
+		}
+
+		m := tomlEntryPattern.FindStringSubmatch(l.text)
+		if m == nil {
+			b.protect(l.text + l.eol)
+			continue
+		}
+		keys := append(table[:len(table):len(table)], tomlKeyParts(m[1])...)
+		context := keyContext(keys)
+		b.protect(m[0])
+		value := l.text[len(m[0]):]
+
+		switch {
+		case strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''"):
+			i = tomlMultiline(b, lines, i, value, context)
+		case value != "" && (value[0] == '"' || value[0] == '\''):
+			end := closingTOMLQuote(value)
+			if end < 0 {
+				b.protect(value + l.eol)
+				continue
+			}
+			b.protect(value[:1])
+			if value[0] == '"' {
+				b.translateMessage(value[1:end-1], context, unescapeTOMLBasic, escapeTOMLBasic)
+			} else {
+				// Literal strings cannot escape quotes, so such translations are dropped
+				b.translateMessage(value[1:end-1], context, identity, func(s string) string {
+					if strings.ContainsAny(s, "'\n") {
+						return value[1 : end-1]
+					}
+					return s
+				})
+			}
+			b.protect(value[end-1:] + l.eol)
+		default:
+			b.protect(value + l.eol)
+		}
+	}
+	return b.doc, nil
+}
+
+func tomlKeyParts(raw string) []string {
+	var keys []string
+	for _, part := range tomlKeyPart.FindAllString(raw, -1) {
+		switch part[0] {
+		case '"':
+			keys = append(keys, unescapeTOMLBasic(part[1:len(part)-1]))
+		case '\'':
+			keys = append(keys, part[1:len(part)-1])
+		default:
+			keys = append(keys, part)
+		}
+	}
+	return keys
+}
+
+// tomlMultiline translates a multi-line basic or literal string
+// and returns the index of the line where it ends
+func tomlMultiline(b *docBuilder, lines []line, i int, value, context string) int {
+	delimiter := value[:3]
+	var body strings.Builder
+	body.WriteString(value[3:])
+	end := i
+	closeAt := strings.Index(value[3:], delimiter)
+	for closeAt < 0 && end+1 < len(lines) {
+		body.WriteString(lines[end].eol)
+		end++
+		offset := body.Len()
+		body.WriteString(lines[end].text)
+		if idx := strings.Index(lines[end].text, delimiter); idx >= 0 {
+			closeAt = offset + idx
+		}
+	}
+	if closeAt < 0 {
+		b.protect(value + lines[i].eol)
+		for j := i + 1; j <= end; j++ {
+			b.protect(lines[j].text + lines[j].eol)
+		}
+		return end
+	}
+
+	text := body.String()
+	// Quotes right before the delimiter belong to the string
+	for closeAt+3 < len(text) && text[closeAt+3] == delimiter[0] {
+		closeAt++
+	}
+	inner, rest := text[:closeAt], text[closeAt:]
+
+	// A newline right after the opening delimiter is trimmed by TOML
+	lead := ""
+	if strings.HasPrefix(inner, "\r\n") {
+		lead, inner = "\r\n", inner[2:]
+	} else if strings.HasPrefix(inner, "\n") {
+		lead, inner = "\n", inner[1:]
+	}
+
+	b.protect(delimiter + lead)
+	if delimiter == `"""` {
+		b.translateMessage(inner, context, unescapeTOMLBasic, func(s string) string {
+			return strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `"""`, `""\"`)
+		})
+	} else {
+		b.translateMessage(inner, context, identity, func(s string) string {
+			if strings.Contains(s, "'''") {
+				return inner
+			}
+			return s
+		})
+	}
+	b.protect(rest + lines[end].eol)
+	return end
+}
+
+// closingTOMLQuote returns the end of the single-line string that starts s, or -1
+func closingTOMLQuote(s string) int {
+	quote := s[0]
+	for j := 1; j < len(s); j++ {
+		switch {
+		case quote == '"' && s[j] == '\\':
+			j++
+		case s[j] == quote:
+			return j + 1
+		}
+	}
+	return -1
+}
+
+func unescapeTOMLBasic(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'b':
+			sb.WriteByte('\b')
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'e':
+			sb.WriteByte(0x1b)
+		case ' ', '\t', '\r', '\n':
+			// Line ending backslash: trim the newline and the following whitespace
+			for i+1 < len(s) && strings.ContainsRune(" \t\r\n", rune(s[i+1])) {
+				i++
+			}
+		case 'u', 'U':
+			size := 4
+			if c == 'U' {
+				size = 8
+			}
+			if i+size < len(s) {
+				if code, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32); err == nil {
+					sb.WriteRune(rune(code))
+					i += size
+					continue
+				}
+			}
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func escapeTOMLBasic(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s)
+}
@@ -0,0 +1,182 @@
+package translation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const sampleJSONCatalog = `{
+  // Docusaurus theme strings
+  "theme.footer.copyright": {
+    "message": "Copyright © {year} <b>Xeo</b>",
+    "description": "The footer copyright"
+  },
+  "cart.items": "{count, plural, =0 {No items} one {# item} other {# items}}",
+  "greeting": "Hello, {{name}}! You have %d new \"messages\"",
+  "nested": {"list": ["First", "Second", 3, true, null]},
+  "url": "https://example.com/docs"
+}
+`
+
+const sampleYAMLCatalog = `# Rails style catalog
+en:
+  welcome: Welcome to Xeo # shown on the home page
+  count: 3
+  enabled: true
+  quoted: "Save \"draft\"\n"
+  single: 'It''s ready'
+  anchor: &default Default text
+  items:
+    - First item
+    - label: Second item
+      hint: Shown below
+  notice: |
+    Your changes were saved.
+    Reload to see them.
+  tags: [a, b]
+`
+
+const sampleTOMLCatalog = `# Hugo i18n
+[readingTime]
+one = "One minute to read"
+other = "{{ .Count }} minutes to read"
+
+[home]
+title = 'Welcome'  # greeting
+weight = 10
+body = """
+Read the "docs"
+to get started."""
+`
+
+func TestStructuredCatalogsRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		path    string
+		content string
+	}{
+		{"i18n/en/code.json", sampleJSONCatalog},
+		{"config/locales/en.yml", sampleYAMLCatalog},
+		{"i18n/en.toml", sampleTOMLCatalog},
+	} {
+		doc, err := ParseDocument(tc.path, tc.content)
+		require.NoError(t, err, tc.path)
+		require.Equal(t, tc.content, doc.Render(nil), tc.path)
+		require.Equal(t, tc.content, doc.Render(doc.Texts()), tc.path)
+	}
+}
+
+func TestParseJSONCatalog(t *testing.T) {
+	doc, err := ParseJSONCatalog(sampleJSONCatalog)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		`Copyright © <ph id="0"/> <ph id="1"/>Xeo<ph id="2"/>`,
+		`<ph id="0"/>No items<ph id="1"/> item<ph id="2"/> items<ph id="3"/>`,
+		`Hello, <ph id="0"/>! You have <ph id="1"/> new "messages"`,
+		"First",
+		"Second",
+	}, doc.Texts())
+	require.Equal(t, "key:theme.footer.copyright.message", doc.Segments[0].Context)
+	require.Equal(t, []string{"{count, plural, =0 {", "} one {#", "} other {#", "}}"}, doc.Segments[1].Placeholders)
+	require.Equal(t, "key:nested.list.1", doc.Segments[4].Context)
+
+	rendered := doc.Render([]string{
+		"",
+		`<ph id="0"/>Ningún artículo<ph id="1"/> artículo<ph id="2"/> artículos<ph id="3"/>`,
+		`¡Hola, <ph id="0"/>! Tienes <ph id="1"/> "mensajes" nuevos`,
+		"",
+		"",
+	})
+	require.Contains(t, rendered, `"cart.items": "{count, plural, =0 {Ningún artículo} one {# artículo} other {# artículos}}",`)
+	require.Contains(t, rendered, `"greeting": "¡Hola, {{name}}! Tienes %d \"mensajes\" nuevos",`)
+	require.Contains(t, rendered, `"description": "The footer copyright"`)
+	require.Contains(t, rendered, "// Docusaurus theme strings\n")
+}
+
+func TestParseJSONCatalogRejectsInvalidJSON(t *testing.T) {
+	_, err := ParseJSONCatalog(`{"a": "b",`)
+	require.Error(t, err)
+}
+
+func TestParseYAMLCatalog(t *testing.T) {
+	doc, err := ParseYAMLCatalog(sampleYAMLCatalog)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"Welcome to Xeo",
+		"Save \"draft\"\n",
+		"It's ready",
+		"First item",
+		"Second item",
+		"Shown below",
+		"Your changes were saved.\nReload to see them.",
+	}, doc.Texts())
+	require.Equal(t, "key:en.welcome", doc.Segments[0].Context)
+	require.Equal(t, "key:en.items.hint", doc.Segments[5].Context)
+
+	rendered := doc.Render([]string{
+		"Bienvenido: Xeo",
+		"Guardar \"borrador\"\n",
+		"Está listo, ¿no?",
+		"",
+		"",
+		"",
+		"Se guardaron los cambios.\nRecarga para verlos.",
+	})
+	require.Contains(t, rendered, "  welcome: \"Bienvenido: Xeo\" # shown on the home page\n")
+	require.Contains(t, rendered, `  quoted: "Guardar \"borrador\"\n"`+"\n")
+	require.Contains(t, rendered, "  single: 'Está listo, ¿no?'\n")
+	require.Contains(t, rendered, "  notice: |\n    Se guardaron los cambios.\n    Recarga para verlos.\n  tags: [a, b]\n")
+	require.Contains(t, rendered, "  anchor: &default Default text\n")
+}
+
+func TestParseTOMLCatalog(t *testing.T) {
+	doc, err := ParseTOMLCatalog(sampleTOMLCatalog)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"One minute to read",
+		`<ph id="0"/> minutes to read`,
+		"Welcome",
+		"Read the \"docs\"\nto get started.",
+	}, doc.Texts())
+	require.Equal(t, "key:readingTime.other", doc.Segments[1].Context)
+
+	rendered := doc.Render([]string{
+		`Un minuto de "lectura"`,
+		`<ph id="0"/> minutos de lectura`,
+		"L'accueil",
+		"Lee la \"documentación\"\npara empezar.",
+	})
+	require.Contains(t, rendered, `one = "Un minuto de \"lectura\""`+"\n")
+	require.Contains(t, rendered, `other = "{{ .Count }} minutos de lectura"`+"\n")
+	// Literal strings cannot hold a quote, so the source is kept
+	require.Contains(t, rendered, "title = 'Welcome'  # greeting\n")
+	require.Contains(t, rendered, "body = \"\"\"\nLee la \"documentación\"\npara empezar.\"\"\"\n")
+}
+
+func TestStructuredFilesOnlyInLocaleDirectories(t *testing.T) {
+	require.True(t, IsSupportedFile("i18n/en/code.json"))
+	require.True(t, IsSupportedFile("config/locales/en.yml"))
+	require.True(t, IsSupportedFile("src/lang/en.yaml"))
+	require.False(t, IsSupportedFile("package.json"))
+	require.False(t, IsSupportedFile("mkdocs.yml"))
+	require.False(t, IsSupportedFile("hugo.toml"))
+
+	_, err := ParseDocument("tsconfig.json", "{}")
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestTokenizeMessage(t *testing.T) {
+	tokens := mergeTokens(tokenizeMessage("Click <0>here</0> or see %1$s at https://x.io, {n, select, a {A text} other {Other}}", false))
+	var protected []string
+	for _, token := range tokens {
+		if token.protected {
+			protected = append(protected, token.text)
+		}
+	}
+	require.Equal(t, []string{"<0>", "</0>", "%1$s", "https://x.io", "{n, select, a {", "} other {", "}}"}, protected)
+	require.True(t, strings.Contains(tokens[len(tokens)-2].text, "Other"))
+}
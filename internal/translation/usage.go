@@ -0,0 +1,138 @@
+package translation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/xeodocs/xeodocs-backend/internal/shared/db"
+)
+
+// Pricing is the provider price in USD per million tokens
+type Pricing struct {
+	PromptPerMillion     float64
+	CompletionPerMillion float64
+}
+
+// Cost estimates the price of the tokens in usage
+func (p Pricing) Cost(usage Usage) float64 {
+	return (float64(usage.PromptTokens)*p.PromptPerMillion + float64(usage.CompletionTokens)*p.CompletionPerMillion) / 1e6
+}
+
+// PricedTranslator sets the cost of each result from the prices of the provider
+// that answered it
+type PricedTranslator struct {
+	Translator
+	pricing Pricing
+}
+
+// NewPricedTranslator prices the results of translator with pricing
+func NewPricedTranslator(translator Translator, pricing Pricing) *PricedTranslator {
+	return &PricedTranslator{Translator: translator, pricing: pricing}
+}
+
+func (p *PricedTranslator) Translate(ctx context.Context, req TranslateRequest) (*TranslateResult, error) {
+	result, err := p.Translator.Translate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	result.Cost = p.pricing.Cost(result.Usage)
+	return result, nil
+}
+
+// UsageRecord is the provider usage of one translated document
+type UsageRecord struct {
+	ProjectID  int
+	Language   string
+	JobID      string
+	SourcePath string
+	Provider   string
+	Requests   int
+	Usage      Usage
+	Cost       float64
+}
+
+// UsageTotals sums provider usage over a group of translations
+type UsageTotals struct {
+	Language         string  `json:"language,omitempty"`
+	JobID            string  `json:"job_id,omitempty"`
+	Files            int     `json:"files"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// ProjectUsage is the provider usage of a project in a period, per language and per job
+type ProjectUsage struct {
+	ProjectID int           `json:"project_id"`
+	From      *time.Time    `json:"from,omitempty"`
+	To        *time.Time    `json:"to,omitempty"`
+	Total     UsageTotals   `json:"total"`
+	Languages []UsageTotals `json:"languages"`
+	Jobs      []UsageTotals `json:"jobs"`
+}
+
+// RecordUsage stores the provider usage of one translated document
+func RecordUsage(record UsageRecord) error {
+	var jobID *string
+	if record.JobID != "" {
+		jobID = &record.JobID
+	}
+	query := `INSERT INTO translation_usage (project_id, language, job_id, source_path, provider, requests, prompt_tokens, completion_tokens, cost, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err := db.DB.Exec(query, record.ProjectID, record.Language, jobID, record.SourcePath, record.Provider, record.Requests,
+		record.Usage.PromptTokens, record.Usage.CompletionTokens, record.Cost, time.Now())
+	return err
+}
+
+// GetProjectUsage sums the provider usage of a project, optionally within [from, to)
+func GetProjectUsage(projectID int, from, to *time.Time) (*ProjectUsage, error) {
+	where := "WHERE project_id = $1"
+	args := []interface{}{projectID}
+	if from != nil {
+		args = append(args, *from)
+		where += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if to != nil {
+		args = append(args, *to)
+		where += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+
+	usage := &ProjectUsage{ProjectID: projectID, From: from, To: to}
+	totals, err := queryUsageTotals(`SELECT '', '', `+usageSums+` FROM translation_usage `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(totals) > 0 {
+		usage.Total = totals[0]
+	}
+	if usage.Languages, err = queryUsageTotals(`SELECT language, '', `+usageSums+` FROM translation_usage `+where+` GROUP BY language ORDER BY language`, args...); err != nil {
+		return nil, err
+	}
+	if usage.Jobs, err = queryUsageTotals(`SELECT language, job_id, `+usageSums+` FROM translation_usage `+where+` AND job_id IS NOT NULL
+		GROUP BY language, job_id ORDER BY MIN(created_at) DESC`, args...); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+const usageSums = `COUNT(*), COALESCE(SUM(requests), 0), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(cost), 0)`
+
+func queryUsageTotals(query string, args ...interface{}) ([]UsageTotals, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []UsageTotals{}
+	for rows.Next() {
+		var t UsageTotals
+		if err := rows.Scan(&t.Language, &t.JobID, &t.Files, &t.Requests, &t.PromptTokens, &t.CompletionTokens, &t.Cost); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
@@ -0,0 +1,202 @@
+package translation
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const xliffNamespace = "urn:oasis:names:tc:xliff:document:2.0"
+
+// xliffState maps segment origins to XLIFF 2.0 segment states
+func xliffState(unit ExchangeUnit) string {
+	switch {
+	case unit.Target == "":
+		return "initial"
+	case unit.Origin == OriginHuman:
+		return "reviewed"
+	default:
+		return "translated"
+	}
+}
+
+// EncodeXLIFF writes units as an XLIFF 2.0 document with one <file> per source path.
+// Placeholders become <ph> elements whose disp attribute shows the protected text.
+func EncodeXLIFF(file ExchangeFile) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	fmt.Fprintf(&buf, `<xliff xmlns="%s" version="2.0" srcLang="%s" trgLang="%s">`+"\n", xliffNamespace, xmlEscape(file.SourceLanguage), xmlEscape(file.TargetLanguage))
+
+	fileIndex := 0
+	for i, unit := range file.Units {
+		if i == 0 || unit.Path != file.Units[i-1].Path {
+			if i > 0 {
+				buf.WriteString("  </file>\n")
+			}
+			fileIndex++
+			fmt.Fprintf(&buf, `  <file id="f%d" original="%s">`+"\n", fileIndex, xmlEscape(unit.Path))
+		}
+
+		fmt.Fprintf(&buf, `    <unit id="u%d" name="%s">`+"\n", unit.Index, xmlEscape(unit.UnitID()))
+		buf.WriteString("      <notes>\n")
+		fmt.Fprintf(&buf, `        <note category="source-hash">%s</note>`+"\n", xmlEscape(unit.SourceHash))
+		if unit.Context != "" {
+			fmt.Fprintf(&buf, `        <note category="context">%s</note>`+"\n", xmlEscape(unit.Context))
+		}
+		buf.WriteString("      </notes>\n")
+		fmt.Fprintf(&buf, `      <segment state="%s">`+"\n", xliffState(unit))
+		fmt.Fprintf(&buf, "        <source>%s</source>\n", xliffInline(unit.Source, unit.Placeholders))
+		if unit.Target != "" {
+			fmt.Fprintf(&buf, "        <target>%s</target>\n", xliffInline(unit.Target, unit.Placeholders))
+		}
+		buf.WriteString("      </segment>\n")
+		buf.WriteString("    </unit>\n")
+	}
+	if len(file.Units) > 0 {
+		buf.WriteString("  </file>\n")
+	}
+	buf.WriteString("</xliff>\n")
+	return buf.Bytes(), nil
+}
+
+// xliffInline escapes text and turns placeholders into <ph> elements
+func xliffInline(text string, placeholders []string) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range placeholderPattern.FindAllStringSubmatchIndex(text, -1) {
+		sb.WriteString(xmlEscape(text[last:m[0]]))
+		id, _ := strconv.Atoi(text[m[2]:m[3]])
+		if id < len(placeholders) {
+			fmt.Fprintf(&sb, `<ph id="%d" disp="%s"/>`, id, xmlEscape(placeholders[id]))
+		} else {
+			fmt.Fprintf(&sb, `<ph id="%d"/>`, id)
+		}
+		last = m[1]
+	}
+	sb.WriteString(xmlEscape(text[last:]))
+	return sb.String()
+}
+
+// xmlEscape escapes text for use in XML content and attribute values
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// DecodeXLIFF reads an XLIFF 2.0 document. <ph> elements become placeholders;
+// other inline markup is rejected because it cannot be mapped back to the document.
+func DecodeXLIFF(data []byte) (*ExchangeFile, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	file := &ExchangeFile{}
+
+	var path string
+	var unit *ExchangeUnit
+	var noteCategory string
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XLIFF: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "xliff":
+				if attr(t, "version") != "2.0" {
+					return nil, errors.New("invalid XLIFF: only version 2.0 is supported")
+				}
+				file.SourceLanguage = attr(t, "srcLang")
+				file.TargetLanguage = attr(t, "trgLang")
+			case "file":
+				path = attr(t, "original")
+			case "unit":
+				unit = &ExchangeUnit{Path: path, Index: -1}
+				if name := attr(t, "name"); name != "" {
+					if p, index, err := parseUnitID(name); err == nil {
+						unit.Path, unit.Index = p, index
+					}
+				}
+				if unit.Index < 0 {
+					index, err := strconv.Atoi(strings.TrimPrefix(attr(t, "id"), "u"))
+					if err != nil {
+						return nil, fmt.Errorf("invalid XLIFF: unknown unit ID %q", attr(t, "id"))
+					}
+					unit.Index = index
+				}
+			case "note":
+				noteCategory = attr(t, "category")
+			case "source", "target":
+				if unit == nil {
+					return nil, fmt.Errorf("invalid XLIFF: <%s> outside of a unit", t.Name.Local)
+				}
+				text, err := readXLIFFInline(dec, t.Name.Local)
+				if err != nil {
+					return nil, err
+				}
+				if t.Name.Local == "source" {
+					unit.Source += text
+				} else {
+					unit.Target += text
+				}
+			}
+		case xml.CharData:
+			if unit != nil && noteCategory == "source-hash" {
+				unit.SourceHash += strings.TrimSpace(string(t))
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "note":
+				noteCategory = ""
+			case "unit":
+				file.Units = append(file.Units, *unit)
+				unit = nil
+			}
+		}
+	}
+	return file, nil
+}
+
+// readXLIFFInline reads the content of a <source> or <target> element
+func readXLIFFInline(dec *xml.Decoder, element string) (string, error) {
+	var sb strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", fmt.Errorf("invalid XLIFF: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			sb.Write(t)
+		case xml.StartElement:
+			if t.Name.Local != "ph" {
+				return "", fmt.Errorf("invalid XLIFF: unsupported inline element <%s>", t.Name.Local)
+			}
+			id, err := strconv.Atoi(attr(t, "id"))
+			if err != nil {
+				return "", fmt.Errorf("invalid XLIFF: invalid placeholder ID %q", attr(t, "id"))
+			}
+			sb.WriteString(placeholder(id))
+		case xml.EndElement:
+			if t.Name.Local == element {
+				return sb.String(), nil
+			}
+		}
+	}
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -5,4 +5,5 @@ type Task struct {
 	Type    string                 `json:"type"`    // e.g., "clone_repo", "translate_files", "build_task"
 	Payload map[string]interface{} `json:"payload"`
 	ID      string                 `json:"id"`
+	Attempt int                    `json:"attempt,omitempty"` // retries after the project was busy
 }
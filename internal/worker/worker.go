@@ -3,13 +3,20 @@ package worker
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/rabbitmq/amqp091-go"
 	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
 	"github.com/xeodocs/xeodocs-backend/internal/shared/logging"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/queue"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
+	"github.com/xeodocs/xeodocs-backend/internal/translation"
 )
 
 // Start initializes the worker service and begins consuming messages from RabbitMQ
@@ -81,8 +88,7 @@ func consumeQueue(cfg *config.Config, ch *amqp091.Channel, queueName string) {
 
 			log.Printf("Received task: %s, ID: %s", task.Type, task.ID)
 
-			// Process the task (placeholder)
-			processTask(cfg, task)
+			retryBusyTask(cfg, queueName, task, processTask(cfg, task))
 
 			d.Ack(false) // acknowledge the message
 		}
@@ -92,7 +98,7 @@ func consumeQueue(cfg *config.Config, ch *amqp091.Channel, queueName string) {
 	<-forever
 }
 
-func processTask(cfg *config.Config, task Task) {
+func processTask(cfg *config.Config, task Task) error {
 	log.Printf("Processing task type: %s with payload: %v", task.Type, task.Payload)
 
 	// Log task processing start
@@ -101,27 +107,74 @@ func processTask(cfg *config.Config, task Task) {
 
 	switch task.Type {
 	case "clone_repo":
-		handleCloneRepo(cfg, task.Payload)
+		return handleCloneRepo(cfg, task.Payload)
 	case "create_language_copies":
-		handleCreateLanguageCopies(cfg, task.Payload)
+		return handleCreateLanguageCopies(cfg, task.Payload)
 	case "sync_repo":
-		handleSyncRepo(cfg, task.Payload)
+		return handleSyncRepo(cfg, task.Payload)
+	case "translate_files":
+		handleTranslateFiles(cfg, task)
+	case "commit_translations":
+		return handleCommitTranslations(cfg, task.Payload)
 	case "delete_repo":
-		handleDeleteRepo(cfg, task.Payload)
+		return handleDeleteRepo(cfg, task.Payload)
 	case "build_task":
-		handleBuildTask(cfg, task.Payload)
+		return handleBuildTask(cfg, task.Payload)
 	default:
 		log.Printf("Unknown task type: %s", task.Type)
 	}
+	return nil
 }
 
-func handleCloneRepo(cfg *config.Config, payload map[string]interface{}) {
+// errProjectBusy is returned when another operation holds the lock of the project
+var errProjectBusy = errors.New("project is busy")
+
+// publishDelayedTask is replaced in tests
+var publishDelayedTask = queue.PublishDelayedTask
+
+// retryBusyTask retries a task that found its project busy with another
+// operation. Translations whose commit is given up on are reported as failed.
+func retryBusyTask(cfg *config.Config, queueName string, task Task, err error) {
+	if !errors.Is(err, errProjectBusy) {
+		return
+	}
+	if !retryTask(cfg, queueName, task, cfg.TaskRetryDelay, "project is busy") && task.Type == "commit_translations" {
+		projectID, language, files := commitPayload(task.Payload)
+		reportCommitFailed(cfg, projectID, language, files, err.Error())
+	}
+}
+
+// retryTask publishes a task again after delay, up to cfg.TaskMaxRetries times.
+// reason explains in the activity log why the task is retried. It reports whether
+// the retry was scheduled.
+func retryTask(cfg *config.Config, queueName string, task Task, delay time.Duration, reason string) bool {
+	var projectID *int
+	if id, ok := task.Payload["projectId"].(float64); ok {
+		projectID = new(int)
+		*projectID = int(id)
+	}
+
+	if task.Attempt >= cfg.TaskMaxRetries {
+		message := fmt.Sprintf("Worker gave up on %s after %d retries: %s", task.Type, task.Attempt, reason)
+		logging.LogActivity(cfg.LoggingServiceURL, "worker_task_abandoned", message, nil, projectID, "error")
+		return false
+	}
+	if err := publishDelayedTask(cfg, queueName, task.Type, task.ID, task.Payload, task.Attempt+1, delay); err != nil {
+		log.Printf("Failed to schedule retry of %s: %v", task.ID, err)
+		return false
+	}
+	message := fmt.Sprintf("Worker will retry %s in %s (attempt %d): %s", task.Type, delay, task.Attempt+1, reason)
+	logging.LogActivity(cfg.LoggingServiceURL, "worker_task_delayed", message, nil, projectID, "warning")
+	return true
+}
+
+func handleCloneRepo(cfg *config.Config, payload map[string]interface{}) error {
 	repoURL, ok1 := payload["repoUrl"].(string)
 	projectIDFloat, ok2 := payload["projectId"].(float64)
 
 	if !ok1 || !ok2 {
 		log.Printf("Invalid payload for clone_repo: %v", payload)
-		return
+		return nil
 	}
 
 	projectID := int(projectIDFloat)
@@ -133,20 +186,22 @@ func handleCloneRepo(cfg *config.Config, payload map[string]interface{}) {
 
 	if err := callRepositoryService(cfg, http.MethodPost, "/internal/clone-repo", req); err != nil {
 		log.Printf("Failed to clone repo: %v", err)
-	} else {
-		// Log successful repo cloning
-		message := fmt.Sprintf("Worker successfully cloned repo for project %d", projectID)
-		logging.LogActivity(cfg.LoggingServiceURL, "worker_repo_cloned", message, nil, &projectID, "info")
+		return err
 	}
+
+	// Log successful repo cloning
+	message := fmt.Sprintf("Worker successfully cloned repo for project %d", projectID)
+	logging.LogActivity(cfg.LoggingServiceURL, "worker_repo_cloned", message, nil, &projectID, "info")
+	return nil
 }
 
-func handleCreateLanguageCopies(cfg *config.Config, payload map[string]interface{}) {
+func handleCreateLanguageCopies(cfg *config.Config, payload map[string]interface{}) error {
 	projectIDFloat, ok1 := payload["projectId"].(float64)
 	languagesInterface, ok2 := payload["languages"].([]interface{})
 
 	if !ok1 || !ok2 {
 		log.Printf("Invalid payload for create_language_copies: %v", payload)
-		return
+		return nil
 	}
 
 	projectID := int(projectIDFloat)
@@ -164,19 +219,21 @@ func handleCreateLanguageCopies(cfg *config.Config, payload map[string]interface
 
 	if err := callRepositoryService(cfg, http.MethodPost, "/internal/create-language-copies", req); err != nil {
 		log.Printf("Failed to create language copies: %v", err)
-	} else {
-		// Log successful language copies
-		message := fmt.Sprintf("Worker successfully created language copies for project %d", projectID)
-		logging.LogActivity(cfg.LoggingServiceURL, "worker_language_copies_created", message, nil, &projectID, "info")
+		return err
 	}
+
+	// Log successful language copies
+	message := fmt.Sprintf("Worker successfully created language copies for project %d", projectID)
+	logging.LogActivity(cfg.LoggingServiceURL, "worker_language_copies_created", message, nil, &projectID, "info")
+	return nil
 }
 
-func handleSyncRepo(cfg *config.Config, payload map[string]interface{}) {
+func handleSyncRepo(cfg *config.Config, payload map[string]interface{}) error {
 	projectIDFloat, ok := payload["projectId"].(float64)
 
 	if !ok {
 		log.Printf("Invalid payload for sync_repo: %v", payload)
-		return
+		return nil
 	}
 
 	projectID := int(projectIDFloat)
@@ -187,19 +244,21 @@ func handleSyncRepo(cfg *config.Config, payload map[string]interface{}) {
 
 	if err := callRepositoryService(cfg, http.MethodPut, "/internal/sync-repo", req); err != nil {
 		log.Printf("Failed to sync repo: %v", err)
-	} else {
-		// Log successful repo sync
-		message := fmt.Sprintf("Worker successfully synced repo for project %d", projectID)
-		logging.LogActivity(cfg.LoggingServiceURL, "worker_repo_synced", message, nil, &projectID, "info")
+		return err
 	}
+
+	// Log successful repo sync
+	message := fmt.Sprintf("Worker successfully synced repo for project %d", projectID)
+	logging.LogActivity(cfg.LoggingServiceURL, "worker_repo_synced", message, nil, &projectID, "info")
+	return nil
 }
 
-func handleDeleteRepo(cfg *config.Config, payload map[string]interface{}) {
+func handleDeleteRepo(cfg *config.Config, payload map[string]interface{}) error {
 	projectIDFloat, ok := payload["projectId"].(float64)
 
 	if !ok {
 		log.Printf("Invalid payload for delete_repo: %v", payload)
-		return
+		return nil
 	}
 
 	projectID := int(projectIDFloat)
@@ -210,20 +269,230 @@ func handleDeleteRepo(cfg *config.Config, payload map[string]interface{}) {
 
 	if err := callRepositoryService(cfg, http.MethodDelete, "/internal/delete-repo", req); err != nil {
 		log.Printf("Failed to delete repo: %v", err)
-	} else {
-		// Log successful repo deletion
-		message := fmt.Sprintf("Worker successfully deleted repo for project %d", projectID)
-		logging.LogActivity(cfg.LoggingServiceURL, "worker_repo_deleted", message, nil, &projectID, "info")
+		return err
 	}
+
+	// Log successful repo deletion
+	message := fmt.Sprintf("Worker successfully deleted repo for project %d", projectID)
+	logging.LogActivity(cfg.LoggingServiceURL, "worker_repo_deleted", message, nil, &projectID, "info")
+	return nil
 }
 
-func handleBuildTask(cfg *config.Config, payload map[string]interface{}) {
+func handleTranslateFiles(cfg *config.Config, task Task) {
+	jobID, payload := task.ID, task.Payload
+	projectIDFloat, ok1 := payload["projectId"].(float64)
+	language, ok2 := payload["language"].(string)
+	filesInterface, ok3 := payload["files"].([]interface{})
+
+	if !ok1 || !ok2 || !ok3 || language == "" {
+		log.Printf("Invalid payload for translate_files: %v", payload)
+		return
+	}
+
+	projectID := int(projectIDFloat)
+	sourceLanguage, _ := payload["sourceLanguage"].(string)
+
+	translated := 0
+	var written, providers []string
+	contents := make(map[string]string)
+	var memory translation.MemoryStats
+	var usage translation.Usage
+	cost := 0.0
+	for i, fileInterface := range filesInterface {
+		file, ok := fileInterface.(string)
+		if !ok {
+			continue
+		}
+
+		result, err := translateFile(cfg, jobID, projectID, sourceLanguage, language, file)
+		var unavailable *unavailableError
+		if errors.As(err, &unavailable) {
+			// Every remaining file would fail the same way, so the rest of the batch,
+			// this file included, is translated again once the provider is back
+			message := fmt.Sprintf("Worker stopped translating to %s: %v", language, err)
+			logging.LogActivity(cfg.LoggingServiceURL, "worker_translation_unavailable", message, nil, &projectID, "error")
+			delay := unavailable.retryAfter
+			if delay <= 0 {
+				delay = cfg.TaskRetryDelay
+			}
+			retryTask(cfg, "translate_files", remainingTask(task, filesInterface[i:]), delay, "translation provider unavailable")
+			break
+		}
+		if err != nil {
+			log.Printf("Failed to translate %s to %s for project %d: %v", file, language, projectID, err)
+			message := fmt.Sprintf("Worker failed to translate %s to %s: %v", file, language, err)
+			logging.LogActivity(cfg.LoggingServiceURL, "worker_file_translation_failed", message, nil, &projectID, "error")
+			continue
+		}
+
+		translated++
+		memory.Segments += result.Memory.Segments
+		memory.ExactHits += result.Memory.ExactHits
+		memory.FuzzyHits += result.Memory.FuzzyHits
+		usage.PromptTokens += result.Usage.PromptTokens
+		usage.CompletionTokens += result.Usage.CompletionTokens
+		cost += result.Cost
+		message := fmt.Sprintf("Worker translated %s to %s", file, language)
+		if len(result.GlossaryViolations) > 0 {
+			message += fmt.Sprintf(" with %d glossary violations", len(result.GlossaryViolations))
+		}
+		level := "info"
+		if result.NeedsReview {
+			message += fmt.Sprintf("; held for review after %d quality findings", len(result.QAFindings))
+			level = "warning"
+		} else {
+			written = append(written, file)
+			contents[file] = result.Content
+			if result.Provider != "" && !slices.Contains(providers, result.Provider) {
+				providers = append(providers, result.Provider)
+			}
+		}
+		logging.LogActivity(cfg.LoggingServiceURL, "worker_file_translated", message, nil, &projectID, level)
+	}
+
+	// The repository service writes the translations to the language worktree and
+	// commits them while it holds the project lock, then pushes the branch to the
+	// project's remote
+	if len(written) > 0 {
+		req := map[string]interface{}{
+			"projectId": projectID,
+			"language":  language,
+			"files":     written,
+			"contents":  contents,
+			"providers": providers,
+		}
+		err := callRepositoryService(cfg, http.MethodPost, "/internal/commit-translations", req)
+		if errors.Is(err, errProjectBusy) {
+			// The translations are kept in the task and committed once the project is free
+			id := fmt.Sprintf("commit-%d-%s-%s", projectID, language, jobID)
+			err = publishDelayedTask(cfg, "translate_files", "commit_translations", id, req, 1, cfg.TaskRetryDelay)
+		}
+		if err != nil {
+			log.Printf("Failed to commit %s translations for project %d: %v", language, projectID, err)
+			message := fmt.Sprintf("Worker failed to commit %d translated files to %s: %v", len(written), language, err)
+			logging.LogActivity(cfg.LoggingServiceURL, "worker_commit_failed", message, nil, &projectID, "error")
+			reportCommitFailed(cfg, projectID, language, written, err.Error())
+		}
+	}
+
+	// Log the translation summary
+	message := fmt.Sprintf("Worker translated %d of %d files to %s for project %d (%d of %d segments from translation memory, %d fuzzy matches, %d prompt and %d completion tokens, estimated cost $%.4f)",
+		translated, len(filesInterface), language, projectID, memory.ExactHits, memory.Segments, memory.FuzzyHits, usage.PromptTokens, usage.CompletionTokens, cost)
+	level := "info"
+	if translated < len(filesInterface) {
+		level = "warning"
+	}
+	logging.LogActivity(cfg.LoggingServiceURL, "worker_files_translated", message, nil, &projectID, level)
+}
+
+// remainingTask is a translate_files task for the files of task that were not translated
+func remainingTask(task Task, files []interface{}) Task {
+	payload := make(map[string]interface{}, len(task.Payload))
+	for k, v := range task.Payload {
+		payload[k] = v
+	}
+	payload["files"] = files
+	return Task{Type: task.Type, ID: task.ID, Payload: payload, Attempt: task.Attempt}
+}
+
+// handleCommitTranslations retries committing a batch of translated files that
+// found the project busy
+func handleCommitTranslations(cfg *config.Config, payload map[string]interface{}) error {
+	if _, ok := payload["projectId"].(float64); !ok {
+		log.Printf("Invalid payload for commit_translations: %v", payload)
+		return nil
+	}
+
+	if err := callRepositoryService(cfg, http.MethodPost, "/internal/commit-translations", payload); err != nil {
+		log.Printf("Failed to commit translations: %v", err)
+		if !errors.Is(err, errProjectBusy) {
+			projectID, language, files := commitPayload(payload)
+			reportCommitFailed(cfg, projectID, language, files, err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// commitPayload extracts the project, language and files of a commit_translations payload
+func commitPayload(payload map[string]interface{}) (projectID int, language string, files []string) {
+	if id, ok := payload["projectId"].(float64); ok {
+		projectID = int(id)
+	}
+	language, _ = payload["language"].(string)
+	list, _ := payload["files"].([]interface{})
+	for _, f := range list {
+		if file, ok := f.(string); ok {
+			files = append(files, file)
+		}
+	}
+	return projectID, language, files
+}
+
+// reportCommitFailed tells the translation service that translated files never
+// reached their language branch, so that they are not reported as translated
+func reportCommitFailed(cfg *config.Config, projectID int, language string, files []string, reason string) {
+	req := translation.CommitFailedRequest{ProjectID: projectID, Language: language, Files: files, Error: reason}
+	if err := callTranslationService(cfg, "/internal/commit-failed", req, nil); err != nil {
+		log.Printf("Failed to report the failed commit of %d files to %s: %v", len(files), language, err)
+	}
+}
+
+// errTranslationUnavailable is returned when the AI providers are rate limited or down
+var errTranslationUnavailable = errors.New("translation provider unavailable")
+
+// unavailableError is errTranslationUnavailable with the wait the translation service asked for
+type unavailableError struct {
+	retryAfter time.Duration
+}
+
+func (e *unavailableError) Error() string {
+	if e.retryAfter > 0 {
+		return fmt.Sprintf("%v (retry after %s)", errTranslationUnavailable, e.retryAfter)
+	}
+	return errTranslationUnavailable.Error()
+}
+
+func (e *unavailableError) Unwrap() error {
+	return errTranslationUnavailable
+}
+
+// translateFile sends one source file to the translation service. The worker
+// never writes to the language worktree itself: the translation is written by the
+// repository service under the project lock when it is committed.
+func translateFile(cfg *config.Config, jobID string, projectID int, sourceLanguage, language, file string) (*translation.TranslateDocumentResponse, error) {
+	sourcePath, err := storage.Repos.SourceFile(projectID, file)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := storage.ReadRegularFile(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source file: %w", err)
+	}
+
+	req := translation.TranslateDocumentRequest{
+		ProjectID:      projectID,
+		JobID:          jobID,
+		SourceLanguage: sourceLanguage,
+		TargetLanguage: language,
+		Path:           file,
+		Content:        string(content),
+	}
+	var result translation.TranslateDocumentResponse
+	if err := callTranslationService(cfg, "/internal/translate-document", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func handleBuildTask(cfg *config.Config, payload map[string]interface{}) error {
 	projectIDFloat, ok1 := payload["projectId"].(float64)
 	buildType, ok2 := payload["buildType"].(string) // "build", "export", or "preview"
 
 	if !ok1 || !ok2 {
 		log.Printf("Invalid payload for build_task: %v", payload)
-		return
+		return nil
 	}
 
 	projectID := int(projectIDFloat)
@@ -242,16 +511,29 @@ func handleBuildTask(cfg *config.Config, payload map[string]interface{}) {
 		endpoint = "/internal/preview"
 	default:
 		log.Printf("Unknown build type: %s", buildType)
-		return
+		return nil
 	}
 
 	if err := callBuildService(cfg, http.MethodPost, endpoint, req); err != nil {
 		log.Printf("Failed to execute %s: %v", buildType, err)
-	} else {
-		// Log successful build task
-		message := fmt.Sprintf("Worker successfully executed %s for project %d", buildType, projectID)
-		logging.LogActivity(cfg.LoggingServiceURL, "worker_build_task_completed", message, nil, &projectID, "info")
+		return err
 	}
+
+	// Log successful build task
+	message := fmt.Sprintf("Worker successfully executed %s for project %d", buildType, projectID)
+	logging.LogActivity(cfg.LoggingServiceURL, "worker_build_task_completed", message, nil, &projectID, "info")
+	return nil
+}
+
+// repositoryWorkTimeout bounds the git work of one repository service request
+// once it holds the project lock
+var repositoryWorkTimeout = 10 * time.Minute
+
+// repositoryClient returns the client of repository service requests. They may
+// wait up to ProjectLockTimeout for the project lock before doing any git work,
+// and a hung service must not block the consumer forever.
+func repositoryClient(cfg *config.Config) *http.Client {
+	return &http.Client{Timeout: cfg.ProjectLockTimeout + repositoryWorkTimeout}
 }
 
 func callRepositoryService(cfg *config.Config, method, endpoint string, req map[string]interface{}) error {
@@ -262,32 +544,25 @@ func callRepositoryService(cfg *config.Config, method, endpoint string, req map[
 		return err
 	}
 
-	var resp *http.Response
 	switch method {
-	case http.MethodPost:
-		resp, err = http.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	case http.MethodPut:
-		req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(jsonData))
-		if err != nil {
-			return err
-		}
-		req.Header.Set("Content-Type", "application/json")
-		resp, err = http.DefaultClient.Do(req)
-	case http.MethodDelete:
-		req, err := http.NewRequest(http.MethodDelete, url, bytes.NewBuffer(jsonData))
-		if err != nil {
-			return err
-		}
-		req.Header.Set("Content-Type", "application/json")
-		resp, err = http.DefaultClient.Do(req)
+	case http.MethodPost, http.MethodPut, http.MethodDelete:
 	default:
 		return fmt.Errorf("unsupported method: %s", method)
 	}
+	httpReq, err := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := repositoryClient(cfg).Do(httpReq)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusConflict {
+		return errProjectBusy
+	}
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		return fmt.Errorf("repository service returned status %d", resp.StatusCode)
 	}
@@ -296,6 +571,38 @@ func callRepositoryService(cfg *config.Config, method, endpoint string, req map[
 	return nil
 }
 
+func callTranslationService(cfg *config.Config, endpoint string, req interface{}, result interface{}) error {
+	url := cfg.TranslationServiceURL + endpoint
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		// The translation service sends Retry-After in seconds
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &unavailableError{retryAfter: time.Duration(seconds) * time.Second}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("translation service returned status %d", resp.StatusCode)
+	}
+
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("failed to decode translation service response: %w", err)
+		}
+	}
+
+	return nil
+}
+
 func callBuildService(cfg *config.Config, method, endpoint string, req map[string]interface{}) error {
 	url := cfg.BuildServiceURL + endpoint
 
@@ -310,6 +617,9 @@ func callBuildService(cfg *config.Config, method, endpoint string, req map[strin
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusConflict {
+		return errProjectBusy
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("build service returned status %d", resp.StatusCode)
 	}
@@ -0,0 +1,237 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/config"
+	"github.com/xeodocs/xeodocs-backend/internal/shared/storage"
+	"github.com/xeodocs/xeodocs-backend/internal/translation"
+)
+
+// delayedTask is a task passed to publishDelayedTask
+type delayedTask struct {
+	queueName string
+	task      Task
+	delay     time.Duration
+}
+
+// recordDelayedTasks replaces publishDelayedTask for one test
+func recordDelayedTasks(t *testing.T) *[]delayedTask {
+	var published []delayedTask
+	previous := publishDelayedTask
+	publishDelayedTask = func(cfg *config.Config, queueName, taskType, id string, payload map[string]interface{}, attempt int, delay time.Duration) error {
+		published = append(published, delayedTask{queueName: queueName, task: Task{Type: taskType, ID: id, Payload: payload, Attempt: attempt}, delay: delay})
+		return nil
+	}
+	t.Cleanup(func() { publishDelayedTask = previous })
+	return &published
+}
+
+func TestTranslateFilesRequeuesRemainingFilesWhenUnavailable(t *testing.T) {
+	published := recordDelayedTasks(t)
+
+	previous := storage.Repos
+	storage.Repos = storage.NewWorkspace(t.TempDir())
+	t.Cleanup(func() { storage.Repos = previous })
+	for _, file := range []string{"docs/a.md", "docs/b.md"} {
+		path, err := storage.Repos.SourceFile(3, file)
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("# Title\n"), 0644))
+	}
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "42")
+		http.Error(w, "AI provider unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{TranslationServiceURL: server.URL, TaskRetryDelay: time.Second, TaskMaxRetries: 3}
+	handleTranslateFiles(cfg, Task{
+		Type:    "translate_files",
+		ID:      "translate-3-es-1",
+		Payload: map[string]interface{}{"projectId": float64(3), "language": "es", "files": []interface{}{"docs/a.md", "docs/b.md"}},
+		Attempt: 1,
+	})
+
+	// The batch stops at the first unavailable answer and is published again as a whole
+	require.Equal(t, 1, calls)
+	require.Len(t, *published, 1)
+	retry := (*published)[0]
+	require.Equal(t, "translate_files", retry.queueName)
+	require.Equal(t, 42*time.Second, retry.delay)
+	require.Equal(t, "translate-3-es-1", retry.task.ID)
+	require.Equal(t, 2, retry.task.Attempt)
+	require.Equal(t, []interface{}{"docs/a.md", "docs/b.md"}, retry.task.Payload["files"])
+	require.Equal(t, "es", retry.task.Payload["language"])
+}
+
+func TestCallTranslationServiceWithoutRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "AI provider unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := callTranslationService(&config.Config{TranslationServiceURL: server.URL}, "/internal/translate-document", map[string]string{}, nil)
+	require.ErrorIs(t, err, errTranslationUnavailable)
+	var unavailable *unavailableError
+	require.ErrorAs(t, err, &unavailable)
+	require.Zero(t, unavailable.retryAfter)
+}
+
+func TestRetryTaskGivesUpAfterMaxRetries(t *testing.T) {
+	published := recordDelayedTasks(t)
+	cfg := &config.Config{TaskMaxRetries: 3}
+
+	task := Task{Type: "clone_repo", ID: "clone-5", Payload: map[string]interface{}{"projectId": float64(5)}, Attempt: 2}
+	require.True(t, retryTask(cfg, "repository_tasks", task, 10*time.Second, "project is busy"))
+	require.Len(t, *published, 1)
+	require.Equal(t, "repository_tasks", (*published)[0].queueName)
+	require.Equal(t, 3, (*published)[0].task.Attempt)
+	require.Equal(t, 10*time.Second, (*published)[0].delay)
+
+	// The last allowed attempt is not published again
+	task.Attempt = 3
+	require.False(t, retryTask(cfg, "repository_tasks", task, 10*time.Second, "project is busy"))
+	require.Len(t, *published, 1)
+}
+
+func TestTranslateFilesSendsContentsToCommit(t *testing.T) {
+	recordDelayedTasks(t)
+
+	previous := storage.Repos
+	storage.Repos = storage.NewWorkspace(t.TempDir())
+	t.Cleanup(func() { storage.Repos = previous })
+	for _, file := range []string{"docs/a.md", "docs/b.md"} {
+		path, err := storage.Repos.SourceFile(3, file)
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("# Title\n"), 0644))
+	}
+
+	var commit struct {
+		Files    []string          `json:"files"`
+		Contents map[string]string `json:"contents"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/internal/translate-document":
+			var req struct {
+				Path string `json:"path"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"content":     "# Título " + req.Path + "\n",
+				"provider":    "openai",
+				"needsReview": req.Path == "docs/b.md",
+			})
+		case "/internal/commit-translations":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&commit))
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{TranslationServiceURL: server.URL, RepositoryServiceURL: server.URL, TaskRetryDelay: time.Second, TaskMaxRetries: 3}
+	handleTranslateFiles(cfg, Task{
+		Type:    "translate_files",
+		ID:      "translate-3-es-1",
+		Payload: map[string]interface{}{"projectId": float64(3), "language": "es", "files": []interface{}{"docs/a.md", "docs/b.md"}},
+	})
+
+	// Only the published translation is sent, and nothing is written by the worker
+	require.Equal(t, []string{"docs/a.md"}, commit.Files)
+	require.Equal(t, map[string]string{"docs/a.md": "# Título docs/a.md\n"}, commit.Contents)
+	target, err := storage.Repos.LanguageFile(3, "es", "docs/a.md")
+	require.NoError(t, err)
+	require.NoFileExists(t, target)
+}
+
+func TestCallRepositoryServiceTimesOut(t *testing.T) {
+	previous := repositoryWorkTimeout
+	repositoryWorkTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { repositoryWorkTimeout = previous })
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// A hung service fails the call after the lock timeout and the work timeout
+	cfg := &config.Config{RepositoryServiceURL: server.URL, ProjectLockTimeout: 100 * time.Millisecond}
+	start := time.Now()
+	err := callRepositoryService(cfg, http.MethodPut, "/internal/sync-repo", map[string]interface{}{"projectId": 1})
+	require.Error(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+// recordCommitFailures serves /internal/commit-failed and records the reports
+func recordCommitFailures(t *testing.T, mux *http.ServeMux) *[]translation.CommitFailedRequest {
+	var reports []translation.CommitFailedRequest
+	mux.HandleFunc("/internal/commit-failed", func(w http.ResponseWriter, r *http.Request) {
+		var req translation.CommitFailedRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		reports = append(reports, req)
+	})
+	return &reports
+}
+
+func TestFailedCommitIsReported(t *testing.T) {
+	mux := http.NewServeMux()
+	reports := recordCommitFailures(t, mux)
+	mux.HandleFunc("/internal/commit-translations", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Failed to commit translations", http.StatusInternalServerError)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := &config.Config{TranslationServiceURL: server.URL, RepositoryServiceURL: server.URL}
+	payload := map[string]interface{}{"projectId": float64(3), "language": "es", "files": []interface{}{"docs/a.md"}}
+	require.Error(t, handleCommitTranslations(cfg, payload))
+
+	require.Equal(t, []translation.CommitFailedRequest{{
+		ProjectID: 3, Language: "es", Files: []string{"docs/a.md"}, Error: "repository service returned status 500",
+	}}, *reports)
+}
+
+func TestAbandonedCommitIsReported(t *testing.T) {
+	published := recordDelayedTasks(t)
+	mux := http.NewServeMux()
+	reports := recordCommitFailures(t, mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := &config.Config{TranslationServiceURL: server.URL, TaskMaxRetries: 2}
+	task := Task{
+		Type:    "commit_translations",
+		ID:      "commit-3-es-1",
+		Payload: map[string]interface{}{"projectId": float64(3), "language": "es", "files": []interface{}{"docs/a.md"}},
+		Attempt: 1,
+	}
+
+	// Busy commits are retried without being reported
+	retryBusyTask(cfg, "translate_files", task, errProjectBusy)
+	require.Len(t, *published, 1)
+	require.Empty(t, *reports)
+
+	// The last attempt is reported once it is given up on
+	task.Attempt = 2
+	retryBusyTask(cfg, "translate_files", task, errProjectBusy)
+	require.Len(t, *published, 1)
+	require.Len(t, *reports, 1)
+	require.Equal(t, []string{"docs/a.md"}, (*reports)[0].Files)
+}